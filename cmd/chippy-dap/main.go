@@ -0,0 +1,30 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"fmt"
+	"os"
+)
+
+// chippy-dap is a Debug Adapter Protocol server for chippy, talking to the
+// editor over stdin and stdout. The CHIP-8 runs without a window.
+func main() {
+	if err := newSession(newConn(os.Stdin, os.Stdout)).serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "chippy-dap: "+err.Error())
+		os.Exit(1)
+	}
+}
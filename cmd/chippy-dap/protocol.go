@@ -0,0 +1,149 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// Largest message we read, requests are small so anything bigger is broken
+const MAX_MESSAGE_SIZE = 1 << 20
+
+// A Debug Adapter Protocol request from the editor
+// https://microsoft.github.io/debug-adapter-protocol/specification
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// A response to a request
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+// An event, sent whenever something happens
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// Reads and writes DAP messages, framed by a Content-Length header
+// Writes may come from any goroutine
+type conn struct {
+	r *textproto.Reader
+
+	mu  sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+// Wraps a reader and writer, usually stdin and stdout
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+// Reads the next request
+func (c *conn) read() (*request, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("bad Content-Length %q :(", header.Get("Content-Length"))
+	}
+	if length > MAX_MESSAGE_SIZE {
+		return nil, fmt.Errorf("Content-Length %d is over %d :(", length, MAX_MESSAGE_SIZE)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	req := &request{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Answers a request, successfully if err is nil
+func (c *conn) respond(req *request, body interface{}, err error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	resp := response{
+		Seq:        c.seq,
+		Type:       "response",
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return c.write(resp)
+}
+
+// Sends an event
+func (c *conn) event(name string, body interface{}) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	return c.write(event{Seq: c.seq, Type: "event", Event: name, Body: body})
+}
+
+// Writes one message, with the lock held
+func (c *conn) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+// Sends log output to the editor's debug console, as output events
+type consoleWriter struct {
+	conn *conn
+}
+
+func (w consoleWriter) Write(p []byte) (int, error) {
+	err := w.conn.event("output", map[string]interface{}{
+		"category": "console",
+		"output":   string(p),
+	})
+	return len(p), err
+}
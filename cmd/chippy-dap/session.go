@@ -0,0 +1,810 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy"
+	"chippy/pkg/chip8"
+	"chippy/pkg/logging"
+	"chippy/pkg/romdb"
+	"chippy/pkg/rominfo"
+	"chippy/pkg/symbols"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The only thread there is
+const THREAD_ID = 1
+
+// Variables references for the scopes
+const (
+	varsRegisters = 1 + iota
+	varsStack
+)
+
+// Arguments to launch
+type launchArgs struct {
+	// ROM to run, a path or builtin:<name>
+	Program string `json:"program"`
+
+	// Optional symbol file, see pkg/symbols
+	Symbols string `json:"symbols"`
+
+	// Stop before the first instruction
+	StopOnEntry bool `json:"stopOnEntry"`
+
+	// Emulator log level for the debug console, like chippy -log-level
+	LogLevel string `json:"logLevel"`
+
+	// Settings, on top of the ROM database, like the chippy flags
+	Profile  string `json:"profile"`
+	Timing   string `json:"timing"`
+	TickRate int    `json:"tickrate"`
+}
+
+// A source file, as the editor names it
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// A breakpoint, as the editor sees it
+type breakpoint struct {
+	ID                   int     `json:"id"`
+	Verified             bool    `json:"verified"`
+	Message              string  `json:"message,omitempty"`
+	Line                 int     `json:"line,omitempty"`
+	Source               *source `json:"source,omitempty"`
+	InstructionReference string  `json:"instructionReference,omitempty"`
+}
+
+// A debugging session, one CHIP-8 driven by one editor
+type session struct {
+	conn *conn
+
+	// Guards everything below, the CHIP-8 runs on its own goroutine
+	mu sync.Mutex
+
+	chip    chip8.Chip8
+	symbols *symbols.Table
+
+	// Launched and configured, the CHIP-8 is running unless halted
+	launched    bool
+	stopOnEntry bool
+	running     bool
+
+	// Breakpoints, by where they were set from
+	sourceBreakpoints      map[string][]uint16
+	functionBreakpoints    []uint16
+	instructionBreakpoints []uint16
+	breakpoints            map[uint16]bool
+	breakpointID           int
+
+	// Instruction count when we last resumed, so we can run off the
+	// breakpoint we are stopped on
+	resumedAt uint64
+
+	// Step over and step out stop when this returns true
+	until func() bool
+
+	// Starts the run loop, once however many times the editor configures us
+	start sync.Once
+
+	// Closed when the session ends
+	done chan struct{}
+}
+
+// Creates a session talking over the connection
+func newSession(c *conn) *session {
+	return &session{
+		conn:              c,
+		symbols:           symbols.New(),
+		sourceBreakpoints: map[string][]uint16{},
+		breakpoints:       map[uint16]bool{},
+		done:              make(chan struct{}),
+	}
+}
+
+// Serves requests until the editor disconnects
+func (s *session) serve() error {
+	defer close(s.done)
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+
+		s.mu.Lock()
+		body, after, err := s.handle(req)
+		s.mu.Unlock()
+		if err := s.conn.respond(req, body, err); err != nil {
+			return err
+		}
+
+		// Events that have to follow the response
+		if after != nil {
+			s.mu.Lock()
+			after()
+			s.mu.Unlock()
+		}
+		if req.Command == "disconnect" || req.Command == "terminate" {
+			s.conn.event("terminated", nil)
+			return nil
+		}
+	}
+}
+
+// Handles a request, with the lock held
+// Returns the response body, and optionally something to do after responding
+func (s *session) handle(req *request) (interface{}, func(), error) {
+	switch req.Command {
+	case "initialize":
+		return map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsInstructionBreakpoints":   true,
+			"supportsReadMemoryRequest":        true,
+			"supportsDisassembleRequest":       true,
+			"supportsSetVariable":              true,
+			"supportsTerminateRequest":         true,
+		}, nil, nil
+
+	case "launch":
+		var args launchArgs
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		if err := s.launch(args); err != nil {
+			return nil, nil, err
+		}
+		// Ready for breakpoints now the symbols are loaded
+		return nil, func() { s.conn.event("initialized", nil) }, nil
+
+	case "setBreakpoints":
+		return s.setBreakpoints(req.Arguments)
+
+	case "setFunctionBreakpoints":
+		return s.setFunctionBreakpoints(req.Arguments)
+
+	case "setInstructionBreakpoints":
+		return s.setInstructionBreakpoints(req.Arguments)
+
+	case "setExceptionBreakpoints":
+		// CHIP-8 has no exceptions
+		return map[string]interface{}{"breakpoints": []breakpoint{}}, nil, nil
+
+	case "configurationDone":
+		if !s.launched {
+			return nil, nil, fmt.Errorf("nothing launched :(")
+		}
+		s.start.Do(func() { go s.run() })
+		if s.stopOnEntry {
+			return nil, func() { s.halt("entry") }, nil
+		}
+		s.resume(nil)
+		return nil, nil, nil
+
+	case "threads":
+		return map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": THREAD_ID, "name": "CHIP-8"}},
+		}, nil, nil
+
+	case "stackTrace":
+		return map[string]interface{}{"stackFrames": s.stackFrames()}, nil, nil
+
+	case "scopes":
+		return map[string]interface{}{
+			"scopes": []map[string]interface{}{
+				{"name": "Registers", "variablesReference": varsRegisters, "expensive": false},
+				{"name": "Stack", "variablesReference": varsStack, "expensive": false},
+			},
+		}, nil, nil
+
+	case "variables":
+		var args struct {
+			VariablesReference int `json:"variablesReference"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		return map[string]interface{}{"variables": s.variables(args.VariablesReference)}, nil, nil
+
+	case "setVariable":
+		var args struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		val, err := parseNumber(args.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !s.setRegister(args.Name, val) {
+			return nil, nil, fmt.Errorf("%s can't be changed :(", args.Name)
+		}
+		got, _ := s.register(args.Name)
+		return map[string]interface{}{"value": formatValue(args.Name, got)}, nil, nil
+
+	case "continue":
+		s.resume(nil)
+		return map[string]interface{}{"allThreadsContinued": true}, nil, nil
+
+	case "next":
+		// Step over calls by running until the stack is back where it was
+		if s.chip.Memory(s.chip.PC())&0xF0 == 0x20 {
+			depth := s.chip.SP()
+			s.resume(func() bool { return s.chip.SP() <= depth })
+			return nil, nil, nil
+		}
+		return nil, s.step, nil
+
+	case "stepIn":
+		return nil, s.step, nil
+
+	case "stepOut":
+		depth := s.chip.SP()
+		if depth == 0 {
+			return nil, s.step, nil
+		}
+		s.resume(func() bool { return s.chip.SP() < depth })
+		return nil, nil, nil
+
+	case "pause":
+		return nil, func() { s.halt("pause") }, nil
+
+	case "readMemory":
+		return s.readMemory(req.Arguments)
+
+	case "disassemble":
+		return s.disassemble(req.Arguments)
+
+	case "evaluate":
+		var args struct {
+			Expression string `json:"expression"`
+		}
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.evaluate(args.Expression)
+		if err != nil {
+			return nil, nil, err
+		}
+		return map[string]interface{}{"result": result, "variablesReference": 0}, nil, nil
+
+	case "disconnect", "terminate":
+		s.running = false
+		return nil, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("%s isn't supported :(", req.Command)
+}
+
+// Loads the ROM and symbols, and applies the settings
+func (s *session) launch(args launchArgs) error {
+	var rom []byte
+	var err error
+	if strings.HasPrefix(args.Program, "builtin:") {
+		rom, err = chippy.ReadBuiltinROM(strings.TrimPrefix(args.Program, "builtin:"))
+	} else {
+		rom, err = os.ReadFile(args.Program)
+	}
+	if err != nil {
+		return err
+	}
+
+	level := logging.LEVEL_INFO
+	if args.LogLevel != "" {
+		if level, err = logging.ParseLevel(args.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	// stdout is the protocol, so the emulator log goes to the debug console
+	chip8.SetDefaultLogger(logging.New(consoleWriter{conn: s.conn}, level))
+	s.chip = chip8.Init()
+
+	if args.Symbols != "" {
+		table, err := symbols.Load(args.Symbols)
+		if err != nil {
+			return err
+		}
+		s.symbols = table
+	}
+
+	// Same layering as chippy: analysis, then the database, then us
+	report := rominfo.Analyze(rom)
+	settings := romdb.Recommended(report)
+	if db, err := romdb.Open(); err == nil {
+		if known, ok := db.Lookup(report.SHA1); ok {
+			settings = settings.Merge(known)
+		}
+	}
+	settings = settings.Merge(romdb.Settings{Profile: args.Profile, Timing: args.Timing, TickRate: args.TickRate})
+
+	if err := settings.Apply(&s.chip); err != nil {
+		return err
+	}
+
+	// The platform decides where the ROM goes, so load it last
+	if _, err := s.chip.LoadROMBytes(rom); err != nil {
+		return err
+	}
+
+	s.launched = true
+	s.stopOnEntry = args.StopOnEntry
+	return nil
+}
+
+// Runs the CHIP-8 at 60Hz until the session ends
+func (s *session) run() {
+	ticker := time.NewTicker(time.Second / chip8.FRAME_RATE)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			if s.running {
+				s.runFrame()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Runs one 60Hz frame, stopping early at breakpoints and step targets
+func (s *session) runFrame() {
+	frame := s.chip.Frame()
+	for s.chip.Frame() == frame {
+		if s.breakpoints[s.chip.PC()] && s.chip.Instructions() != s.resumedAt {
+			s.halt("breakpoint")
+			return
+		}
+		executed := s.chip.Instructions()
+		s.chip.Cycle()
+		if s.until != nil && s.chip.Instructions() != executed && s.until() {
+			s.halt("step")
+			return
+		}
+	}
+}
+
+// Lets the CHIP-8 run, until a breakpoint or until returns true
+func (s *session) resume(until func() bool) {
+	s.running = true
+	s.resumedAt = s.chip.Instructions()
+	s.until = until
+}
+
+// Stops the CHIP-8, and tells the editor why
+func (s *session) halt(reason string) {
+	s.running = false
+	s.until = nil
+	s.conn.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          THREAD_ID,
+		"allThreadsStopped": true,
+	})
+}
+
+// Executes one instruction, and stops
+// In a machine code routine that is one 1802 instruction, so stepping
+// through a routine that spins can't hold the lock for ever
+func (s *session) step() {
+	s.chip.Step()
+	s.halt("step")
+}
+
+// Sets the breakpoints for a source file, replacing its old ones
+func (s *session) setBreakpoints(raw json.RawMessage) (interface{}, func(), error) {
+	var args struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line int `json:"line"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, nil, err
+	}
+
+	addrs := []uint16{}
+	result := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		bp := s.newBreakpoint()
+		bp.Line = b.Line
+		bp.Source = &args.Source
+		if found := s.symbols.Addresses(args.Source.Path, b.Line); len(found) > 0 {
+			addrs = append(addrs, found...)
+			bp.Verified = true
+			bp.InstructionReference = formatAddr(found[0])
+		} else {
+			bp.Message = "no code at this line in the symbol file"
+		}
+		result = append(result, bp)
+	}
+	s.sourceBreakpoints[args.Source.Path] = addrs
+	s.updateBreakpoints()
+	return map[string]interface{}{"breakpoints": result}, nil, nil
+}
+
+// Sets breakpoints on labels or addresses, replacing the old ones
+func (s *session) setFunctionBreakpoints(raw json.RawMessage) (interface{}, func(), error) {
+	var args struct {
+		Breakpoints []struct {
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, nil, err
+	}
+
+	s.functionBreakpoints = nil
+	result := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		bp := s.newBreakpoint()
+		addr, ok := s.symbols.Label(b.Name)
+		if !ok {
+			val, err := parseNumber(b.Name)
+			addr, ok = uint16(val), err == nil
+		}
+		if ok {
+			s.functionBreakpoints = append(s.functionBreakpoints, addr&0x0FFF)
+			bp.Verified = true
+			bp.InstructionReference = formatAddr(addr & 0x0FFF)
+		} else {
+			bp.Message = "not a label or an address"
+		}
+		result = append(result, bp)
+	}
+	s.updateBreakpoints()
+	return map[string]interface{}{"breakpoints": result}, nil, nil
+}
+
+// Sets breakpoints on instruction addresses, replacing the old ones
+func (s *session) setInstructionBreakpoints(raw json.RawMessage) (interface{}, func(), error) {
+	var args struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+		} `json:"breakpoints"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, nil, err
+	}
+
+	s.instructionBreakpoints = nil
+	result := []breakpoint{}
+	for _, b := range args.Breakpoints {
+		bp := s.newBreakpoint()
+		val, err := parseNumber(b.InstructionReference)
+		if err == nil {
+			addr := uint16(int(val)+b.Offset) & 0x0FFF
+			s.instructionBreakpoints = append(s.instructionBreakpoints, addr)
+			bp.Verified = true
+			bp.InstructionReference = formatAddr(addr)
+		} else {
+			bp.Message = err.Error()
+		}
+		result = append(result, bp)
+	}
+	s.updateBreakpoints()
+	return map[string]interface{}{"breakpoints": result}, nil, nil
+}
+
+// Returns a new, unverified, breakpoint
+func (s *session) newBreakpoint() breakpoint {
+	s.breakpointID++
+	return breakpoint{ID: s.breakpointID}
+}
+
+// Rebuilds the breakpoint set from every kind of breakpoint
+func (s *session) updateBreakpoints() {
+	s.breakpoints = map[uint16]bool{}
+	for _, addrs := range s.sourceBreakpoints {
+		for _, addr := range addrs {
+			s.breakpoints[addr] = true
+		}
+	}
+	for _, addr := range s.functionBreakpoints {
+		s.breakpoints[addr] = true
+	}
+	for _, addr := range s.instructionBreakpoints {
+		s.breakpoints[addr] = true
+	}
+}
+
+// Returns the stack frames, the PC first, then every call on the stack
+func (s *session) stackFrames() []map[string]interface{} {
+	addrs := []uint16{s.chip.PC()}
+	stack := s.chip.Stack()
+	for i := len(stack) - 1; i >= 0; i-- {
+		addrs = append(addrs, stack[i])
+	}
+
+	frames := []map[string]interface{}{}
+	for i, addr := range addrs {
+		frame := map[string]interface{}{
+			"id":                          i,
+			"name":                        s.describe(addr),
+			"line":                        0,
+			"column":                      0,
+			"instructionPointerReference": formatAddr(addr),
+		}
+		if src, ok := s.symbols.Line(addr); ok {
+			frame["source"] = source{Path: src.File}
+			frame["line"] = src.Line
+		}
+		frames = append(frames, frame)
+	}
+	return frames
+}
+
+// Returns a label+offset name for an address, or just the address
+func (s *session) describe(addr uint16) string {
+	if label, offset, ok := s.symbols.Nearest(addr); ok {
+		if offset == 0 {
+			return label
+		}
+		return fmt.Sprintf("%s+%d", label, offset)
+	}
+	return formatAddr(addr)
+}
+
+// Names of the registers, in the order they are shown
+var registerNames = []string{
+	"V0", "V1", "V2", "V3", "V4", "V5", "V6", "V7",
+	"V8", "V9", "VA", "VB", "VC", "VD", "VE", "VF",
+	"I", "PC", "SP", "DT", "ST",
+}
+
+// Returns the variables for a scope
+func (s *session) variables(ref int) []map[string]interface{} {
+	vars := []map[string]interface{}{}
+	switch ref {
+	case varsRegisters:
+		for _, name := range registerNames {
+			val, _ := s.register(name)
+			v := map[string]interface{}{
+				"name":               name,
+				"value":              formatValue(name, val),
+				"variablesReference": 0,
+			}
+			if name == "I" || name == "PC" {
+				v["memoryReference"] = formatAddr(val)
+			}
+			vars = append(vars, v)
+		}
+
+	case varsStack:
+		stack := s.chip.Stack()
+		for i := len(stack) - 1; i >= 0; i-- {
+			vars = append(vars, map[string]interface{}{
+				"name":               fmt.Sprintf("#%d", len(stack)-1-i),
+				"value":              s.describe(stack[i]),
+				"variablesReference": 0,
+				"memoryReference":    formatAddr(stack[i]),
+			})
+		}
+	}
+	return vars
+}
+
+// Returns a register by name
+func (s *session) register(name string) (uint16, bool) {
+	name = strings.ToUpper(name)
+	switch name {
+	case "I":
+		return s.chip.I(), true
+	case "PC":
+		return s.chip.PC(), true
+	case "SP":
+		return s.chip.SP(), true
+	case "DT":
+		return uint16(s.chip.DelayTimer()), true
+	case "ST":
+		return uint16(s.chip.SoundTimer()), true
+	}
+	if x, ok := vRegister(name); ok {
+		return uint16(s.chip.V(x)), true
+	}
+	return 0, false
+}
+
+// Sets a register by name, SP can't be set
+func (s *session) setRegister(name string, val uint64) bool {
+	name = strings.ToUpper(name)
+	switch name {
+	case "I":
+		s.chip.SetI(uint16(val))
+	case "PC":
+		s.chip.SetPC(uint16(val))
+	case "DT":
+		s.chip.SetDelayTimer(uint8(val))
+	case "ST":
+		s.chip.SetSoundTimer(uint8(val))
+	default:
+		x, ok := vRegister(name)
+		if !ok {
+			return false
+		}
+		s.chip.SetV(x, uint8(val))
+	}
+	return true
+}
+
+// Reads memory for the editor's hex view
+func (s *session) readMemory(raw json.RawMessage) (interface{}, func(), error) {
+	var args struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, nil, err
+	}
+	base, err := parseNumber(args.MemoryReference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Only the 4 KiB address space is readable
+	start := int(base) + args.Offset
+	end := start + args.Count
+	if start < 0 {
+		start = 0
+	}
+	if end > chip8.MEMORY_SIZE {
+		end = chip8.MEMORY_SIZE
+	}
+	count := end - start
+	if count < 0 {
+		count = 0
+	}
+	data := s.chip.ReadMemory(uint16(start), count)
+	return map[string]interface{}{
+		"address":         formatAddr(uint16(start)),
+		"data":            base64.StdEncoding.EncodeToString(data),
+		"unreadableBytes": args.Count - count,
+	}, nil, nil
+}
+
+// Disassembles memory for the editor's disassembly view
+func (s *session) disassemble(raw json.RawMessage) (interface{}, func(), error) {
+	var args struct {
+		MemoryReference   string `json:"memoryReference"`
+		Offset            int    `json:"offset"`
+		InstructionOffset int    `json:"instructionOffset"`
+		InstructionCount  int    `json:"instructionCount"`
+	}
+	if err := json.Unmarshal(raw, &args); err != nil {
+		return nil, nil, err
+	}
+	base, err := parseNumber(args.MemoryReference)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	instructions := []map[string]interface{}{}
+	addr := int(base) + args.Offset + args.InstructionOffset*2
+	for i := 0; i < args.InstructionCount; i, addr = i+1, addr+2 {
+		if addr < 0 || addr+1 >= chip8.MEMORY_SIZE {
+			// Outside memory, the editor still wants a row
+			instructions = append(instructions, map[string]interface{}{
+				"address":     fmt.Sprintf("0x%X", addr&0xFFFF),
+				"instruction": "??",
+			})
+			continue
+		}
+		oc := uint16(s.chip.Memory(uint16(addr)))<<8 | uint16(s.chip.Memory(uint16(addr+1)))
+		ins := map[string]interface{}{
+			"address":          formatAddr(uint16(addr)),
+			"instructionBytes": fmt.Sprintf("%04X", oc),
+			"instruction":      chip8.Disassemble(oc),
+		}
+		if label, offset, ok := s.symbols.Nearest(uint16(addr)); ok && offset == 0 {
+			ins["symbol"] = label
+		}
+		if src, ok := s.symbols.Line(uint16(addr)); ok {
+			ins["location"] = source{Path: src.File}
+			ins["line"] = src.Line
+		}
+		instructions = append(instructions, ins)
+	}
+	return map[string]interface{}{"instructions": instructions}, nil, nil
+}
+
+// Evaluates a debug console expression:
+// a register (V3, I), a label or address (shows the byte there),
+// or "press K" / "release K" to work the keypad
+func (s *session) evaluate(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 2 && (fields[0] == "press" || fields[0] == "release") {
+		key, err := strconv.ParseUint(fields[1], 16, 4)
+		if err != nil {
+			return "", fmt.Errorf("keys are 0 to F :(")
+		}
+		if fields[0] == "press" {
+			s.chip.KeyPress(int(key))
+		} else {
+			s.chip.KeyRelease(int(key))
+		}
+		return fmt.Sprintf("key %X %sed", key, fields[0]), nil
+	}
+
+	expr = strings.TrimSpace(expr)
+	if val, ok := s.register(expr); ok {
+		return formatValue(expr, val), nil
+	}
+	addr, ok := s.symbols.Label(expr)
+	if !ok {
+		val, err := parseNumber(expr)
+		if err != nil {
+			return "", fmt.Errorf("expected a register, label, address or press/release :(")
+		}
+		addr = uint16(val)
+	}
+	b := s.chip.Memory(addr)
+	return fmt.Sprintf("[%s] = 0x%02X (%d)", formatAddr(addr&0x0FFF), b, b), nil
+}
+
+// Returns the V register number for a name like VA
+func vRegister(name string) (int, bool) {
+	if len(name) != 2 || name[0] != 'V' {
+		return 0, false
+	}
+	x, err := strconv.ParseUint(name[1:], 16, 4)
+	return int(x), err == nil
+}
+
+// Formats a register value, addresses in hex and the rest in hex and decimal
+func formatValue(name string, val uint16) string {
+	switch strings.ToUpper(name) {
+	case "I", "PC":
+		return formatAddr(val)
+	default:
+		return fmt.Sprintf("0x%02X (%d)", val, val)
+	}
+}
+
+// Formats an address
+func formatAddr(addr uint16) string {
+	return fmt.Sprintf("0x%03X", addr)
+}
+
+// Parses a number, hex with 0x, otherwise decimal
+func parseNumber(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToLower(s), "0x") {
+		return strconv.ParseUint(s[2:], 16, 16)
+	}
+	return strconv.ParseUint(s, 10, 16)
+}
@@ -0,0 +1,363 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Counts up in a subroutine, forever
+//
+//	200: 6000  main: V0 = 0
+//	202: 2208        call inc
+//	204: 1202        jump 202
+//	206: 0000
+//	208: 7001  inc:  V0 += 1
+//	20A: 00EE        return
+var counter = []byte{0x60, 0x00, 0x22, 0x08, 0x12, 0x02, 0x00, 0x00, 0x70, 0x01, 0x00, 0xEE}
+
+// Symbols for counter, assembled from game.8o
+const counterSymbols = `
+0x200 main
+0x208 inc
+0x200 game.8o:1
+0x202 game.8o:2
+0x204 game.8o:3
+0x208 game.8o:6
+0x20A game.8o:7
+`
+
+// A message from the session, a response or an event
+type message struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// An editor, talking to a session over pipes
+type editor struct {
+	t   *testing.T
+	w   io.Writer
+	seq int
+
+	// Everything the session sends, read as it arrives like stdio would
+	msgs chan message
+
+	// Events read while waiting for something else
+	events []message
+}
+
+// Starts a session, with a config dir of its own so the user's ROM database
+// stays out of it, and connects an editor to it
+func startSession(t *testing.T) *editor {
+	t.Helper()
+	old, had := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Cleanup(func() {
+		if had {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	})
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := newSession(newConn(inR, outW))
+	done := make(chan error, 1)
+	go func() {
+		done <- s.serve()
+		outW.Close()
+	}()
+
+	e := &editor{t: t, w: inW, msgs: make(chan message, 1024)}
+	go e.receive(outR)
+	t.Cleanup(func() {
+		inW.Close()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+	return e
+}
+
+// Reads messages from the session until it closes its end
+func (e *editor) receive(r io.Reader) {
+	defer close(e.msgs)
+	tr := textproto.NewReader(bufio.NewReader(r))
+	for {
+		header, err := tr.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(tr.R, body); err != nil {
+			return
+		}
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			return
+		}
+		e.msgs <- msg
+	}
+}
+
+// Returns the next message from the session
+func (e *editor) read() message {
+	e.t.Helper()
+	select {
+	case msg, ok := <-e.msgs:
+		if !ok {
+			e.t.Fatal("session hung up")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		e.t.Fatal("session went quiet")
+	}
+	return message{}
+}
+
+// Sends a request and returns its response, decoding the body into out
+func (e *editor) request(command string, args interface{}, out interface{}) message {
+	e.t.Helper()
+	e.seq++
+	data, err := json.Marshal(map[string]interface{}{
+		"seq": e.seq, "type": "request", "command": command, "arguments": args,
+	})
+	if err != nil {
+		e.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(e.w, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		e.t.Fatal(err)
+	}
+	for {
+		msg := e.read()
+		if msg.Type == "event" {
+			e.events = append(e.events, msg)
+			continue
+		}
+		if msg.RequestSeq != e.seq {
+			e.t.Fatalf("%s: response to request %d", command, msg.RequestSeq)
+		}
+		if out != nil && msg.Success {
+			if err := json.Unmarshal(msg.Body, out); err != nil {
+				e.t.Fatalf("%s: %v", command, err)
+			}
+		}
+		return msg
+	}
+}
+
+// Sends a request that has to succeed
+func (e *editor) must(command string, args interface{}, out interface{}) {
+	e.t.Helper()
+	if msg := e.request(command, args, out); !msg.Success {
+		e.t.Fatalf("%s failed: %s", command, msg.Message)
+	}
+}
+
+// Waits for the next stopped event, and returns why
+func (e *editor) stopped() string {
+	e.t.Helper()
+	for {
+		var msg message
+		if len(e.events) > 0 {
+			msg, e.events = e.events[0], e.events[1:]
+		} else {
+			msg = e.read()
+		}
+		if msg.Type == "event" && msg.Event == "stopped" {
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			json.Unmarshal(msg.Body, &body)
+			return body.Reason
+		}
+	}
+}
+
+// Stack frames, as the session reports them
+type stackTrace struct {
+	StackFrames []struct {
+		Name                        string `json:"name"`
+		Line                        int    `json:"line"`
+		InstructionPointerReference string `json:"instructionPointerReference"`
+	} `json:"stackFrames"`
+}
+
+// Returns the stack frames as name@line, innermost first
+func (e *editor) frames() string {
+	e.t.Helper()
+	var trace stackTrace
+	e.must("stackTrace", map[string]interface{}{"threadId": THREAD_ID}, &trace)
+	var frames []string
+	for _, f := range trace.StackFrames {
+		frames = append(frames, fmt.Sprintf("%s@%d", f.Name, f.Line))
+	}
+	return strings.Join(frames, " ")
+}
+
+// Launches counter with its symbols, stopped on entry
+func (e *editor) launch() {
+	e.t.Helper()
+	dir := e.t.TempDir()
+	rom := filepath.Join(dir, "counter.ch8")
+	syms := filepath.Join(dir, "counter.sym")
+	if err := os.WriteFile(rom, counter, 0644); err != nil {
+		e.t.Fatal(err)
+	}
+	if err := os.WriteFile(syms, []byte(counterSymbols), 0644); err != nil {
+		e.t.Fatal(err)
+	}
+
+	e.must("initialize", map[string]interface{}{"adapterID": "chippy"}, nil)
+	e.must("launch", map[string]interface{}{"program": rom, "symbols": syms, "stopOnEntry": true}, nil)
+}
+
+func TestLaunch(t *testing.T) {
+	e := startSession(t)
+
+	if msg := e.request("launch", map[string]interface{}{"program": "/nowhere.ch8"}, nil); msg.Success {
+		t.Errorf("launched a ROM that isn't there")
+	}
+	if msg := e.request("configurationDone", nil, nil); msg.Success {
+		t.Errorf("configured with nothing launched")
+	}
+
+	e.launch()
+	e.must("configurationDone", nil, nil)
+	if reason := e.stopped(); reason != "entry" {
+		t.Errorf("stopped for %s, want entry", reason)
+	}
+	if frames := e.frames(); frames != "main@1" {
+		t.Errorf("frames = %s, want main@1", frames)
+	}
+
+	if msg := e.request("nope", nil, nil); msg.Success || msg.Message == "" {
+		t.Errorf("unknown request = %+v", msg)
+	}
+	e.must("disconnect", nil, nil)
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	e := startSession(t)
+	e.launch()
+
+	// Line 6 has code, line 4 doesn't
+	var reply struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	e.must("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "/elsewhere/game.8o"},
+		"breakpoints": []map[string]int{{"line": 6}, {"line": 4}},
+	}, &reply)
+	if len(reply.Breakpoints) != 2 {
+		t.Fatalf("breakpoints = %+v", reply.Breakpoints)
+	}
+	if b := reply.Breakpoints[0]; !b.Verified || b.InstructionReference != "0x208" {
+		t.Errorf("line 6 breakpoint = %+v", b)
+	}
+	if b := reply.Breakpoints[1]; b.Verified {
+		t.Errorf("line 4 breakpoint = %+v", b)
+	}
+
+	e.must("configurationDone", nil, nil)
+	e.stopped()
+
+	// Next over 6000 steps, next over the call stops at the breakpoint in it
+	e.must("next", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := e.stopped(); reason != "step" {
+		t.Errorf("next stopped for %s, want step", reason)
+	}
+	if frames := e.frames(); frames != "main+2@2" {
+		t.Errorf("frames after next = %s, want main+2@2", frames)
+	}
+	e.must("next", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := e.stopped(); reason != "breakpoint" {
+		t.Errorf("next over the call stopped for %s, want breakpoint", reason)
+	}
+	if frames := e.frames(); frames != "inc@6 main+2@2" {
+		t.Errorf("frames in inc = %s, want inc@6 main+2@2", frames)
+	}
+
+	// Without the breakpoint, step out of inc and next over the next call
+	e.must("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": "/elsewhere/game.8o"},
+		"breakpoints": []map[string]int{},
+	}, nil)
+	e.must("stepOut", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := e.stopped(); reason != "step" {
+		t.Errorf("stepOut stopped for %s, want step", reason)
+	}
+	if frames := e.frames(); frames != "main+4@3" {
+		t.Errorf("frames after stepOut = %s, want main+4@3", frames)
+	}
+	e.must("next", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	e.stopped()
+	e.must("next", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	e.stopped()
+	if frames := e.frames(); frames != "main+4@3" {
+		t.Errorf("frames after next over the call = %s, want main+4@3", frames)
+	}
+
+	var value struct {
+		Result string `json:"result"`
+	}
+	e.must("evaluate", map[string]string{"expression": "V0"}, &value)
+	if value.Result != "0x02 (2)" {
+		t.Errorf("V0 = %s, want 0x02 (2)", value.Result)
+	}
+
+	// Run, and pause
+	e.must("continue", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	e.must("pause", map[string]interface{}{"threadId": THREAD_ID}, nil)
+	if reason := e.stopped(); reason != "pause" {
+		t.Errorf("pause stopped for %s, want pause", reason)
+	}
+	e.must("disconnect", nil, nil)
+}
+
+func TestMessageSize(t *testing.T) {
+	tests := []string{
+		fmt.Sprintf("Content-Length: %d\r\n\r\n{}", MAX_MESSAGE_SIZE+1),
+		"Content-Length: -1\r\n\r\n",
+		"Content-Length: lots\r\n\r\n",
+		"Content-Length: 2\r\n\r\n{",
+	}
+	for _, input := range tests {
+		if _, err := newConn(strings.NewReader(input), io.Discard).read(); err == nil {
+			t.Errorf("read %q", input)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy"
+	"chippy/pkg/env"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// chippy-env serves a reinforcement-learning environment over stdin and
+// stdout, one JSON object per line, see pkg/env
+func main() {
+	rom := flag.String("rom", "", "Path to CHIP-8 ROM, or builtin:<name> for a bundled ROM")
+	specFile := flag.String("spec", "", "Environment spec (JSON): actions, reward and done watches, frame skip")
+	flag.Parse()
+
+	if err := run(*rom, *specFile); err != nil {
+		// stdout is the protocol, so errors go to stderr
+		fmt.Fprintln(os.Stderr, "chippy-env: "+err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(romPath string, specFile string) error {
+	if romPath == "" {
+		return fmt.Errorf("-rom is required :(")
+	}
+	var rom []byte
+	var err error
+	if strings.HasPrefix(romPath, "builtin:") {
+		rom, err = chippy.ReadBuiltinROM(strings.TrimPrefix(romPath, "builtin:"))
+	} else {
+		rom, err = os.ReadFile(romPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	var spec env.Spec
+	if specFile != "" {
+		if spec, err = env.LoadSpec(specFile); err != nil {
+			return err
+		}
+	}
+
+	e, err := env.New(rom, spec)
+	if err != nil {
+		return err
+	}
+	return env.Serve(e, os.Stdin, os.Stdout)
+}
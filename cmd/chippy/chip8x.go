@@ -0,0 +1,68 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/palette"
+	"image/color"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Returns the CHIP-8X display as one pixel value per byte, and the colour
+// of every pixel from the VP-590 colour zones
+func displayColors(chippy *chip8.Chip8) ([]uint8, []color.RGBA) {
+	pixels := displayPixels(chippy)
+	colors := make([]color.RGBA, len(pixels))
+	bg := palette.VP590[chippy.BackgroundColor()]
+	for i, pixel := range pixels {
+		if pixel == 0 {
+			colors[i] = bg
+		} else {
+			x, y := i%int(chip8.DISPLAY_WIDTH), i/int(chip8.DISPLAY_WIDTH)
+			colors[i] = palette.VP590[chippy.ZoneColor(x, y)]
+		}
+	}
+	return pixels, colors
+}
+
+// Returns the colour behind the CHIP-8 display
+// CHIP-8X has its own, everything else uses the palette's
+func backgroundColor(chippy *chip8.Chip8, pal palette.Palette) sdl.Color {
+	bg := pal.Color(0)
+	if chippy.Platform() == chip8.PLATFORM_CHIP8X {
+		bg = palette.VP590[chippy.BackgroundColor()]
+	}
+	return sdl.Color{R: bg.R, G: bg.G, B: bg.B, A: 255}
+}
+
+// Sends a key event to the CHIP-8X second keypad
+// Returns false if the key isn't on it
+func pressKey2(chippy *chip8.Chip8, t *sdl.KeyboardEvent) bool {
+	for kc, sym := range chippy.KeyMap2() {
+		if t.Keysym.Sym != sym {
+			continue
+		}
+		if t.State == sdl.PRESSED {
+			chippy.KeyPress2(kc)
+		} else {
+			chippy.KeyRelease2(kc)
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/romdb"
+	"chippy/pkg/rominfo"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// chippy info <rom>...
+// Prints a report for each ROM without running it, returns the exit code
+func runInfo(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: chippy info <rom>...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	db, err := romdb.Open()
+	if err != nil {
+		fmt.Println("Failed to open ROM database: " + err.Error())
+	}
+
+	status := 0
+	for _, spec := range fs.Args() {
+		rom, err := readROM(spec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", spec, err)
+			status = 1
+			continue
+		}
+
+		fmt.Printf("%s\n", spec)
+		report := rominfo.Analyze(rom)
+		if db != nil {
+			if known, ok := db.Lookup(report.SHA1); ok {
+				fmt.Printf("Title:     %s\n", known.Title)
+			}
+		}
+		report.Print(os.Stdout)
+		if !report.OK() {
+			status = 1
+		}
+		fmt.Println()
+	}
+	return status
+}
@@ -18,8 +18,17 @@ package main
 import (
 	"chippy/pkg/chip8"
 	"chippy/pkg/debug"
+	"chippy/pkg/filter"
+	"chippy/pkg/logging"
+	"chippy/pkg/movie"
+	"chippy/pkg/netplay"
+	"chippy/pkg/palette"
+	"chippy/pkg/romdb"
+	"chippy/pkg/rominfo"
+	"chippy/pkg/screen"
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
@@ -28,11 +37,237 @@ import (
 func main() {
 	fmt.Println("henlo from chippy <3")
 
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "info" {
+		os.Exit(runInfo(os.Args[2:]))
+	}
+
 	// Get ROM command line argument
-	// TODO: Do some error checking here, how can we only load CHIP-8 roms?
-	rom := flag.String("rom", "./roms/test_opcode.ch8", "Path to CHIP-8 ROM")
+	rom := flag.String("rom", builtinPrefix+"test_opcode", "Path to CHIP-8 ROM (.zip and .gz work too), - for stdin, or builtin:<name> for a bundled ROM")
+	listBuiltin := flag.Bool("list-roms", false, "List the bundled ROMs and exit")
+	flag.String("timing", "fixed", "Instruction timing, fixed (constant IPS) or vip (COSMAC VIP machine cycles)")
+	flag.Int("tickrate", 0, "Instructions per 60Hz frame in fixed timing (default ~8, 500Hz)")
+	flag.String("profile", "modern", fmt.Sprintf("Quirk profile, one of %v", chip8.ProfileNames()))
+	flag.String("platform", "chip8", "Platform the ROM was written for, chip8, chip8x, hires (64x64) or megachip")
+	flag.Bool("display-wait", false, "Sprites wait for the next 60Hz frame before drawing (COSMAC VIP quirk), overrides -profile")
+	flag.Bool("wrap", false, "Sprites wrap around the screen edges instead of clipping, overrides -profile")
+	flag.Bool("machine-code", false, "0NNN runs COSMAC VIP machine code on an emulated 1802, overrides -profile")
+	flag.Bool("shift-vy", false, "8XY6/8XYE shift VY into VX (COSMAC VIP quirk), overrides -profile")
+	flag.Bool("memory-increment", false, "FX55/FX65 leave I at I+X+1 (COSMAC VIP quirk), overrides -profile")
+	flag.Bool("jump-vx", false, "BXNN jumps to XNN+VX (CHIP-48 and SUPER-CHIP quirk), overrides -profile")
+	flag.Bool("reset-vf", false, "8XY1/8XY2/8XY3 reset VF (COSMAC VIP quirk), overrides -profile")
+	flag.String("palette", "classic", fmt.Sprintf("Colour palette, one of %v (P cycles at runtime)", palette.Names()))
+	flag.String("colors", "", "Palette colours, background first, as comma separated hex (#000000,#FFFFFF)")
+	flag.String("keys", "", "Key bindings, as comma separated CHIP-8 key=SDL key name (5=Up,8=Down)")
+	filterSpec := flag.String("filter", "none", "Post-processing: comma separated phosphor, scanlines, gaps, or crt for all of them")
+	scaling := flag.String("scaling", "fit", "Window scaling, fit (keep aspect ratio) or integer (whole pixels only)")
+	persistence := flag.Float64("persistence", filter.DefaultOptions.Persistence, "Phosphor glow kept per frame with -filter phosphor, 0 to 1")
+	save := flag.Bool("save", false, "Save the settings given on the command line for this ROM")
+	record := flag.String("record", "", "Record inputs to a movie file, for deterministic replay")
+	play := flag.String("play", "", "Play back a movie file recorded with -record")
+	headless := flag.Bool("headless", false, "Play the -play movie without a window, as fast as possible, and check the result, run the -debug-listen and -http-listen servers without a window, or run -netplay-frames of netplay without a window")
+	debugListen := flag.String("debug-listen", "", "Serve the remote debug protocol on this address (:2159)")
+	httpListen := flag.String("http-listen", "", "Serve the HTTP control API on this address (localhost:8080)")
+	tracePath := flag.String("trace", "", "Trace every instruction to a file")
+	traceFormat := flag.String("trace-format", "text", "Trace format, text or binary")
+	tracePC := flag.String("trace-pc", "", "Only trace instructions in this hex address range (200-2FF)")
+	traceOps := flag.String("trace-ops", "", "Only trace these opcode classes, comma separated (D,8XY4,FX33)")
+	profileOut := flag.String("profile-out", "", "Profile the ROM, and write a hot-spot report to this file on exit")
+	logLevel := flag.String("log-level", "info", "Emulator log level, one of debug, info, warn, error or off")
+	logFile := flag.String("log-file", "", "Write the emulator log to a file instead of stdout")
+	netHost := flag.String("netplay-host", "", "Host a two-player netplay game on this address (:7000), and wait for a player to join")
+	netJoin := flag.String("netplay-join", "", "Join a netplay game hosted at this address (localhost:7000)")
+	netKeys := flag.String("netplay-keys", "", "CHIP-8 keys we control in netplay, as hex digits (0123), default is every key the other player doesn't take")
+	netDelay := flag.Int("netplay-delay", netplay.DEFAULT_DELAY, "Netplay input delay in frames, the host's is used")
+	netFrames := flag.Uint64("netplay-frames", 600, "Frames to run with -headless netplay, before printing the state hash")
 	flag.Parse()
 
+	if *listBuiltin {
+		listROMs()
+		return
+	}
+
+	// Settings given on the command line override the ROM database
+	cmdSettings, err := flagSettings()
+	if err != nil {
+		panic(err)
+	}
+
+	// Post-processing filter options
+	filterOpts, filtering, err := filter.ParseOptions(*filterSpec, *persistence)
+	if err != nil {
+		panic(err)
+	}
+	filterOpts.Scale = int(chip8.DISPLAY_MODIFIER)
+	scalingMode, err := screen.ParseScaling(*scaling)
+	if err != nil {
+		panic(err)
+	}
+
+	// Send the emulator log where we were asked to
+	level, err := logging.ParseLevel(*logLevel)
+	if err != nil {
+		panic(err)
+	}
+	logOut := os.Stdout
+	if *logFile != "" {
+		logOut, err = os.Create(*logFile)
+		if err != nil {
+			panic(err)
+		}
+		defer logOut.Close()
+	}
+	chip8.SetDefaultLogger(logging.New(logOut, level))
+
+	// Initilaize CHIP-8 and load ROM :3
+	chippy := chip8.Init()
+	romData, err := readROM(*rom)
+	if err != nil {
+		panic(err)
+	}
+
+	// Check the ROM looks like CHIP-8 before running it
+	report := rominfo.Analyze(romData)
+	for _, warning := range report.Warnings {
+		fmt.Println("Warning: " + warning)
+	}
+
+	// Look up settings for this ROM, and apply them
+	// Unknown ROMs get the quirk profile and platform the analysis recommends
+	recommended := romdb.Recommended(report)
+	settings := recommended.Merge(cmdSettings)
+	db, err := romdb.Open()
+	if err != nil {
+		fmt.Println("Failed to open ROM database: " + err.Error())
+	} else {
+		if known, ok := db.Lookup(report.SHA1); ok {
+			fmt.Printf("Found %q in the ROM database <3\n", known.Title)
+			settings = recommended.Merge(known).Merge(cmdSettings)
+		}
+		if *save {
+			if err := db.SaveOverride(report.SHA1, cmdSettings); err != nil {
+				fmt.Println("Failed to save ROM settings: " + err.Error())
+			}
+		}
+	}
+	pal, err := applySettings(&chippy, settings)
+	if err != nil {
+		panic(err)
+	}
+
+	// The platform decides where the ROM goes, and how big it can be
+	size, err := chippy.LoadROMBytes(romData)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Loaded %d bytes! <3\n", size)
+
+	// Load the movie to play back, it brings its own settings
+	var player *movie.Player
+	if *play != "" {
+		m, err := movie.Load(*play)
+		if err != nil {
+			panic(err)
+		}
+		player = movie.NewPlayer(m)
+		if err := player.Setup(&chippy); err != nil {
+			panic(err)
+		}
+	}
+
+	// Trace every instruction, if asked to
+	var tracer *traceFile
+	if *tracePath != "" {
+		tracer, err = openTrace(*tracePath, *traceFormat, *tracePC, *traceOps)
+		if err != nil {
+			panic(err)
+		}
+		defer tracer.Close()
+		chippy.SetTracer(tracer)
+	}
+
+	// Profile execution, H shows the heatmap whether or not we write a report
+	if *profileOut != "" {
+		chippy.SetProfiler(chip8.NewProfiler())
+	}
+
+	// Netplay, the session runs the CHIP-8 and has the keypad
+	var session *netplay.Session
+	if *netHost != "" || *netJoin != "" {
+		if player != nil || *record != "" || *debugListen != "" || *httpListen != "" {
+			panic(fmt.Errorf("netplay doesn't mix with -play, -record, -debug-listen or -http-listen :("))
+		}
+		session, err = startNetplay(&chippy, *netHost, *netJoin, *netKeys, *netDelay)
+		if err != nil {
+			panic(err)
+		}
+		defer session.Close()
+	}
+
+	// Headless netplay, run a fixed number of frames so both sides can
+	// compare where they ended up
+	if *headless && session != nil {
+		if err := session.Run(*netFrames); err != nil {
+			fmt.Println("Netplay failed: " + err.Error())
+			tracer.Close()
+			os.Exit(1)
+		}
+		printNetplayStats(session)
+		writeProfile(*profileOut, &chippy)
+		fmt.Printf("State hash after %d frames: %016x\n", chippy.Frame(), chippy.StateHash())
+		return
+	}
+
+	// Remote control, over the debug protocol and HTTP
+	// Movies only record the keypad, anything else the servers did to the
+	// CHIP-8 would be missing from the recording
+	if *record != "" && (*debugListen != "" || *httpListen != "") {
+		panic(fmt.Errorf("-record doesn't mix with -debug-listen or -http-listen :("))
+	}
+	rem, err := startRemote(&chippy, *debugListen, *httpListen)
+	if err != nil {
+		panic(err)
+	}
+
+	// Headless remote control, the servers drive the CHIP-8 until we're interrupted
+	if *headless && rem.enabled() && player == nil {
+		rem.runHeadless()
+		writeProfile(*profileOut, &chippy)
+		fmt.Println("kthxbai<3")
+		return
+	}
+
+	// Headless playback, no SDL needed
+	if *headless {
+		if player == nil {
+			panic(fmt.Errorf("-headless needs a movie to -play :("))
+		}
+		if err := movie.Replay(&chippy, player.Movie()); err != nil {
+			fmt.Println("Replay failed: " + err.Error())
+			tracer.Close()
+			writeProfile(*profileOut, &chippy)
+			os.Exit(1)
+		}
+		writeProfile(*profileOut, &chippy)
+		fmt.Printf("Replayed %d frames, display matches <3\n", chippy.Frame())
+		return
+	}
+
+	// Where key presses go, recording them on the way if we need to
+	var keys keypad = &chippy
+	var recorder *movie.Recorder
+	if *record != "" {
+		recorder = movie.NewRecorder(&chippy)
+		keys = recorder
+	}
+	if player != nil {
+		// The movie has the keypad while it plays
+		keys = ignoreKeys{}
+	}
+	if session != nil {
+		keys = session
+	}
+
 	// Initialize SDL2
 	fmt.Println("Initializing SDL2...")
 	if err := sdl.Init(sdl.INIT_EVERYTHING); err != nil {
@@ -42,15 +277,16 @@ func main() {
 
 	// Initialize SDL2 TTF
 	fmt.Println("Initializing SDL2 TTF...")
-	err := ttf.Init()
+	err = ttf.Init()
 	if err != nil {
 		fmt.Println("Failed to initialize TTF: " + err.Error())
 	}
 
-	// Create SDL2 window
+	// Create SDL2 window, HIRES CHIP-8 gets a square one
+	height := chippy.DisplayHeight()
 	window, err := sdl.CreateWindow("chippy <3", sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
-		chip8.DISPLAY_WIDTH*chip8.DISPLAY_MODIFIER, chip8.DISPLAY_HEIGHT*chip8.DISPLAY_MODIFIER,
-		sdl.WINDOW_SHOWN)
+		chip8.DISPLAY_WIDTH*chip8.DISPLAY_MODIFIER, height*chip8.DISPLAY_MODIFIER,
+		sdl.WINDOW_SHOWN|sdl.WINDOW_RESIZABLE)
 	if err != nil {
 		panic(err)
 	}
@@ -63,60 +299,164 @@ func main() {
 	}
 	defer renderer.Destroy()
 
-	// Initilaize CHIP-8 and load ROM :3
-	chippy := chip8.Init()
-	size, err := chippy.LoadROM(*rom)
+	// Create the screen, with the post-processing filter if we have one
+	var filt *filter.Filter
+	if filtering {
+		filt = filter.New(filterOpts, int(chip8.DISPLAY_WIDTH), int(height))
+	}
+	scr, err := screen.New(renderer, int(chip8.DISPLAY_WIDTH), int(height), filt, scalingMode)
 	if err != nil {
 		panic(err)
 	}
-	fmt.Printf("Loaded %d bytes! <3\n", size)
+	defer scr.Destroy()
+
+	// MegaChip plays digitised sound
+	var sound *audio
+	if chippy.Platform() == chip8.PLATFORM_MEGACHIP {
+		if sound, err = openAudio(); err != nil {
+			fmt.Println("Failed to open audio: " + err.Error())
+		} else {
+			defer sound.Close()
+		}
+	}
+
+	// Create the debug overlay
+	overlay, err := debug.NewOverlay(renderer)
+	if err != nil {
+		fmt.Println("Failed to create debug overlay: " + err.Error())
+	} else {
+		defer overlay.Close()
+	}
+
+	// Create the profiler heatmap
+	heatmap, err := debug.NewHeatmap(renderer)
+	if err != nil {
+		fmt.Println("Failed to create heatmap: " + err.Error())
+	} else {
+		defer heatmap.Destroy()
+	}
+
+	// Create the memory view
+	memView, err := debug.NewMemoryView(renderer)
+	if err != nil {
+		fmt.Println("Failed to create memory view: " + err.Error())
+	} else {
+		defer memView.Close()
+	}
 
 	// Emulator loop
 	emulating := true
-	displayOverlay := true
+	displayOverlay := overlay != nil
+	displayHeatmap := false
+	displayMemory := false
+	paused := false
+	upload := true
+	present := true
 	for emulating {
-		// CHIP-8 CPU Cycle (Fetch/Decode/Execute)
-		chippy.Cycle()
+		frameStart := sdl.GetTicks()
 
-		// Update debug overlay
-		var overlay *sdl.Texture
-		if displayOverlay {
-			overlay = debug.RenderOverlay(&chippy, renderer)
-		}
+		// Keep the remote servers off the CHIP-8 while we use it
+		rem.Lock()
 
-		// Render CHIP-8 Screen
-		// NOTE: Might need to keep track of when to draw to prevent flickering,
-		//       instead of rendering every frame / cycle.
-		renderer.SetDrawColor(0, 0, 0, 255)
-		renderer.Clear()
-
-		buff := chippy.DisplayBuffer()
-		for h := 0; h < len(buff); h++ {
-			for w := 0; w < len(buff[h]); w++ {
-				// CHIP-8 pixels are colored based on 1 or 0
-				if buff[h][w] != 0 {
-					renderer.SetDrawColor(255, 255, 255, 255)
+		// Play back the movie's inputs for this frame
+		if player != nil && !paused {
+			if player.Done(&chippy) {
+				if m := player.Movie(); m.Display != "" && m.Display != movie.DisplayHash(&chippy) {
+					fmt.Println("Movie finished, but the display differs from the recording :(")
 				} else {
-					renderer.SetDrawColor(0, 0, 0, 255)
+					fmt.Println("Movie finished <3")
 				}
+				player = nil
+				keys = &chippy
+			} else {
+				player.Feed(&chippy)
+			}
+		}
 
-				// Render, keeping our display scaling in mind
-				renderer.FillRect(&sdl.Rect{
-					Y: int32(h) * chip8.DISPLAY_MODIFIER,
-					X: int32(w) * chip8.DISPLAY_MODIFIER,
-					W: chip8.DISPLAY_MODIFIER,
-					H: chip8.DISPLAY_MODIFIER,
-				})
+		// CHIP-8 CPU Cycles (Fetch/Decode/Execute) for one 60Hz frame
+		if !paused {
+			frame := chippy.Frame()
+			if session != nil {
+				// Netplay runs the frame, unless we have to wait for the other player
+				if _, err := session.Advance(); err != nil {
+					fmt.Println("Netplay stopped: " + err.Error())
+					emulating = false
+				}
+			} else {
+				rem.runFrame()
+			}
+			if filtering && chippy.Frame() > frame {
+				filt.Advance(chippy.Frame() - frame)
+			}
+			if sound != nil {
+				if err := sound.queue(&chippy); err != nil {
+					fmt.Println("Failed to queue audio: " + err.Error())
+				}
+			}
+			if displayMemory {
+				present = true
+			}
+		}
+
+		// MegaChip changes display size when it switches mode
+		if resized, err := resizeDisplay(&chippy, window, scr); err != nil {
+			fmt.Println("Failed to resize screen: " + err.Error())
+		} else if resized {
+			upload = true
+		}
+
+		// Upload the CHIP-8 Screen when it changed
+		// Phosphor glow keeps fading after the display stops changing
+		// CHIP-8X colours every pixel from its colour zones, and MegaChip
+		// brings its own true colour frames
+		if chippy.DisplayChanged() || upload || filterOpts.Persistence > 0 {
+			if chippy.MegaChip() {
+				pixels, colors, bg := megaColors(&chippy)
+				err = scr.UpdateColors(pixels, colors, bg)
+			} else if chippy.Platform() == chip8.PLATFORM_CHIP8X {
+				pixels, colors := displayColors(&chippy)
+				err = scr.UpdateColors(pixels, colors, palette.VP590[chippy.BackgroundColor()])
+			} else {
+				err = scr.Update(displayPixels(&chippy), pal)
+			}
+			if err != nil {
+				fmt.Println("Failed to update screen: " + err.Error())
 			}
+			upload = false
+			present = true
 		}
 
-		// Copy debug overlay to renderer
-		if displayOverlay {
-			renderer.Copy(overlay, nil, &sdl.Rect{X: 0, Y: 0, W: 100, H: 100})
+		// Update debug overlay
+		if displayOverlay && overlay.Update(&chippy) {
+			present = true
+		}
+
+		// Update the heatmap, it changes every frame
+		if displayHeatmap {
+			if err := heatmap.Update(chippy.Profiler(), chippy.PC()); err != nil {
+				fmt.Println("Failed to update heatmap: " + err.Error())
+			}
+			present = true
 		}
 
 		// Render to screen <3
-		renderer.Present()
+		if present {
+			scr.Draw(backgroundColor(&chippy, pal))
+
+			// Copy debug overlay and heatmap to renderer
+			if displayHeatmap {
+				heatmap.Draw()
+			}
+			if displayMemory {
+				memView.Draw(&chippy, paused)
+			}
+			if displayOverlay {
+				overlay.Draw()
+			}
+
+			renderer.Present()
+			present = false
+		}
 
 		// Event handling
 		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
@@ -125,135 +465,235 @@ func main() {
 				println("kthxbai<3")
 				emulating = false
 
+			case *sdl.WindowEvent:
+				// Resized or uncovered, draw everything again
+				present = true
+
 			case *sdl.KeyboardEvent:
+				// The memory view has the keyboard while paused, unless we're
+				// recording, edits aren't part of the movie
+				if displayMemory && paused && recorder == nil && t.State == sdl.PRESSED && memView.HandleKey(&chippy, t.Keysym.Sym) {
+					present = true
+					break
+				}
+
+				// CHIP-8X has a second keypad
+				if chippy.Platform() == chip8.PLATFORM_CHIP8X && pressKey2(&chippy, t) {
+					break
+				}
+
 				switch t.Keysym.Sym {
 				case sdl.K_ESCAPE:
 					println("kthxbai<3")
 					emulating = false
 
 				case sdl.K_LALT:
-					if t.State == sdl.PRESSED {
+					if t.State == sdl.PRESSED && overlay != nil {
 						displayOverlay = !displayOverlay
+						present = true
+					}
+
+				case sdl.K_h:
+					if t.State == sdl.PRESSED && heatmap != nil {
+						// Start profiling the first time the heatmap is shown
+						if chippy.Profiler() == nil {
+							chippy.SetProfiler(chip8.NewProfiler())
+						}
+						displayHeatmap = !displayHeatmap
+						present = true
+					}
+
+				case sdl.K_m:
+					if t.State == sdl.PRESSED && memView != nil {
+						displayMemory = !displayMemory
+						present = true
+					}
+
+				case sdl.K_F5:
+					if t.State == sdl.PRESSED {
+						paused = !paused
+						if paused {
+							window.SetTitle("chippy <3 (paused)")
+						} else {
+							window.SetTitle("chippy <3")
+						}
+						present = true
+					}
+
+				case sdl.K_F10:
+					// Step one instruction while paused, netplay would fall out of
+					// sync and movies only record whole frames
+					if t.State == sdl.PRESSED && paused && session == nil && recorder == nil {
+						chippy.Step()
+						upload = true
+					}
+
+				case sdl.K_p:
+					if t.State == sdl.PRESSED {
+						pal = palette.Next(pal)
+						upload = true
+						fmt.Printf("Palette: %s\n", pal.Name)
 					}
 
 				case chippy.KeyMap()[0x0]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x0)
+						keys.KeyPress(0x0)
 					} else {
-						chippy.KeyRelease(0x0)
+						keys.KeyRelease(0x0)
 					}
 
 				case chippy.KeyMap()[0x1]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x1)
+						keys.KeyPress(0x1)
 					} else {
-						chippy.KeyRelease(0x1)
+						keys.KeyRelease(0x1)
 					}
 
 				case chippy.KeyMap()[0x2]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x2)
+						keys.KeyPress(0x2)
 					} else {
-						chippy.KeyRelease(0x2)
+						keys.KeyRelease(0x2)
 					}
 
 				case chippy.KeyMap()[0x3]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x3)
+						keys.KeyPress(0x3)
 					} else {
-						chippy.KeyRelease(0x3)
+						keys.KeyRelease(0x3)
 					}
 
 				case chippy.KeyMap()[0x4]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x4)
+						keys.KeyPress(0x4)
 					} else {
-						chippy.KeyRelease(0x4)
+						keys.KeyRelease(0x4)
 					}
 
 				case chippy.KeyMap()[0x5]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x5)
+						keys.KeyPress(0x5)
 					} else {
-						chippy.KeyRelease(0x5)
+						keys.KeyRelease(0x5)
 					}
 
 				case chippy.KeyMap()[0x6]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x6)
+						keys.KeyPress(0x6)
 					} else {
-						chippy.KeyRelease(0x6)
+						keys.KeyRelease(0x6)
 					}
 
 				case chippy.KeyMap()[0x7]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x7)
+						keys.KeyPress(0x7)
 					} else {
-						chippy.KeyRelease(0x7)
+						keys.KeyRelease(0x7)
 					}
 
 				case chippy.KeyMap()[0x8]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x8)
+						keys.KeyPress(0x8)
 					} else {
-						chippy.KeyRelease(0x8)
+						keys.KeyRelease(0x8)
 					}
 
 				case chippy.KeyMap()[0x9]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0x9)
+						keys.KeyPress(0x9)
 					} else {
-						chippy.KeyRelease(0x9)
+						keys.KeyRelease(0x9)
 					}
 
 				case chippy.KeyMap()[0xA]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xA)
+						keys.KeyPress(0xA)
 					} else {
-						chippy.KeyRelease(0xA)
+						keys.KeyRelease(0xA)
 					}
 
 				case chippy.KeyMap()[0xB]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xB)
+						keys.KeyPress(0xB)
 					} else {
-						chippy.KeyRelease(0xB)
+						keys.KeyRelease(0xB)
 					}
 
 				case chippy.KeyMap()[0xC]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xC)
+						keys.KeyPress(0xC)
 					} else {
-						chippy.KeyRelease(0xC)
+						keys.KeyRelease(0xC)
 					}
 
 				case chippy.KeyMap()[0xD]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xD)
+						keys.KeyPress(0xD)
 					} else {
-						chippy.KeyRelease(0xD)
+						keys.KeyRelease(0xD)
 					}
 
 				case chippy.KeyMap()[0xE]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xE)
+						keys.KeyPress(0xE)
 					} else {
-						chippy.KeyRelease(0xE)
+						keys.KeyRelease(0xE)
 					}
 
 				case chippy.KeyMap()[0xF]:
 					if t.State == sdl.PRESSED {
-						chippy.KeyPress(0xF)
+						keys.KeyPress(0xF)
 					} else {
-						chippy.KeyRelease(0xF)
+						keys.KeyRelease(0xF)
 					}
 				}
 			}
 		}
 
-		// Maintain CHIP-8 Clock Speed, this is ~500hz by default
-		// TODO: Make clock speed adjustable
-		// TODO: Make sure display is updating at 60hz (60 FPS)?
-		sdl.Delay(1000 / chippy.ClockSpeed())
+		rem.Unlock()
+
+		// Maintain 60Hz, the CHIP-8 timing mode decides how much
+		// work happens within each frame
+		if elapsed := sdl.GetTicks() - frameStart; elapsed < 1000/chip8.FRAME_RATE {
+			sdl.Delay(1000/chip8.FRAME_RATE - elapsed)
+		}
+	}
+
+	// Save the profile report
+	writeProfile(*profileOut, &chippy)
+
+	if session != nil {
+		printNetplayStats(session)
+	}
+
+	// Save the recording
+	if recorder != nil {
+		if err := recorder.Finish().Save(*record); err != nil {
+			fmt.Println("Failed to save movie: " + err.Error())
+		} else {
+			fmt.Printf("Saved movie to %s <3\n", *record)
+		}
+	}
+}
+
+// Returns the CHIP-8 display as one pixel value per byte, row by row
+func displayPixels(chippy *chip8.Chip8) []uint8 {
+	buff := chippy.DisplayBuffer()
+	pixels := make([]uint8, 0, int(chip8.DISPLAY_WIDTH)*len(buff))
+	for h := 0; h < len(buff); h++ {
+		pixels = append(pixels, buff[h][:]...)
 	}
+	return pixels
 }
+
+// Somewhere to send CHIP-8 key presses
+type keypad interface {
+	KeyPress(kc int)
+	KeyRelease(kc int)
+}
+
+// Drops key presses, for when a movie has the keypad
+type ignoreKeys struct{}
+
+func (ignoreKeys) KeyPress(kc int)   {}
+func (ignoreKeys) KeyRelease(kc int) {}
@@ -0,0 +1,97 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/screen"
+	"image/color"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Sample rate for MegaChip's digitised sound
+const AUDIO_RATE = 44100
+
+// Most audio we let SDL queue up, in bytes, before dropping frames of it
+// A quarter of a second, so a slow frame doesn't pile up lag
+const AUDIO_QUEUE = AUDIO_RATE / 4
+
+// Returns the size of the display the CHIP-8 is showing, in CHIP-8 pixels
+func displaySize(chippy *chip8.Chip8) (int, int) {
+	if chippy.MegaChip() {
+		return chip8.MEGA_WIDTH, chip8.MEGA_HEIGHT
+	}
+	return int(chip8.DISPLAY_WIDTH), int(chippy.DisplayHeight())
+}
+
+// Returns the MegaChip display as lit pixels, for the filter, the colour of
+// every pixel, and the black behind them
+func megaColors(chippy *chip8.Chip8) ([]uint8, []color.RGBA, color.RGBA) {
+	fb := chippy.Framebuffer()
+	pixels := make([]uint8, len(fb.Pixels))
+	for i, p := range fb.Pixels {
+		if p.R != 0 || p.G != 0 || p.B != 0 {
+			pixels[i] = 1
+		}
+	}
+	return pixels, fb.Pixels, color.RGBA{A: 0xFF}
+}
+
+// Resizes the screen to the display the CHIP-8 is showing, if it changed
+// The window stays as wide and takes the display's shape
+// Returns true if it had to
+func resizeDisplay(chippy *chip8.Chip8, window *sdl.Window, scr *screen.Screen) (bool, error) {
+	width, height := displaySize(chippy)
+	if w, h := scr.Size(); w == width && h == height {
+		return false, nil
+	}
+	if err := scr.Resize(width, height); err != nil {
+		return false, err
+	}
+	ww := chip8.DISPLAY_WIDTH * chip8.DISPLAY_MODIFIER
+	window.SetSize(ww, ww*int32(height)/int32(width))
+	return true, nil
+}
+
+// Plays MegaChip's digitised sound
+type audio struct {
+	device sdl.AudioDeviceID
+}
+
+// Opens the default audio device for MegaChip sound
+func openAudio() (*audio, error) {
+	spec := sdl.AudioSpec{Freq: AUDIO_RATE, Format: sdl.AUDIO_U8, Channels: 1, Samples: 1024}
+	device, err := sdl.OpenAudioDevice("", false, &spec, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	sdl.PauseAudioDevice(device, false)
+	return &audio{device: device}, nil
+}
+
+// Queues the sound from the frame the CHIP-8 just ran
+func (a *audio) queue(chippy *chip8.Chip8) error {
+	if sdl.GetQueuedAudioSize(a.device) > AUDIO_QUEUE {
+		return nil
+	}
+	return sdl.QueueAudio(a.device, chippy.FrameSamples(AUDIO_RATE))
+}
+
+// Closes the audio device
+func (a *audio) Close() {
+	sdl.CloseAudioDevice(a.device)
+}
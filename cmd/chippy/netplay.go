@@ -0,0 +1,66 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/netplay"
+	"fmt"
+	"net"
+)
+
+// Starts netplay, hosting on one address or joining the other
+func startNetplay(chippy *chip8.Chip8, host string, join string, keys string, delay int) (*netplay.Session, error) {
+	if host != "" && join != "" {
+		return nil, fmt.Errorf("-netplay-host or -netplay-join, not both :(")
+	}
+	mask, err := netplay.ParseKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	opts := netplay.Options{Keys: mask, Delay: delay}
+
+	var session *netplay.Session
+	if host != "" {
+		l, err := net.Listen("tcp", host)
+		if err != nil {
+			return nil, err
+		}
+		defer l.Close()
+		fmt.Printf("Waiting for a player to join on %s...\n", l.Addr())
+		session, err = netplay.Host(l, chippy, opts)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fmt.Printf("Joining %s...\n", join)
+		session, err = netplay.Join(join, chippy, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Printf("Netplay started! We have keys %s, they have keys %s, %d frames of input delay <3\n",
+		netplay.FormatKeys(session.LocalKeys()), netplay.FormatKeys(session.RemoteKeys()), session.Delay())
+	return session, nil
+}
+
+// Prints what the netplay session got up to
+func printNetplayStats(session *netplay.Session) {
+	stats := session.Stats()
+	fmt.Printf("Netplay: %d frames, %d rollbacks (%d frames run again), %d stalls\n",
+		stats.Frames, stats.Rollbacks, stats.Resimulated, stats.Stalls)
+}
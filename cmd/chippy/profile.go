@@ -0,0 +1,41 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/profiler"
+	"fmt"
+	"os"
+)
+
+// Writes the CHIP-8's profile report to a file, if it was being profiled
+func writeProfile(path string, chippy *chip8.Chip8) {
+	p := chippy.Profiler()
+	if path == "" || p == nil {
+		return
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		fmt.Println("Failed to write profile: " + err.Error())
+		return
+	}
+	defer f.Close()
+
+	profiler.WriteReport(f, p, chippy.Timing())
+	fmt.Printf("Saved profile to %s <3\n", path)
+}
@@ -0,0 +1,102 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/debugserver"
+	"chippy/pkg/httpapi"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// The servers that drive the CHIP-8 from their own goroutines
+// They share one lock with the emulator loop, hold it while touching the CHIP-8
+type remote struct {
+	sync.Mutex
+
+	chip     *chip8.Chip8
+	debugger *debugserver.Server
+	api      *httpapi.Server
+}
+
+// Starts the debug and HTTP servers on the addresses given, empty skips one
+func startRemote(c *chip8.Chip8, debugAddr string, httpAddr string) (*remote, error) {
+	r := &remote{chip: c}
+
+	if debugAddr != "" {
+		l, err := net.Listen("tcp", debugAddr)
+		if err != nil {
+			return nil, err
+		}
+		r.debugger = debugserver.New(c, r)
+		go r.debugger.Serve(l)
+		fmt.Printf("Debug server listening on %s\n", l.Addr())
+	}
+
+	if httpAddr != "" {
+		l, err := net.Listen("tcp", httpAddr)
+		if err != nil {
+			return nil, err
+		}
+		r.api = httpapi.New(c, r)
+		go r.api.Serve(l)
+		fmt.Printf("HTTP API listening on http://%s\n", l.Addr())
+	}
+
+	return r, nil
+}
+
+// Returns true if any server is running
+func (r *remote) enabled() bool {
+	return r.debugger != nil || r.api != nil
+}
+
+// Runs the CHIP-8 for one 60Hz frame, unless a server has it paused
+// Call with the lock held
+func (r *remote) runFrame() {
+	switch {
+	case r.api != nil && r.api.Paused():
+	case r.debugger != nil:
+		r.debugger.RunFrame()
+	default:
+		r.chip.RunFrame()
+	}
+}
+
+// Runs the CHIP-8 at 60Hz without a window, until interrupted
+func (r *remote) runHeadless() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	ticker := time.NewTicker(time.Second / chip8.FRAME_RATE)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupt:
+			return
+		case <-ticker.C:
+			r.Lock()
+			r.runFrame()
+			r.Unlock()
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"archive/zip"
+	"bytes"
+	"chippy"
+	"chippy/pkg/chip8"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Prefix for ROMs bundled into the binary, like builtin:ibm_logo
+const builtinPrefix = "builtin:"
+
+// Largest ROM read from the command line, MegaChip's memory
+// The platform isn't known until the ROM is read, LoadROMBytes checks the
+// ROM fits the one it runs on.
+const maxROMSize = chip8.MEGA_MEMORY_SIZE
+
+// File extensions used for ROMs, to pick the ROM out of a zip archive
+var romExtensions = map[string]bool{
+	".ch8": true,
+	".c8":  true,
+	".sc8": true,
+	".xo8": true,
+	".c8x": true,
+	".mc8": true,
+}
+
+// Reads the whole ROM named on the command line
+// This is a path, a .zip or .gz archive, - for stdin, or a bundled ROM
+func readROM(spec string) ([]byte, error) {
+	rom, err := openROM(spec)
+	if err != nil {
+		return nil, err
+	}
+	defer rom.Close()
+
+	// Read one byte more than fits, so archives and stdin can't fill memory
+	data, err := io.ReadAll(io.LimitReader(rom, maxROMSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxROMSize {
+		return nil, fmt.Errorf("ROM %s is too large to fit in memory :(", spec)
+	}
+	return data, nil
+}
+
+// Opens the ROM named on the command line, see readROM
+func openROM(spec string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasPrefix(spec, builtinPrefix):
+		rom, err := chippy.ReadBuiltinROM(strings.TrimPrefix(spec, builtinPrefix))
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(rom)), nil
+
+	case spec == "-":
+		return io.NopCloser(os.Stdin), nil
+
+	case strings.EqualFold(filepath.Ext(spec), ".gz"):
+		f, err := os.Open(spec)
+		if err != nil {
+			return nil, err
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return &archiveReader{ReadCloser: gz, archive: f}, nil
+
+	case strings.EqualFold(filepath.Ext(spec), ".zip"):
+		return openZipROM(spec)
+
+	default:
+		return os.Open(spec)
+	}
+}
+
+// A ROM inside an archive, closing the ROM closes the archive too
+type archiveReader struct {
+	io.ReadCloser
+	archive io.Closer
+}
+
+// Closes the ROM and the archive it came from
+func (a *archiveReader) Close() error {
+	err := a.ReadCloser.Close()
+	if aerr := a.archive.Close(); err == nil {
+		err = aerr
+	}
+	return err
+}
+
+// Opens the ROM inside a zip archive
+// The archive should hold a single ROM, or a single file with a ROM extension
+func openZipROM(file string) (io.ReadCloser, error) {
+	archive, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the candidates, ignoring directories
+	var files, roms []*zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, f)
+		if romExtensions[strings.ToLower(path.Ext(f.Name))] {
+			roms = append(roms, f)
+		}
+	}
+	if len(roms) == 0 && len(files) == 1 {
+		roms = files
+	}
+	if len(roms) != 1 {
+		archive.Close()
+		return nil, fmt.Errorf("expected one ROM in %s, found %d :(", file, len(roms))
+	}
+
+	rom, err := roms[0].Open()
+	if err != nil {
+		archive.Close()
+		return nil, err
+	}
+	fmt.Printf("Loading %s from %s...\n", roms[0].Name, file)
+	return &archiveReader{ReadCloser: rom, archive: archive}, nil
+}
+
+// Prints the catalogue of bundled ROMs
+func listROMs() {
+	fmt.Println("Builtin ROMs, run with -rom builtin:<name>")
+	for _, rom := range chippy.BuiltinROMs() {
+		fmt.Printf("  %-16s %s\n", rom.Name, rom.Description)
+		if rom.Source != "" {
+			fmt.Printf("  %-16s %s\n", "", rom.Source)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/palette"
+	"chippy/pkg/romdb"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Builds ROM settings from the flags given on the command line
+// Flags left at their defaults have no opinion, so the ROM database wins
+func flagSettings() (romdb.Settings, error) {
+	var s romdb.Settings
+	var err error
+	flag.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch f.Name {
+		case "timing":
+			s.Timing = value
+		case "tickrate":
+			s.TickRate, _ = strconv.Atoi(value)
+		case "profile":
+			s.Profile = value
+		case "platform":
+			s.Platform = value
+		case "display-wait":
+			s.Quirks = setQuirk(s.Quirks, "vblank", value == "true")
+		case "wrap":
+			s.Quirks = setQuirk(s.Quirks, "wrap", value == "true")
+		case "machine-code":
+			s.Quirks = setQuirk(s.Quirks, "machineCode", value == "true")
+		case "shift-vy":
+			s.Quirks = setQuirk(s.Quirks, "shift", value != "true")
+		case "memory-increment":
+			s.Quirks = setQuirk(s.Quirks, "memoryLeaveIUnchanged", value != "true")
+		case "jump-vx":
+			s.Quirks = setQuirk(s.Quirks, "jump", value == "true")
+		case "reset-vf":
+			s.Quirks = setQuirk(s.Quirks, "logic", value == "true")
+		case "palette":
+			s.Palette = value
+		case "colors":
+			s.Colors = strings.Split(value, ",")
+		case "keys":
+			s.Keys = map[string]string{}
+			for _, binding := range strings.Split(value, ",") {
+				kv := strings.SplitN(binding, "=", 2)
+				if len(kv) != 2 {
+					err = fmt.Errorf("bad key binding %q, expected key=name :(", binding)
+					return
+				}
+				s.Keys[strings.ToUpper(kv[0])] = kv[1]
+			}
+		}
+	})
+	return s, err
+}
+
+// Returns the quirk map with the given quirk set
+func setQuirk(quirks map[string]bool, name string, on bool) map[string]bool {
+	if quirks == nil {
+		quirks = map[string]bool{}
+	}
+	quirks[name] = on
+	return quirks
+}
+
+// Applies ROM settings to the CHIP-8, and returns the palette to draw with
+func applySettings(chippy *chip8.Chip8, s romdb.Settings) (palette.Palette, error) {
+	pal := palette.Presets[0]
+
+	// Timing and quirks
+	if err := s.Apply(chippy); err != nil {
+		return pal, err
+	}
+
+	// Key bindings
+	for key, name := range s.Keys {
+		kc, err := strconv.ParseUint(key, 16, 8)
+		if err != nil || kc > 0xF {
+			return pal, fmt.Errorf("bad CHIP-8 key %q :(", key)
+		}
+		sym := sdl.GetKeyFromName(name)
+		if sym == sdl.K_UNKNOWN {
+			return pal, fmt.Errorf("unknown SDL key %q :(", name)
+		}
+		chippy.SetKey(int(kc), sym)
+	}
+
+	// Colours
+	if s.Palette != "" {
+		var err error
+		if pal, err = palette.Lookup(s.Palette); err != nil {
+			return pal, err
+		}
+	}
+	if len(s.Colors) > 0 {
+		return pal.WithColors(s.Colors)
+	}
+
+	return pal, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/trace"
+	"fmt"
+	"os"
+)
+
+// An instruction trace being written to a file
+type traceFile struct {
+	*trace.Writer
+	file *os.File
+}
+
+// Creates the trace file, with the given format and filters
+func openTrace(path string, format string, pcRange string, classes string) (*traceFile, error) {
+	f, err := trace.ParseFormat(format)
+	if err != nil {
+		return nil, err
+	}
+	var filt trace.Filter
+	if pcRange != "" {
+		if err := filt.ParseRange(pcRange); err != nil {
+			return nil, err
+		}
+	}
+	if err := filt.ParseClasses(classes); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &traceFile{Writer: trace.NewWriter(file, f, filt), file: file}, nil
+}
+
+// Flushes and closes the trace file, safe to call on a nil trace
+func (t *traceFile) Close() {
+	if t == nil {
+		return
+	}
+	if err := t.Flush(); err != nil {
+		fmt.Println("Failed to write trace: " + err.Error())
+	}
+	t.file.Close()
+}
@@ -0,0 +1,120 @@
+package chippy
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Fonts bundled into the binary, so chippy runs from any directory
+//
+//go:embed fonts/VT323.ttf
+var Fonts embed.FS
+
+// ROMs bundled into the binary, with the README that describes them
+//
+//go:embed roms/*.ch8 roms/README.md
+var ROMs embed.FS
+
+// A ROM bundled into the binary
+type BuiltinROM struct {
+	// Name used with builtin:, the file name without .ch8
+	Name string
+
+	// Description from roms/README.md
+	Description string
+
+	// Where the ROM came from, from roms/README.md
+	Source string
+}
+
+// Returns the debug overlay font, VT323
+func OverlayFont() []byte {
+	font, err := Fonts.ReadFile("fonts/VT323.ttf")
+	if err != nil {
+		// Embedded at build time, this can't happen
+		panic(err)
+	}
+	return font
+}
+
+// Returns the bundled ROM with the given name, like ibm_logo
+func ReadBuiltinROM(name string) ([]byte, error) {
+	rom, err := ROMs.ReadFile(path.Join("roms", name+".ch8"))
+	if err != nil {
+		return nil, fmt.Errorf("no builtin ROM named %q, try -list-roms :(", name)
+	}
+	return rom, nil
+}
+
+// Returns the catalogue of bundled ROMs, sorted by name
+func BuiltinROMs() []BuiltinROM {
+	files, _ := ROMs.ReadDir("roms")
+	info := readmeInfo()
+
+	var roms []BuiltinROM
+	for _, f := range files {
+		if path.Ext(f.Name()) != ".ch8" {
+			continue
+		}
+		rom := info[f.Name()]
+		rom.Name = strings.TrimSuffix(f.Name(), ".ch8")
+		roms = append(roms, rom)
+	}
+	sort.Slice(roms, func(i, j int) bool { return roms[i].Name < roms[j].Name })
+	return roms
+}
+
+// Parses roms/README.md, mapping file names to their description and source
+// Entries look like:
+//
+//	`ibm_logo.ch8` - Test ROM that displays the IBM logo.
+//	https://github.com/...
+func readmeInfo() map[string]BuiltinROM {
+	info := map[string]BuiltinROM{}
+	readme, err := ROMs.ReadFile("roms/README.md")
+	if err != nil {
+		return info
+	}
+
+	last := ""
+	scanner := bufio.NewScanner(bytes.NewReader(readme))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "`"):
+			parts := strings.SplitN(line, " - ", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			last = strings.Trim(parts[0], "` ")
+			info[last] = BuiltinROM{Description: parts[1]}
+
+		case strings.HasPrefix(line, "http") && last != "":
+			rom := info[last]
+			rom.Source = line
+			info[last] = rom
+			last = ""
+		}
+	}
+	return info
+}
@@ -0,0 +1,423 @@
+package cdp1802
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// RCA CDP1802 CPU, as found in the COSMAC VIP
+// The 1802 has 16 16-bit registers, any of which can be the program counter
+// (picked by P) or the data pointer (picked by X), an 8-bit accumulator D
+// and a carry flag DF. Every instruction takes 2 machine cycles, except the
+// long branches and skips which take 3.
+
+// Machine cycles for most instructions, and for long branches and skips
+const CYCLES_SHORT = 2
+const CYCLES_LONG = 3
+
+// Bus is the memory the CPU runs against
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+}
+
+// CPU is the state of an 1802
+// The zero value is a CPU just out of reset, running from R0
+type CPU struct {
+	// Scratchpad registers R0-RF
+	r [16]uint16
+
+	// Which register is the program counter, and which the data pointer
+	p uint8
+	x uint8
+
+	// Accumulator and carry (1 if set)
+	d  uint8
+	df uint8
+
+	// X and P saved by MARK and interrupts
+	t uint8
+
+	// Interrupts enabled, and the Q output flip-flop
+	ie bool
+	q  bool
+
+	// External flag inputs EF1-EF4, tested by B1-B4 and BN1-BN4
+	ef [4]bool
+
+	// I/O ports 1-7: what INP reads, and what OUT last wrote
+	in  [8]uint8
+	out [8]uint8
+
+	// Set by IDL until an interrupt or Wake
+	idle bool
+}
+
+// Resets the CPU, like the CLEAR input
+func (c *CPU) Reset() {
+	c.r[0] = 0
+	c.p = 0
+	c.x = 0
+	c.q = false
+	c.ie = true
+	c.idle = false
+}
+
+// Returns register N
+func (c *CPU) R(n int) uint16 {
+	return c.r[n&0xF]
+}
+
+// Sets register N
+func (c *CPU) SetR(n int, value uint16) {
+	c.r[n&0xF] = value
+}
+
+// Returns the register number that is the program counter
+func (c *CPU) P() uint8 {
+	return c.p
+}
+
+// Makes register N the program counter
+func (c *CPU) SetP(n uint8) {
+	c.p = n & 0xF
+}
+
+// Returns the register number that is the data pointer
+func (c *CPU) X() uint8 {
+	return c.x
+}
+
+// Makes register N the data pointer
+func (c *CPU) SetX(n uint8) {
+	c.x = n & 0xF
+}
+
+// Returns the accumulator
+func (c *CPU) D() uint8 {
+	return c.d
+}
+
+// Returns the carry flag, 1 if set
+func (c *CPU) DF() uint8 {
+	return c.df
+}
+
+// Returns the Q output
+func (c *CPU) Q() bool {
+	return c.q
+}
+
+// Returns true if the CPU is waiting in IDL
+func (c *CPU) Idle() bool {
+	return c.idle
+}
+
+// Ends an IDL, like a DMA request does
+func (c *CPU) Wake() {
+	c.idle = false
+}
+
+// Sets external flag input EF1-EF4
+func (c *CPU) SetEF(n int, on bool) {
+	if n >= 1 && n <= 4 {
+		c.ef[n-1] = on
+	}
+}
+
+// Sets what INP reads from port 1-7
+func (c *CPU) SetInput(port int, value uint8) {
+	c.in[port&0x7] = value
+}
+
+// Returns what OUT last wrote to port 1-7
+func (c *CPU) Output(port int) uint8 {
+	return c.out[port&0x7]
+}
+
+// Takes an interrupt, if they are enabled
+// T saves X and P, then R1 becomes the program counter and R2 the data pointer
+func (c *CPU) Interrupt() {
+	if !c.ie {
+		return
+	}
+	c.t = c.x<<4 | c.p
+	c.p = 1
+	c.x = 2
+	c.ie = false
+	c.idle = false
+}
+
+// Runs one instruction, and returns the machine cycles it took
+// An idle CPU does nothing and takes one cycle
+func (c *CPU) Step(bus Bus) uint64 {
+	if c.idle {
+		return 1
+	}
+
+	// Fetch
+	op := bus.Read(c.r[c.p])
+	c.r[c.p]++
+	n := op & 0xF
+
+	switch op >> 4 {
+	case 0x0:
+		if n == 0 { // IDL
+			c.idle = true
+		} else { // LDN - Load D via R(N)
+			c.d = bus.Read(c.r[n])
+		}
+
+	case 0x1: // INC - Increment R(N)
+		c.r[n]++
+
+	case 0x2: // DEC - Decrement R(N)
+		c.r[n]--
+
+	case 0x3: // Short branches, within the page
+		target := bus.Read(c.r[c.p])
+		if c.shortCondition(n) {
+			c.r[c.p] = c.r[c.p]&0xFF00 | uint16(target)
+		} else {
+			c.r[c.p]++
+		}
+
+	case 0x4: // LDA - Load D and advance R(N)
+		c.d = bus.Read(c.r[n])
+		c.r[n]++
+
+	case 0x5: // STR - Store D via R(N)
+		bus.Write(c.r[n], c.d)
+
+	case 0x6:
+		switch {
+		case n == 0x0: // IRX - Increment R(X)
+			c.r[c.x]++
+		case n < 0x8: // OUT - Write M(R(X)) to port N, and advance R(X)
+			c.out[n] = bus.Read(c.r[c.x])
+			c.r[c.x]++
+		case n > 0x8: // INP - Read port N-8 into M(R(X)) and D
+			c.d = c.in[n&0x7]
+			bus.Write(c.r[c.x], c.d)
+		}
+
+	case 0x7:
+		c.group7(bus, n)
+
+	case 0x8: // GLO - Get low byte of R(N)
+		c.d = uint8(c.r[n])
+
+	case 0x9: // GHI - Get high byte of R(N)
+		c.d = uint8(c.r[n] >> 8)
+
+	case 0xA: // PLO - Put D in the low byte of R(N)
+		c.r[n] = c.r[n]&0xFF00 | uint16(c.d)
+
+	case 0xB: // PHI - Put D in the high byte of R(N)
+		c.r[n] = c.r[n]&0x00FF | uint16(c.d)<<8
+
+	case 0xC:
+		c.long(bus, n)
+		return CYCLES_LONG
+
+	case 0xD: // SEP - Make R(N) the program counter
+		c.p = n
+
+	case 0xE: // SEX - Make R(N) the data pointer
+		c.x = n
+
+	case 0xF:
+		c.groupF(bus, n)
+	}
+
+	return CYCLES_SHORT
+}
+
+// Returns true if short branch 3N should be taken
+func (c *CPU) shortCondition(n uint8) bool {
+	var taken bool
+	switch n & 0x7 {
+	case 0x0: // BR, and SKP which never branches
+		taken = true
+	case 0x1: // BQ
+		taken = c.q
+	case 0x2: // BZ
+		taken = c.d == 0
+	case 0x3: // BDF
+		taken = c.df == 1
+	default: // B1-B4
+		taken = c.ef[n&0x7-4]
+	}
+	// 38-3F are the opposites: SKP, BNQ, BNZ, BNF, BN1-BN4
+	if n&0x8 != 0 {
+		return !taken
+	}
+	return taken
+}
+
+// Runs the long branches and skips, CN
+func (c *CPU) long(bus Bus, n uint8) {
+	var cond bool
+	switch n & 0x3 {
+	case 0x0:
+		cond = true
+	case 0x1:
+		cond = c.q
+	case 0x2:
+		cond = c.d == 0
+	case 0x3:
+		cond = c.df == 1
+	}
+	if n == 0xC {
+		cond = c.ie
+	}
+
+	switch {
+	case n == 0x4: // NOP
+
+	case n&0x4 == 0:
+		// C0-C3 (LBR, LBQ, LBZ, LBDF) branch if, C8-CB (NLBR, LBNQ, LBNZ,
+		// LBNF) branch if not, so NLBR always skips the address
+		if n&0x8 != 0 {
+			cond = !cond
+		}
+		if cond {
+			c.r[c.p] = uint16(bus.Read(c.r[c.p]))<<8 | uint16(bus.Read(c.r[c.p]+1))
+		} else {
+			c.r[c.p] += 2
+		}
+
+	default:
+		// C5-C7 (LSNQ, LSNZ, LSNF) skip if not, CC-CF (LSIE, LSQ, LSZ,
+		// LSDF) skip if
+		if n&0x8 == 0 {
+			cond = !cond
+		}
+		if cond {
+			c.r[c.p] += 2
+		}
+	}
+}
+
+// Runs the control and arithmetic instructions, 7N
+func (c *CPU) group7(bus Bus, n uint8) {
+	switch n {
+	case 0x0, 0x1: // RET/DIS - Restore X and P from M(R(X)), and set IE
+		v := bus.Read(c.r[c.x])
+		c.r[c.x]++
+		c.x = v >> 4
+		c.p = v & 0xF
+		c.ie = n == 0x0
+	case 0x2: // LDXA - Load D via R(X) and advance
+		c.d = bus.Read(c.r[c.x])
+		c.r[c.x]++
+	case 0x3: // STXD - Store D via R(X) and decrement
+		bus.Write(c.r[c.x], c.d)
+		c.r[c.x]--
+	case 0x4: // ADC
+		c.add(bus.Read(c.r[c.x]), c.df)
+	case 0x5: // SDB - M(R(X)) - D with borrow
+		c.subtract(bus.Read(c.r[c.x]), c.d, c.df)
+	case 0x6: // SHRC - Shift right through carry
+		carry := c.df
+		c.df = c.d & 1
+		c.d = c.d>>1 | carry<<7
+	case 0x7: // SMB - D - M(R(X)) with borrow
+		c.subtract(c.d, bus.Read(c.r[c.x]), c.df)
+	case 0x8: // SAV - Save T at M(R(X))
+		bus.Write(c.r[c.x], c.t)
+	case 0x9: // MARK - Save X and P in T and at M(R2), then X = P
+		c.t = c.x<<4 | c.p
+		bus.Write(c.r[2], c.t)
+		c.x = c.p
+		c.r[2]--
+	case 0xA: // REQ
+		c.q = false
+	case 0xB: // SEQ
+		c.q = true
+	case 0xC: // ADCI
+		c.add(c.immediate(bus), c.df)
+	case 0xD: // SDBI
+		c.subtract(c.immediate(bus), c.d, c.df)
+	case 0xE: // SHLC - Shift left through carry
+		carry := c.df
+		c.df = c.d >> 7
+		c.d = c.d<<1 | carry
+	case 0xF: // SMBI
+		c.subtract(c.d, c.immediate(bus), c.df)
+	}
+}
+
+// Runs the logic and arithmetic instructions, FN
+// F0-F7 work on M(R(X)), F8-FF on the next byte
+func (c *CPU) groupF(bus Bus, n uint8) {
+	var m uint8
+	switch {
+	case n == 0x6 || n == 0xE:
+		// SHR and SHL have no operand
+	case n < 0x8:
+		m = bus.Read(c.r[c.x])
+	default:
+		m = c.immediate(bus)
+	}
+
+	switch n & 0x7 {
+	case 0x0: // LDX/LDI
+		c.d = m
+	case 0x1: // OR/ORI
+		c.d |= m
+	case 0x2: // AND/ANI
+		c.d &= m
+	case 0x3: // XOR/XRI
+		c.d ^= m
+	case 0x4: // ADD/ADI
+		c.add(m, 0)
+	case 0x5: // SD/SDI - M - D
+		c.subtract(m, c.d, 1)
+	case 0x6:
+		if n == 0x6 { // SHR
+			c.df = c.d & 1
+			c.d >>= 1
+		} else { // SHL
+			c.df = c.d >> 7
+			c.d <<= 1
+		}
+	case 0x7: // SM/SMI - D - M
+		c.subtract(c.d, m, 1)
+	}
+}
+
+// Returns the byte after the instruction, and moves past it
+func (c *CPU) immediate(bus Bus) uint8 {
+	v := bus.Read(c.r[c.p])
+	c.r[c.p]++
+	return v
+}
+
+// Sets D to D + m + carry, DF is the carry out
+func (c *CPU) add(m uint8, carry uint8) {
+	sum := uint16(c.d) + uint16(m) + uint16(carry)
+	c.d = uint8(sum)
+	c.df = uint8(sum >> 8)
+}
+
+// Sets D to a - b, borrowing if notBorrow is 0. DF is 1 if there was no
+// borrow out, like the 1802.
+func (c *CPU) subtract(a, b uint8, notBorrow uint8) {
+	diff := int(a) - int(b) - int(1-notBorrow)
+	c.d = uint8(diff)
+	c.df = 0
+	if diff >= 0 {
+		c.df = 1
+	}
+}
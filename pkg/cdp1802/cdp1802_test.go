@@ -0,0 +1,205 @@
+package cdp1802
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "testing"
+
+// 4K of memory, like the VIP
+type ram [0x1000]uint8
+
+func (m *ram) Read(addr uint16) uint8 {
+	return m[addr&0x0FFF]
+}
+
+func (m *ram) Write(addr uint16, value uint8) {
+	m[addr&0x0FFF] = value
+}
+
+// Returns a CPU just out of reset, with code at 0x000 where R0 runs it from
+func load(code ...uint8) (*CPU, *ram) {
+	m := &ram{}
+	copy(m[:], code)
+	return &CPU{}, m
+}
+
+// Runs n instructions, and returns the machine cycles they took
+func run(c *CPU, m *ram, n int) uint64 {
+	var cycles uint64
+	for i := 0; i < n; i++ {
+		cycles += c.Step(m)
+	}
+	return cycles
+}
+
+func TestShortBranches(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup []uint8
+		steps int
+		op    uint8
+		ef3   bool
+		taken bool
+	}{
+		{"BR", nil, 0, 0x30, false, true},
+		{"SKP", nil, 0, 0x38, false, false},
+		{"BZ on zero", []uint8{0xF8, 0x00}, 1, 0x32, false, true},
+		{"BZ on non-zero", []uint8{0xF8, 0x01}, 1, 0x32, false, false},
+		{"BNZ on non-zero", []uint8{0xF8, 0x01}, 1, 0x3A, false, true},
+		{"BDF after carry", []uint8{0xF8, 0x80, 0xFE}, 2, 0x33, false, true},
+		{"BNF after carry", []uint8{0xF8, 0x80, 0xFE}, 2, 0x3B, false, false},
+		{"BQ after SEQ", []uint8{0x7B}, 1, 0x31, false, true},
+		{"BNQ after SEQ", []uint8{0x7B}, 1, 0x39, false, false},
+		{"B3 with EF3", nil, 0, 0x36, true, true},
+		{"BN3 with EF3", nil, 0, 0x3E, true, false},
+		{"BN3 without EF3", nil, 0, 0x3E, false, true},
+	}
+	for _, tt := range tests {
+		code := append(append([]uint8(nil), tt.setup...), tt.op, 0x80)
+		c, m := load(code...)
+		c.SetEF(3, tt.ef3)
+		if cycles := run(c, m, tt.steps+1); cycles != uint64(tt.steps+1)*CYCLES_SHORT {
+			t.Errorf("%s: took %d cycles", tt.name, cycles)
+		}
+
+		// A branch stays in the page, a miss skips the target byte
+		want := uint16(len(code))
+		if tt.taken {
+			want = 0x80
+		}
+		if c.R(0) != want {
+			t.Errorf("%s: R0 = %04X, want %04X", tt.name, c.R(0), want)
+		}
+	}
+}
+
+func TestLongBranches(t *testing.T) {
+	// LDI 00, LBZ 0123
+	c, m := load(0xF8, 0x00, 0xC2, 0x01, 0x23)
+	if cycles := run(c, m, 2); cycles != CYCLES_SHORT+CYCLES_LONG {
+		t.Errorf("LBZ took %d cycles", cycles-CYCLES_SHORT)
+	}
+	if c.R(0) != 0x0123 {
+		t.Errorf("LBZ went to %04X, want 0123", c.R(0))
+	}
+
+	// LDI 01, LBZ 0123, falls through past the address
+	c, m = load(0xF8, 0x01, 0xC2, 0x01, 0x23)
+	run(c, m, 2)
+	if c.R(0) != 0x0005 {
+		t.Errorf("LBZ not taken went to %04X, want 0005", c.R(0))
+	}
+
+	// LDI 01, LSNZ skips the next two bytes
+	c, m = load(0xF8, 0x01, 0xC6, 0xC0, 0x01, 0x23)
+	run(c, m, 2)
+	if c.R(0) != 0x0005 {
+		t.Errorf("LSNZ went to %04X, want 0005", c.R(0))
+	}
+}
+
+func TestSEP(t *testing.T) {
+	// The VIP interpreter's convention: it calls a routine by making R3 the
+	// program counter, and the routine hands back with D4
+	c, m := load()
+	copy(m[0x300:], []uint8{0xF8, 0x42, 0xD4, 0x30, 0x00})
+	c.SetR(3, 0x300)
+	c.SetR(4, 0x100)
+	c.SetP(3)
+
+	run(c, m, 2)
+	if c.P() != 4 {
+		t.Fatalf("P = %d after D4, want 4", c.P())
+	}
+	if c.D() != 0x42 {
+		t.Errorf("D = %02X, want 42", c.D())
+	}
+	if c.R(3) != 0x303 {
+		t.Errorf("R3 = %04X, want 0303, just past the D4", c.R(3))
+	}
+	if c.R(4) != 0x100 {
+		t.Errorf("R4 = %04X, SEP moved it", c.R(4))
+	}
+}
+
+func TestIdle(t *testing.T) {
+	// IDL, LDI 07
+	c, m := load(0x00, 0xF8, 0x07)
+	run(c, m, 1)
+	if !c.Idle() {
+		t.Fatalf("IDL didn't idle")
+	}
+
+	// Idle until a DMA request wakes it
+	if cycles := run(c, m, 5); cycles != 5 || c.R(0) != 0x0001 {
+		t.Errorf("idle CPU took %d cycles and moved R0 to %04X", cycles, c.R(0))
+	}
+	c.Wake()
+	run(c, m, 1)
+	if c.Idle() || c.D() != 0x07 {
+		t.Errorf("woken CPU idle %v, D = %02X, want 07", c.Idle(), c.D())
+	}
+
+	// Interrupts wake it too, once Reset has turned them on
+	c, m = load(0x00)
+	c.Reset()
+	run(c, m, 1)
+	c.Interrupt()
+	if c.Idle() || c.P() != 1 || c.X() != 2 {
+		t.Errorf("interrupt left idle %v, P = %d, X = %d", c.Idle(), c.P(), c.X())
+	}
+}
+
+func TestInputOutput(t *testing.T) {
+	// SEX 2, OUT 2, INP 1 (69), with R2 at 0x100
+	c, m := load(0xE2, 0x62, 0x69)
+	m[0x100] = 0x0A
+	c.SetR(2, 0x100)
+	c.SetInput(1, 0x5A)
+
+	run(c, m, 2)
+	if c.Output(2) != 0x0A {
+		t.Errorf("OUT 2 wrote %02X, want 0A", c.Output(2))
+	}
+	if c.R(2) != 0x101 {
+		t.Errorf("OUT didn't advance R(X), R2 = %04X", c.R(2))
+	}
+
+	run(c, m, 1)
+	if c.D() != 0x5A || m[0x101] != 0x5A {
+		t.Errorf("INP 1 read D = %02X, M = %02X, want 5A", c.D(), m[0x101])
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	tests := []struct {
+		name  string
+		code  []uint8
+		d, df uint8
+	}{
+		{"ADI carries", []uint8{0xF8, 0xF0, 0xFC, 0x20}, 0x10, 1},
+		{"SMI borrows", []uint8{0xF8, 0x10, 0xFF, 0x20}, 0xF0, 0},
+		{"SDI without borrow", []uint8{0xF8, 0x10, 0xFD, 0x20}, 0x10, 1},
+		{"SHR", []uint8{0xF8, 0x03, 0xF6}, 0x01, 1},
+		{"XRI", []uint8{0xF8, 0xFF, 0xFB, 0x0F}, 0xF0, 0},
+	}
+	for _, tt := range tests {
+		c, m := load(tt.code...)
+		run(c, m, 2)
+		if c.D() != tt.d || c.DF() != tt.df {
+			t.Errorf("%s: D = %02X, DF = %d, want %02X, %d", tt.name, c.D(), c.DF(), tt.d, tt.df)
+		}
+	}
+}
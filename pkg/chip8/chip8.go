@@ -16,8 +16,11 @@ package chip8
 */
 
 import (
+	"chippy/pkg/cdp1802"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"math/rand"
+	"io"
 	"os"
 	"time"
 
@@ -30,6 +33,9 @@ const DISPLAY_WIDTH int32 = 64
 // CHIP-8 Display Height 32px
 const DISPLAY_HEIGHT int32 = 32
 
+// HIRES CHIP-8 Display Height 64px, see platform.go
+const HIRES_DISPLAY_HEIGHT int32 = 64
+
 // CHIP-8 Display Scaling Factor
 const DISPLAY_MODIFIER int32 = 10
 
@@ -59,7 +65,8 @@ type Chip8 struct {
 	memory [4096]uint8
 
 	// CHIP-8 has a display that is 64x32
-	display [DISPLAY_HEIGHT][DISPLAY_WIDTH]uint8
+	// HIRES CHIP-8 uses all 64 rows, see DisplayHeight
+	display [HIRES_DISPLAY_HEIGHT][DISPLAY_WIDTH]uint8
 
 	// CHIP-8 Program Counter
 	// Points at the current instruction in memory
@@ -106,11 +113,104 @@ type Chip8 struct {
 	// CHIP-8 Keypad State, Keys 0-F
 	// 1 is pressed, 0 is not pressed
 	ks [0xF + 1]int
+
+	// CHIP-8 Timing Mode
+	// Decides how much each instruction costs, see timing.go
+	timing TimingMode
+
+	// Total cycles spent since Init, in timing mode units
+	cycles uint64
+
+	// Cycles spent in the current 60Hz frame, scaled by frameBudget
+	frameCycles uint64
+
+	// Number of 60Hz frames elapsed since Init
+	frame uint64
+
+	// Number of instructions executed since Init
+	instructions uint64
+
+	// CHIP-8 Quirks
+	// Behaviour that differs between interpreters, see quirks.go
+	quirks Quirks
+
+	// CHIP-8 Platform
+	// Hardware beyond the original CHIP-8, see platform.go
+	platform Platform
+
+	// CHIP-8X colour zones, background colour and tone, see chip8x.go
+	zones      [ZONE_ROWS][ZONE_COLUMNS]uint8
+	background uint8
+	tone       uint8
+
+	// CHIP-8X second keypad mapping and state
+	km2 [0xF + 1]sdl.Keycode
+	ks2 [0xF + 1]int
+
+	// MegaChip mode, see megachip.go
+	// High byte of the 24 bit I, and read only memory above MEMORY_SIZE,
+	// which Snapshot shares because nothing writes to it
+	megaOn     bool
+	iHigh      uint8
+	megaMemory []uint8
+
+	// MegaChip sprite size, blending and collision colour
+	spriteWidth  uint8
+	spriteHeight uint8
+	screenAlpha  uint8
+	blend        uint8
+	collision    uint8
+
+	// MegaChip palette and display, nil unless the platform is MegaChip
+	// They're big, so other platforms don't copy them around in snapshots
+	mega *megaDisplay
+
+	// MegaChip digitised sound
+	sound megaSound
+
+	// 1802 for 0NNN machine code, whether a routine is running, and machine
+	// cycles not yet charged in TIMING_FIXED. See machinecode.go
+	cpu           cdp1802.CPU
+	machineCode   bool
+	machineCycles uint64
+
+	// SHA-1 of the loaded ROM, hex encoded
+	romHash string
+	romSize int
+
+	// The loaded ROM, kept for Reset
+	rom []byte
+
+	// Memory written this frame and the last, one bit per address
+	// See memory.go
+	writes     [MEMORY_SIZE / 64]uint64
+	lastWrites [MEMORY_SIZE / 64]uint64
+
+	// Set whenever the display changes, so frontends only redraw when needed
+	dirty bool
+
+	// Random number generator state for CXNN, and the seed it started from
+	// See random.go
+	rng  uint64
+	seed int64
+
+	// Optional instruction tracer, and the record it is handed
+	// See trace.go
+	tracer      Tracer
+	traceRecord TraceRecord
+
+	// Optional execution profiler
+	// See profile.go
+	profiler *Profiler
+
+	// Where log messages go, silent unless SetLogger is called
+	// See log.go
+	log Logger
 }
 
 // Initializes the CHIP-8
 func Init() Chip8 {
-	fmt.Println("Initializing CHIP-8...")
+	defaultLogger.Info("Initializing CHIP-8...")
 	chippy := Chip8{
 		// The first CHIP-8 interpreter, on the COMAC VIP, was located in RAM,
 		// from address 000 to 1FF. It would expect a CHIP-8 program to be
@@ -123,6 +223,8 @@ func Init() Chip8 {
 		dt:         0x0,
 		st:         0x0,
 		clockSpeed: 500,
+		dirty:      true,
+		log:        defaultLogger,
 	}
 
 	// Zero out memory
@@ -148,7 +250,7 @@ func Init() Chip8 {
 	}
 
 	// Load fontset into memory
-	fmt.Println("Loading font set into memory...")
+	chippy.log.Debug("Loading font set into memory...")
 	for i := 0; i < len(fontset); i++ {
 		chippy.memory[i] = fontset[i]
 	}
@@ -181,6 +283,16 @@ func Init() Chip8 {
 		chippy.ks[i] = 0
 	}
 
+	// Power on the CHIP-8X boards, they sit idle on other platforms
+	chippy.initChip8X()
+	chippy.initMegaChip()
+
+	// The 1802 comes out of reset with interrupts on, they never come though
+	chippy.cpu.Reset()
+
+	// Seed the random number generator, SetSeed makes runs repeatable
+	chippy.SetSeed(time.Now().UnixNano())
+
 	return chippy
 }
 
@@ -189,9 +301,31 @@ func (chippy *Chip8) ClockSpeed() uint32 {
 	return chippy.clockSpeed
 }
 
-// Returns the current CHIP-8 Display Buffer
-func (c *Chip8) DisplayBuffer() [DISPLAY_HEIGHT][DISPLAY_WIDTH]uint8 {
-	return c.display
+// Sets the CHIP-8 Clock Speed (Hz)
+func (c *Chip8) SetClockSpeed(hz uint32) {
+	if hz > 0 {
+		c.clockSpeed = hz
+	}
+}
+
+// Returns a copy of the current CHIP-8 Display Buffer, DisplayHeight rows
+func (c *Chip8) DisplayBuffer() [][DISPLAY_WIDTH]uint8 {
+	return append([][DISPLAY_WIDTH]uint8(nil), c.display[:c.DisplayHeight()]...)
+}
+
+// Returns the height of the display, in pixels
+func (c *Chip8) DisplayHeight() int32 {
+	if c.platform == PLATFORM_HIRES {
+		return HIRES_DISPLAY_HEIGHT
+	}
+	return DISPLAY_HEIGHT
+}
+
+// Returns true if the display changed since the last call
+func (c *Chip8) DisplayChanged() bool {
+	changed := c.dirty
+	c.dirty = false
+	return changed
 }
 
 // Returns the current CHIP-8 Opcode
@@ -199,9 +333,9 @@ func (c *Chip8) Opcode() uint16 {
 	return c.oc
 }
 
-// Returns the current CHIP-8 Program Counter
+// Returns the current CHIP-8 Program Counter, wrapped at 4 KiB like the fetch
 func (c *Chip8) PC() uint16 {
-	return c.pc
+	return c.pc & 0x0FFF
 }
 
 // Returns the current CHIP-8 Index Register
@@ -214,12 +348,25 @@ func (c *Chip8) KeyMap() [0xF + 1]sdl.Keycode {
 	return c.km
 }
 
+// Binds the given CHIP-8 key to an SDL key
+func (c *Chip8) SetKey(kc int, key sdl.Keycode) {
+	if kc >= 0x0 && kc <= 0xF {
+		c.km[kc] = key
+	}
+}
+
+// Returns the SHA-1 of the loaded ROM, hex encoded
+// Returns an empty string if no ROM is loaded
+func (c *Chip8) ROMHash() string {
+	return c.romHash
+}
+
 // Set state to pressed for the given key
 func (c *Chip8) KeyPress(kc int) {
 	if kc >= 0x0 && kc <= 0xF {
 		c.ks[kc] = 1
 
-		fmt.Printf("Key %X pressed\n", kc)
+		c.log.Debug("Key pressed", "key", kc)
 	}
 }
 
@@ -228,53 +375,152 @@ func (c *Chip8) KeyRelease(kc int) {
 	if kc >= 0x0 && kc <= 0xF {
 		c.ks[kc] = 0
 
-		fmt.Printf("Key %X released\n", kc)
+		c.log.Debug("Key released", "key", kc)
 	}
 }
 
+// Resets the CHIP-8 to power on, and loads the current ROM again
+// Settings (timing, clock speed, quirks, platform, keys, seed) and attached
+// loggers, tracers and profilers are kept
+func (c *Chip8) Reset() {
+	fresh := Init()
+	fresh.timing = c.timing
+	fresh.clockSpeed = c.clockSpeed
+	fresh.quirks = c.quirks
+	fresh.platform = c.platform
+	fresh.pc = fresh.ROMAddress()
+	if fresh.platform == PLATFORM_MEGACHIP {
+		fresh.mega = &megaDisplay{}
+	}
+	fresh.km = c.km
+	fresh.km2 = c.km2
+	fresh.log = c.log
+	fresh.tracer = c.tracer
+	fresh.profiler = c.profiler
+	fresh.SetSeed(c.seed)
+	if c.rom != nil {
+		fresh.LoadROMBytes(c.rom)
+	}
+	*c = fresh
+}
+
 // Loads a CHIP-8 ROM into memory from the given file
 // Returns the size of the ROM, and an error if the ROM is invalid
 func (c *Chip8) LoadROM(file string) (int64, error) {
 	// Read ROM File
-	rom, err := os.OpenFile(file, os.O_RDONLY, 0644)
+	rom, err := os.Open(file)
 	if err != nil {
 		return -1, err
 	}
 	defer rom.Close()
 
-	// Make sure the ROM is the correct size, given that we
-	// load into memory starting at 0x200
-	stat, err := rom.Stat()
+	return c.LoadROMFrom(rom)
+}
+
+// Loads a CHIP-8 ROM into memory from the given reader, reading until EOF
+// Returns the size of the ROM, and an error if the ROM is invalid
+func (c *Chip8) LoadROMFrom(r io.Reader) (int64, error) {
+	// Read one byte more than fits, so we can tell if the ROM is too large
+	// without reading a huge file all the way
+	buffer, err := io.ReadAll(io.LimitReader(r, int64(c.MaxROMSize())+1))
 	if err != nil {
 		return -1, err
 	}
-	if int64(len(c.memory)-0x200) < stat.Size() {
+
+	return c.LoadROMBytes(buffer)
+}
+
+// Loads a CHIP-8 ROM into memory from the given bytes
+// Returns the size of the ROM, and an error if the ROM is invalid
+func (c *Chip8) LoadROMBytes(rom []byte) (int64, error) {
+	// Make sure the ROM is the correct size, given that we
+	// load into memory starting at 0x200 (0x300 for CHIP-8X)
+	addr := int(c.ROMAddress())
+	if c.MaxROMSize() < len(rom) {
 		return -1, fmt.Errorf("ROM is too large to fit in memory :(")
 	}
 
-	// Read the ROM into memory, starting at 0x200
-	fmt.Println("Loading ROM into memory...")
-	buffer := make([]byte, stat.Size())
-	if _, err := rom.Read(buffer); err != nil {
-		return -1, err
+	// Read the ROM into memory, starting at the ROM address
+	// MegaChip ROMs carry on past 4K
+	c.log.Info("Loading ROM into memory...", "size", len(rom))
+	c.megaMemory = nil
+	for i := 0; i < len(rom) && i+addr < len(c.memory); i++ {
+		c.memory[i+addr] = rom[i]
 	}
-	for i := 0; i < len(buffer); i++ {
-		c.memory[i+0x200] = buffer[i]
+	if addr+len(rom) > len(c.memory) {
+		c.megaMemory = append([]uint8(nil), rom[len(c.memory)-addr:]...)
 	}
 
-	return stat.Size(), nil
+	// Remember the ROM hash, so settings can be looked up per ROM
+	sum := sha1.Sum(rom)
+	c.romHash = hex.EncodeToString(sum[:])
+	c.romSize = len(rom)
+	c.rom = append([]byte(nil), rom...)
+
+	return int64(len(rom)), nil
+}
+
+// Clears the whole display
+func (c *Chip8) clearDisplay() {
+	for h := 0; h < len(c.display); h++ {
+		for w := 0; w < len(c.display[h]); w++ {
+			c.display[h][w] = 0x0
+		}
+	}
+	c.dirty = true
+}
+
+// Moves I past the registers FX55 and FX65 just stored or loaded, if the
+// quirks say so
+func (c *Chip8) incrementI() {
+	x := (c.oc & 0x0F00) >> 8
+	switch {
+	case c.quirks.MemoryIncrementByX:
+		c.i = (c.i + x) & c.MaxI()
+	case c.quirks.MemoryIncrement:
+		c.i = (c.i + x + 1) & c.MaxI()
+	}
+}
+
+// Logs the current opcode as one we don't know
+func (c *Chip8) unknownOpcode() {
+	c.log.Warn("Unknown opcode", "opcode", fmt.Sprintf("0x%04X", c.oc), "pc", fmt.Sprintf("0x%03X", c.pc))
 }
 
 // Cycle the CHIP-8 CPU (Fetch, Decode, Execute)
 func (c *Chip8) Cycle() {
+	// Machine code routines run on the 1802 until they return
+	if c.machineCode {
+		c.stepMachineCode()
+		return
+	}
+
 	// Fetch Opcode (2 bytes), and merge into a single 16-bit value
 	// Todo this we shift left by 8 bytes and use bitwise OR to merge
 	// For example:
 	// memory[pc] = 0xA2
 	// memory[pc+1] = 0xF0
 	// Resulting merge: 0xA2F0
-	c.oc = uint16(c.memory[c.pc])<<8 | uint16(c.memory[c.pc+1])
-	//fmt.Printf("[0x%X]\n", c.oc)
+	// Memory is 4 KiB, so PC wraps around the end, like I does
+	c.pc &= 0x0FFF
+	c.oc = uint16(c.read(c.pc))<<8 | uint16(c.read(c.pc+1))
+
+	// On the COSMAC VIP, sprites wait for the 60Hz interrupt before drawing
+	// Idle until the next frame, the draw happens on the next cycle
+	if c.displayWait() && c.oc&0xF000 == 0xD000 && !c.atFrameStart() {
+		c.waitForFrame()
+		return
+	}
+
+	// Work out what this instruction costs before it changes any state
+	cost := c.opcodeCost()
+	c.instructions++
+	if c.tracer != nil {
+		c.traceStart()
+	}
+	if c.profiler != nil {
+		c.profiler.record(c, cost)
+	}
 
 	// Decode & Execute Opcode
 	// Ex: 0xA2F0 & 0xF000 -> 0xA000
@@ -283,35 +529,64 @@ func (c *Chip8) Cycle() {
 	// Instrucutions starting with 0x0
 	// 0x00E0 - Clear the display
 	// 0x00EE - Return from a subroutine
-	// NOTE: Did not implement 0x0NNN - Used for running machine language outside of CHIP-8
+	// 0x02A0 - Cycle the background colour (CHIP-8X)
+	// 0x0230 - Clear the display (HIRES CHIP-8)
+	// 0x0010-0x09NN - MegaChip, see megachip.go
+	// 0x0NNN - Run the 1802 machine code at NNN, with the MachineCode quirk
 	case 0x0000:
+		if c.oc == 0x0230 && c.platform == PLATFORM_HIRES {
+			c.clearDisplay()
+			c.pc += 2
+			break
+		}
+		if c.oc == 0x02A0 && c.platform == PLATFORM_CHIP8X {
+			c.cycleBackground()
+			c.pc += 2
+			break
+		}
+		if c.platform == PLATFORM_MEGACHIP && c.megaOpcode() {
+			break
+		}
+		if c.quirks.MachineCode && c.oc != 0x00E0 && c.oc != 0x00EE {
+			c.callMachineCode()
+			break
+		}
+
 		// Need to compare the last 4 bits of the opcode
 		// Ex: 0x000E & 0x000F-> 0x000E
 		switch c.oc & 0x000F {
 		case 0x0000: // 0x00E0 Clear the display
-			for h := 0; h < len(c.display); h++ {
-				for w := 0; w < len(c.display[h]); w++ {
-					c.display[h][w] = 0x0
-				}
-			}
+			c.clearDisplay()
 			c.pc += 2
 
 		case 0x000E: // 0x00EE Return from a subroutine
 			// Decrease the stack pointer
 			// Set the PC to the stored return address
 			// Increment PC
+			// Returning with nothing on the stack is a ROM bug, skip it
+			if c.sp == 0 {
+				c.log.Warn("Stack underflow", "pc", fmt.Sprintf("0x%03X", c.pc))
+				c.pc += 2
+				break
+			}
 			c.sp -= 1
 			c.pc = c.stack[c.sp]
 			c.pc += 2
 
 		default:
-			fmt.Printf("[0x0000] Unknown opcode: 0x%X\n", c.oc)
+			c.unknownOpcode()
 		}
 
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Instrucutions starting with 0x1
 	// 0x1NNN - Jump to address NNN
 	case 0x1000: // 0x1NNN Jump to address NNN
+		// HIRES ROMs start with 1260, which jumps over the interpreter patch
+		// they carry for the real VIP
+		if c.oc == 0x1260 && c.pc == ROM_ADDRESS && c.platform == PLATFORM_HIRES {
+			c.pc = HIRES_START
+			break
+		}
 		c.pc = c.oc & 0x0FFF
 
 	/////////////////////////////////////////////////////////////////////////////////////////
@@ -321,6 +596,12 @@ func (c *Chip8) Cycle() {
 		// Store the current PC on the stack,
 		// and increase stack pointer since we put something on the stack
 		// Set the PC to the address NNN
+		// Calling with the stack full is a ROM bug, skip it
+		if int(c.sp) >= len(c.stack) {
+			c.log.Warn("Stack overflow", "pc", fmt.Sprintf("0x%03X", c.pc))
+			c.pc += 2
+			break
+		}
 		c.stack[c.sp] = c.pc
 		c.sp += 1
 		c.pc = c.oc & 0x0FFF
@@ -348,8 +629,12 @@ func (c *Chip8) Cycle() {
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Instrucutions starting with 0x5
 	// 0x5XY0 - Skip next instruction if VX equals VY
+	// 0x5XY1 - Add VY to VX one octal digit at a time (CHIP-8X)
 	case 0x5000:
-		if c.v[(c.oc&0x0F00)>>8] == c.v[(c.oc&0x00F0)>>4] {
+		if c.oc&0x000F == 0x0001 && c.platform == PLATFORM_CHIP8X {
+			c.addOctal()
+			c.pc += 2
+		} else if c.v[(c.oc&0x0F00)>>8] == c.v[(c.oc&0x00F0)>>4] {
 			c.pc += 4
 		} else {
 			c.pc += 2
@@ -389,14 +674,23 @@ func (c *Chip8) Cycle() {
 
 		case 0x0001: // 0x8XY1 - Set Register VX to Register VX OR Register VY
 			c.v[(c.oc&0x0F00)>>8] = (c.v[(c.oc&0x0F00)>>8] | c.v[(c.oc&0x00F0)>>4])
+			if c.quirks.ResetVF {
+				c.v[0xF] = 0
+			}
 			c.pc += 2
 
 		case 0x0002: // 0x8XY2 - Set Register VX to Register VX AND Register VY
 			c.v[(c.oc&0x0F00)>>8] = (c.v[(c.oc&0x0F00)>>8] & c.v[(c.oc&0x00F0)>>4])
+			if c.quirks.ResetVF {
+				c.v[0xF] = 0
+			}
 			c.pc += 2
 
 		case 0x0003: // 0x8XY3 - Set Register VX to Register VX XOR Register VY
 			c.v[(c.oc&0x0F00)>>8] = (c.v[(c.oc&0x0F00)>>8] ^ c.v[(c.oc&0x00F0)>>4])
+			if c.quirks.ResetVF {
+				c.v[0xF] = 0
+			}
 			c.pc += 2
 
 		case 0x0004: // 0x8XY4 - Add Register VY to Register VX, set VF to 1 if carry, 0 if not
@@ -421,9 +715,10 @@ func (c *Chip8) Cycle() {
 
 		case 0x0006: // 0x8XY6 - Set VX to VY. Store the least significant bit of Register VX in VF, and then shift Register VX right by 1
 			// NOTE: Modern implementations ignore VY completely
-			//       Treating this as optional so modern ROMs and tests pass
-			// Set VX to VY
-			//c.v[(c.oc&0x0F00)>>8] = c.v[(c.oc&0x00F0)>>4]
+			//       The COSMAC VIP shifts VY, with the ShiftVY quirk
+			if c.quirks.ShiftVY {
+				c.v[(c.oc&0x0F00)>>8] = c.v[(c.oc&0x00F0)>>4]
+			}
 
 			// Store least signigicant bit of VX in VF
 			c.v[0xF] = c.v[(c.oc&0x0F00)>>8] & 0x1
@@ -445,9 +740,10 @@ func (c *Chip8) Cycle() {
 
 		case 0x000E: // 0x8XYE - Set VX to VY. Store the most significant bit of Register VX in VF, and then shift Register VX left by 1
 			// NOTE: Modern implementations ignore VY completely
-			//       Treating this as optional so modern ROMs and tests pass
-			// Set VX to VY
-			//c.v[(c.oc&0x0F00)>>8] = c.v[(c.oc&0x00F0)>>4]
+			//       The COSMAC VIP shifts VY, with the ShiftVY quirk
+			if c.quirks.ShiftVY {
+				c.v[(c.oc&0x0F00)>>8] = c.v[(c.oc&0x00F0)>>4]
+			}
 
 			// Store most signifiant bit of VX in VF
 			c.v[0xF] = c.v[(c.oc&0x0F00)>>8] >> 7
@@ -458,7 +754,7 @@ func (c *Chip8) Cycle() {
 			c.pc += 2
 
 		default:
-			fmt.Printf("[0x8000] Unknown opcode: 0x%X\n", c.oc)
+			c.unknownOpcode()
 		}
 
 	/////////////////////////////////////////////////////////////////////////////////////////
@@ -476,26 +772,35 @@ func (c *Chip8) Cycle() {
 	// 0xANNN - Set I to NNN
 	case 0xA000: // 0xANNN Set I to NNN
 		c.i = c.oc & 0x0FFF
+		c.iHigh = 0
 		c.pc += 2
 
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Instrucutions starting with 0xB
 	// 0xBNNN - Jump to address NNN + V0
+	// 0xBXYN - Colour zones of the display (CHIP-8X), see chip8x.go
 	case 0xB000: // 0xBNNN - Jump to address NNN + V0
+		if c.platform == PLATFORM_CHIP8X {
+			c.colorZones()
+			c.pc += 2
+			break
+		}
+
 		// NOTE: This is the implementation for the original COSMAC VIP
-		//       interpreter. It is not an implementation of the CHIP-48
-		//       and SUPER-CHIP 0xBXNN instruction.
-		/*c.i = (c.oc & 0x0FFF) + uint16(c.v[0x0])
-		c.pc += 2*/
-		c.pc = (c.oc & 0x0FFF) + uint16(c.v[0x0])
+		//       interpreter. CHIP-48 and SUPER-CHIP read it as 0xBXNN,
+		//       jumping to XNN plus VX, with the JumpVX quirk
+		if c.quirks.JumpVX {
+			c.pc = (c.oc & 0x0FFF) + uint16(c.v[(c.oc&0x0F00)>>8])
+		} else {
+			c.pc = (c.oc & 0x0FFF) + uint16(c.v[0x0])
+		}
 
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Instrucutions starting with 0xC
 	// 0xCXNN - Set VX to a random number AND NN
 	case 0xC000: // 0xCXNN - Set VX to a random number AND NN
-		rand.Seed(time.Now().UnixNano())
-		// Remeber to keep the rand num within range, 0xFF
-		c.v[(c.oc&0x0F00)>>8] = uint8(rand.Int()%0xFF) & uint8((c.oc & 0x00FF))
+		// Random byte, from our seeded generator so games can be replayed
+		c.v[(c.oc&0x0F00)>>8] = c.random() & uint8((c.oc & 0x00FF))
 		c.pc += 2
 
 	/////////////////////////////////////////////////////////////////////////////////////////
@@ -503,9 +808,17 @@ func (c *Chip8) Cycle() {
 	// 0xDXYN - Draw a sprite at position VX, VY with N bytes of sprite data starting at the address
 	//			stored in I. Set VF to 01 if any set pixels are changed to unset, and 00 otherwise
 	case 0xD000: // 0xDXYN Display (Drawing)
+		// MegaChip mode draws colour sprites, see megachip.go
+		if c.megaOn {
+			c.drawMega()
+			c.pc += 2
+			break
+		}
+
 		// Fetch (X,Y) from VX and VY
+		height := int(c.DisplayHeight())
 		x := c.v[(c.oc&0x0F00)>>8] % uint8(DISPLAY_WIDTH)
-		y := c.v[(c.oc&0x00F0)>>4] % uint8(DISPLAY_HEIGHT)
+		y := int(c.v[(c.oc&0x00F0)>>4]) % height
 
 		// Fetch N from opcode (N is our height)
 		n := c.oc & 0x000F
@@ -515,44 +828,86 @@ func (c *Chip8) Cycle() {
 
 		// Loop through the height (rows)
 		for i := 0; i < int(n); i++ {
+			// Rows that fall off the bottom are clipped, unless we wrap
+			row := y + i
+			if row >= height {
+				if !c.quirks.WrapSprites {
+					break
+				}
+				row %= height
+			}
+
 			// Fetch Nth byte of sprite data, at I register + i
-			b := c.memory[c.i+uint16(i)]
+			b := c.read(c.i + uint16(i))
 
 			// Each sprite row, there are 8 bits for each pixel
 			for j := 0; j < 8; j++ {
+				// Columns that fall off the right are clipped, unless we wrap
+				col := int(x) + j
+				if col >= int(DISPLAY_WIDTH) {
+					if !c.quirks.WrapSprites {
+						break
+					}
+					col %= int(DISPLAY_WIDTH)
+				}
+
 				// Is the current pixel set?
 				if b&(0x80>>uint8(j)) != 0 {
-					c.v[0xF] = c.display[y+uint8(i)][x+uint8(j)] & 1
-					c.display[y+uint8(i)][x+uint8(j)] ^= 1
+					// Any set pixel that gets unset is a collision, for the whole sprite
+					if c.display[row][col] == 1 {
+						c.v[0xF] = 1
+					}
+					c.display[row][col] ^= 1
 				}
 			}
 		}
 
 		//fmt.Printf("[0xDXYN] X: %d, Y: %d, N: %d\n", x, y, n)
+		c.dirty = true
 		c.pc += 2
 
 	/////////////////////////////////////////////////////////////////////////////////////////
 	// Instrucutions starting with 0xE
 	// 0xEX9E - Skip next instruction if key stored in VX is pressed
 	// 0xEXA1 - Skip next instruction if key stored in VX isn't pressed
+	// 0xEXF2 - Skip next instruction if key stored in VX is pressed on the second keypad (CHIP-8X)
+	// 0xEXF5 - Skip next instruction if key stored in VX isn't pressed on the second keypad (CHIP-8X)
 	case 0xE000:
 		switch c.oc & 0x000F {
 		case 0x000E: // 0xEX9E - Skip next instruction if key stored in VX is pressed
-			if c.ks[c.v[(c.oc&0x0F00)>>8]] == 1 {
+			if c.ks[c.v[(c.oc&0x0F00)>>8]&0xF] == 1 {
 				c.pc += 4
 			} else {
 				c.pc += 2
 			}
 
 		case 0x0001: // 0xEXA1 - Skip next instruction if key stored in VX isn't pressed
-			if c.ks[c.v[(c.oc&0x0F00)>>8]] == 0 {
+			if c.ks[c.v[(c.oc&0x0F00)>>8]&0xF] == 0 {
+				c.pc += 4
+			} else {
+				c.pc += 2
+			}
+
+		case 0x0002: // 0xEXF2 - Skip next instruction if key stored in VX is pressed on the second keypad
+			if c.platform != PLATFORM_CHIP8X {
+				c.unknownOpcode()
+			} else if c.ks2[c.v[(c.oc&0x0F00)>>8]&0xF] == 1 {
+				c.pc += 4
+			} else {
+				c.pc += 2
+			}
+
+		case 0x0005: // 0xEXF5 - Skip next instruction if key stored in VX isn't pressed on the second keypad
+			if c.platform != PLATFORM_CHIP8X {
+				c.unknownOpcode()
+			} else if c.ks2[c.v[(c.oc&0x0F00)>>8]&0xF] == 0 {
 				c.pc += 4
 			} else {
 				c.pc += 2
 			}
 
 		default:
-			fmt.Printf("[0xE000] Unknown opcode: 0x%X\n", c.oc)
+			c.unknownOpcode()
 		}
 
 	/////////////////////////////////////////////////////////////////////////////////////////
@@ -569,6 +924,7 @@ func (c *Chip8) Cycle() {
 	//			I is set to I + X + 1 after operation
 	// 0xFX65 - Fill registers V0 to VX inclusive with the values stored in memory starting at address I
 	//			I is set to I + X + 1 after operation
+	// 0xFXF8 - Set the pitch of the tone to VX (CHIP-8X)
 	case 0xF000:
 		switch c.oc & 0x00FF {
 		case 0x0007: // 0xFX07 - Set VX to the value of the delay timer
@@ -607,56 +963,61 @@ func (c *Chip8) Cycle() {
 
 		case 0x0029: // 0xFX29 - Set I to the location of the sprite for the character in VX
 			// Fonts are loaded withing the first 512 bytes (0x200) of memory and are 4x5
-			// TODO: Check the math on this, does this work?
-			c.i = uint16(c.v[(c.oc&0x0F00)>>8] * 0x5)
+			// Only the low digit of VX counts, like on the VIP
+			c.i = FONT_ADDRESS + uint16(c.v[(c.oc&0x0F00)>>8]&0xF)*0x5
 			c.pc += 2
 
 		case 0x0033: // 0xFX33 - Store the binary-coded decimal representation of VX in memory locations I, I+1, and I+2
-			c.memory[c.i] = c.v[(c.oc&0x0F00)>>8] / 100
-			c.memory[c.i+1] = (c.v[(c.oc&0x0F00)>>8] / 10) % 10
-			c.memory[c.i+2] = (c.v[(c.oc&0x0F00)>>8] % 100) % 10
+			c.storeLong(c.addressI(0), c.v[(c.oc&0x0F00)>>8]/100)
+			c.storeLong(c.addressI(1), (c.v[(c.oc&0x0F00)>>8]/10)%10)
+			c.storeLong(c.addressI(2), (c.v[(c.oc&0x0F00)>>8]%100)%10)
 			c.pc += 2
 
 		case 0x0055: // 0xFX55 - Store the values of registers V0 to VX inclusive in memory starting at address I
 			// I is set to I + X + 1 after operation
 
 			for i := uint16(0); i <= ((c.oc & 0x0F00) >> 8); i++ {
-				c.memory[c.i+i] = c.v[i]
+				c.storeLong(c.addressI(i), c.v[i])
 			}
 			// NOTE: The original CHIP-8 interpreter for the COSMAC VIP did I+X+1 here
-			//       This will break modern ROMs and cause test failures (bc_test for example)
-			//c.i += ((c.oc & 0x0F00) >> 8) + 1
+			//       Modern ROMs expect I left alone (bc_test for example), so
+			//       the VIP and CHIP-48 behaviour are quirks
+			c.incrementI()
 			c.pc += 2
 
 		case 0x0065: // 0xFX65 - Fill registers V0 to VX inclusive with the values stored in memory starting at address I
 			// I is set to I + X + 1 after operation
 
 			for i := uint16(0); i <= ((c.oc & 0x0F00) >> 8); i++ {
-				c.v[i] = c.memory[c.i+i]
+				c.v[i] = c.load(c.addressI(i))
 			}
 			// NOTE: The original CHIP-8 interpreter for the COSMAC VIP did I+X+1 here
-			//       This will break modern ROMs and cause test failures (bc_test for example)
-			//c.i += ((c.oc & 0x0F00) >> 8) + 1
+			//       Modern ROMs expect I left alone (bc_test for example), so
+			//       the VIP and CHIP-48 behaviour are quirks
+			c.incrementI()
+			c.pc += 2
+
+		case 0x00F8: // 0xFXF8 - Set the pitch of the tone to VX
+			if c.platform != PLATFORM_CHIP8X {
+				c.unknownOpcode()
+				break
+			}
+			c.tone = c.v[(c.oc&0x0F00)>>8]
 			c.pc += 2
 
 		default:
-			fmt.Printf("[0xF000] Unknown opcode: 0x%X\n", c.oc)
+			c.unknownOpcode()
 
 		}
 
 	default:
-		fmt.Printf("Unknown opcode: 0x%X\n", c.oc)
+		c.unknownOpcode()
 	}
 
-	// Update timers
-	// FIXME: Both timers are supposed to decrement at 60 hz, independent
-	//        of the clock speed used for the cycle / SDL emulate loop
-	if c.dt > 0 {
-		c.dt -= 1
-	}
-	if c.st > 0 {
-		// TODO: Add option for actually making a "beep" sound
-		fmt.Println("[ST] Beep!")
-		c.st -= 1
+	if c.tracer != nil {
+		c.traceEnd()
 	}
+
+	// Spend the cycles, this ticks both timers at 60Hz
+	c.spend(cost)
 }
@@ -0,0 +1,69 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "testing"
+
+func TestBadROMsDontPanic(t *testing.T) {
+	tests := []struct {
+		name  string
+		rom   []byte
+		steps int
+		pc    uint16
+	}{
+		// Return with nothing on the stack
+		{"00EE", []byte{0x00, 0xEE}, 1, 0x202},
+		// Call ourselves until the stack is full, then carry on
+		{"2NNN", []byte{0x22, 0x00}, 17, 0x202},
+		// Keys past F
+		{"EX9E", []byte{0x60, 0xFF, 0xE0, 0x9E}, 2, 0x204},
+		{"EXA1", []byte{0x60, 0xFF, 0xE0, 0xA1}, 2, 0x206},
+	}
+	for _, tt := range tests {
+		c := runQuirks(t, Quirks{}, tt.steps, tt.rom...)
+		if c.PC() != tt.pc {
+			t.Errorf("%s: PC = %03X, want %03X", tt.name, c.PC(), tt.pc)
+		}
+	}
+
+	// The font only has 16 characters, FX29 uses the low digit
+	if c := runQuirks(t, Quirks{}, 2, 0x60, 0xFA, 0xF0, 0x29); c.I() != FONT_ADDRESS+0xA*5 {
+		t.Errorf("FX29: I = %03X, want %03X", c.I(), FONT_ADDRESS+0xA*5)
+	}
+}
+
+func TestStepMachineCode(t *testing.T) {
+	// 0300 calls a routine that spins on BN3 until key 0 is down, then
+	// returns with D4
+	rom := make([]byte, 0x104)
+	copy(rom, []byte{0x03, 0x00, 0x12, 0x02})
+	copy(rom[0x100:], []byte{0x3E, 0x00, 0xD4})
+	c := runQuirks(t, Quirks{MachineCode: true}, 1, rom...)
+
+	for i := 0; i < 100; i++ {
+		c.Step()
+	}
+	if !c.InMachineCode() {
+		t.Fatalf("routine returned without a key")
+	}
+
+	c.KeyPress(0)
+	c.Step()
+	c.Step()
+	if c.InMachineCode() || c.PC() != 0x202 {
+		t.Errorf("in machine code %v, PC = %03X, want back at 202", c.InMachineCode(), c.PC())
+	}
+}
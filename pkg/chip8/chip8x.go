@@ -0,0 +1,170 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "github.com/veandco/go-sdl2/sdl"
+
+// CHIP-8X runs on a COSMAC VIP with three extra boards:
+//
+// The VP-590 colour board gives every zone of the display a foreground
+// colour, and the whole display one of four background colours. Colours
+// are 3 bits, bit 0 red, bit 1 blue and bit 2 green.
+//
+// The VP-595 simple sound board plays a tone whose pitch the ROM sets.
+//
+// The VP-580 expansion keyboard adds a second 16 key keypad.
+
+// Colour zones are 8 pixels wide, and as short as one row
+const ZONE_WIDTH = 8
+const ZONE_COLUMNS = int(DISPLAY_WIDTH) / ZONE_WIDTH
+const ZONE_ROWS = int(DISPLAY_HEIGHT)
+
+// BXY0 colours zones 4 rows high
+const ZONE_HEIGHT = 4
+
+// VP-590 colours
+const (
+	COLOR_BLACK uint8 = iota
+	COLOR_RED
+	COLOR_BLUE
+	COLOR_VIOLET
+	COLOR_GREEN
+	COLOR_YELLOW
+	COLOR_AQUA
+	COLOR_WHITE
+)
+
+// Background colours, in the order 02A0 cycles through them
+var backgroundColors = [4]uint8{COLOR_BLUE, COLOR_BLACK, COLOR_GREEN, COLOR_RED}
+
+// VP-590 foreground colour at power on
+const DEFAULT_ZONE_COLOR = COLOR_RED
+
+// VP-595 tone at power on, FXF8 changes it
+const DEFAULT_TONE = 0x80
+
+// Returns the VP-590 foreground colour of the zone holding pixel x, y
+func (c *Chip8) ZoneColor(x, y int) uint8 {
+	return c.zones[(y%ZONE_ROWS+ZONE_ROWS)%ZONE_ROWS][((x/ZONE_WIDTH)%ZONE_COLUMNS+ZONE_COLUMNS)%ZONE_COLUMNS]
+}
+
+// Returns the VP-590 background colour
+func (c *Chip8) BackgroundColor() uint8 {
+	return backgroundColors[c.background]
+}
+
+// Returns the tone last set by FXF8
+func (c *Chip8) Tone() uint8 {
+	return c.tone
+}
+
+// Returns the pitch of the VP-595 tone, in Hz
+func (c *Chip8) ToneFrequency() float64 {
+	return 27535 / (float64(c.tone) + 1)
+}
+
+// Returns the second keypad's mapping, CHIP-8X only
+func (c *Chip8) KeyMap2() [0xF + 1]sdl.Keycode {
+	return c.km2
+}
+
+// Binds the given key on the second keypad to an SDL key
+func (c *Chip8) SetKey2(kc int, key sdl.Keycode) {
+	if kc >= 0x0 && kc <= 0xF {
+		c.km2[kc] = key
+	}
+}
+
+// Set state to pressed for the given key on the second keypad
+func (c *Chip8) KeyPress2(kc int) {
+	if kc >= 0x0 && kc <= 0xF {
+		c.ks2[kc] = 1
+
+		c.log.Debug("Key pressed", "keypad", 2, "key", kc)
+	}
+}
+
+// Set state to released for the given key on the second keypad
+func (c *Chip8) KeyRelease2(kc int) {
+	if kc >= 0x0 && kc <= 0xF {
+		c.ks2[kc] = 0
+
+		c.log.Debug("Key released", "keypad", 2, "key", kc)
+	}
+}
+
+// Powers on the CHIP-8X boards
+func (c *Chip8) initChip8X() {
+	for row := range c.zones {
+		for col := range c.zones[row] {
+			c.zones[row][col] = DEFAULT_ZONE_COLOR
+		}
+	}
+	c.background = 0
+	c.tone = DEFAULT_TONE
+
+	// The second keypad lives on the numeric keypad
+	c.km2 = [0xF + 1]sdl.Keycode{
+		sdl.K_KP_0, sdl.K_KP_1, sdl.K_KP_2, sdl.K_KP_3,
+		sdl.K_KP_4, sdl.K_KP_5, sdl.K_KP_6, sdl.K_KP_7,
+		sdl.K_KP_8, sdl.K_KP_9, sdl.K_KP_PERIOD, sdl.K_KP_ENTER,
+		sdl.K_KP_PLUS, sdl.K_KP_MINUS, sdl.K_KP_MULTIPLY, sdl.K_KP_DIVIDE,
+	}
+}
+
+// 0x02A0 - Cycle the background colour through blue, black, green and red
+func (c *Chip8) cycleBackground() {
+	c.background = (c.background + 1) % uint8(len(backgroundColors))
+	c.dirty = true
+}
+
+// 0x5XY1 - Add VY to VX one octal digit at a time, without carries
+func (c *Chip8) addOctal() {
+	x := c.v[(c.oc&0x0F00)>>8]
+	y := c.v[(c.oc&0x00F0)>>4]
+	c.v[(c.oc&0x0F00)>>8] = ((x&0x70)+(y&0x70))&0x70 | ((x + y) & 0x07)
+}
+
+// 0xBXY0 - Colour zones 8 pixels wide and 4 rows high. The low nibble of VX
+// is the first column, the high nibble how many more to colour. VX+1 does the
+// same for rows, VY is the colour.
+// 0xBXYN - Colour the zones under an 8xN sprite at VX, VY one row at a time,
+// VX+1 is the colour
+func (c *Chip8) colorZones() {
+	x := (c.oc & 0x0F00) >> 8
+	y := (c.oc & 0x00F0) >> 4
+	n := int(c.oc & 0x000F)
+
+	if n == 0 {
+		cols := c.v[x]
+		rows := c.v[(x+1)&0xF]
+		color := c.v[y] & 0x7
+		for col := int(cols & 0xF); col <= int(cols&0xF)+int(cols>>4); col++ {
+			for row := int(rows & 0xF); row <= int(rows&0xF)+int(rows>>4); row++ {
+				for line := 0; line < ZONE_HEIGHT; line++ {
+					c.zones[(row*ZONE_HEIGHT+line)%ZONE_ROWS][col%ZONE_COLUMNS] = color
+				}
+			}
+		}
+	} else {
+		col := int(c.v[x]) / ZONE_WIDTH
+		color := c.v[(x+1)&0xF] & 0x7
+		for line := 0; line < n; line++ {
+			c.zones[(int(c.v[y])+line)%ZONE_ROWS][col%ZONE_COLUMNS] = color
+		}
+	}
+	c.dirty = true
+}
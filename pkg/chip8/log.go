@@ -0,0 +1,58 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// Logger is where the CHIP-8 sends its log messages
+// The method set matches log/slog's *Logger, so one can be plugged straight
+// in: a message, then alternating keys and values
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// Drops every message, the default so embedding chippy stays quiet
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...interface{}) {}
+func (nopLogger) Info(msg string, args ...interface{})  {}
+func (nopLogger) Warn(msg string, args ...interface{})  {}
+func (nopLogger) Error(msg string, args ...interface{}) {}
+
+// Logger given to every CHIP-8 made by Init
+var defaultLogger Logger = nopLogger{}
+
+// Sets the logger Init gives new CHIP-8s, nil silences them
+func SetDefaultLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	defaultLogger = l
+}
+
+// Returns the CHIP-8's logger
+func (c *Chip8) Logger() Logger {
+	return c.log
+}
+
+// Sets the CHIP-8's logger, nil silences it
+func (c *Chip8) SetLogger(l Logger) {
+	if l == nil {
+		l = nopLogger{}
+	}
+	c.log = l
+}
@@ -0,0 +1,153 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/cdp1802"
+	"fmt"
+)
+
+// With the MachineCode quirk, 0NNN calls COSMAC VIP machine code at NNN on
+// an emulated 1802, like the original interpreter. The routine runs one 1802
+// instruction per Cycle until it returns to the interpreter with D4 (SEP R4).
+//
+// CHIP-8 memory is laid out like a 4K VIP while the quirk is on, so routines
+// can reach the interpreter's state where they expect it. The ROM sees the
+// same layout, FX55 to 0xEF0 sets the V registers like it did on the VIP:
+// 0xEA0-0xECF - Stack for the routine, R2 points at the top
+// 0xEF0-0xEFF - V0-VF
+// 0xF00-0xFFF - The display, 8 bytes per row, leftmost pixel in the top bit
+//
+// The interpreter's registers are set up for the call too: R3 is the
+// program counter, R5 the CHIP-8 PC, R6 and R7 point at VX and VY, RA is I
+// and RB.1 the display page. R5 and RA are read back when the routine
+// returns, so it can skip instructions or move I.
+
+// Where the VIP keeps its stack, V registers and display
+const VIP_STACK = 0x0ECF
+const VIP_REGISTERS = 0x0EF0
+const VIP_DISPLAY = 0x0F00
+
+// Roughly how many 1802 machine cycles a CHIP-8 instruction took on the VIP
+// TIMING_FIXED charges machine code one instruction for this many cycles
+const MACHINE_CYCLES_PER_INSTRUCTION = 16
+
+// The 1802's view of the CHIP-8's memory
+type vipBus struct {
+	c *Chip8
+}
+
+// Returns true if addr is one of the VIP's V registers or display bytes
+// rather than plain memory, they only are with the MachineCode quirk
+func (c *Chip8) vipMapped(addr uint16) bool {
+	return c.quirks.MachineCode && addr&0x0FFF >= VIP_REGISTERS
+}
+
+// Reads a byte, from memory, the V registers or the display
+func (b vipBus) Read(addr uint16) uint8 {
+	addr &= 0x0FFF
+	switch {
+	case addr >= VIP_DISPLAY:
+		var v uint8
+		row, col := (addr-VIP_DISPLAY)/8, (addr-VIP_DISPLAY)%8*8
+		for bit := uint16(0); bit < 8; bit++ {
+			v = v<<1 | b.c.display[row][col+bit]&1
+		}
+		return v
+	case addr >= VIP_REGISTERS:
+		return b.c.v[addr-VIP_REGISTERS]
+	default:
+		return b.c.memory[addr]
+	}
+}
+
+// Writes a byte, to memory, the V registers or the display
+func (b vipBus) Write(addr uint16, value uint8) {
+	addr &= 0x0FFF
+	switch {
+	case addr >= VIP_DISPLAY:
+		row, col := (addr-VIP_DISPLAY)/8, (addr-VIP_DISPLAY)%8*8
+		for bit := uint16(0); bit < 8; bit++ {
+			b.c.display[row][col+bit] = value >> (7 - bit) & 1
+		}
+		b.c.dirty = true
+	case addr >= VIP_REGISTERS:
+		b.c.v[addr-VIP_REGISTERS] = value
+	default:
+		b.c.store(addr, value)
+	}
+}
+
+// Returns true while a 0NNN machine code routine is running
+func (c *Chip8) InMachineCode() bool {
+	return c.machineCode
+}
+
+// Returns the 1802 that runs machine code routines
+func (c *Chip8) CPU() cdp1802.CPU {
+	return c.cpu
+}
+
+// 0x0NNN - Call the machine code routine at NNN
+func (c *Chip8) callMachineCode() {
+	c.log.Debug("Calling machine code", "addr", fmt.Sprintf("0x%03X", c.oc&0x0FFF))
+	c.cpu.SetR(2, VIP_STACK)
+	c.cpu.SetR(3, c.oc&0x0FFF)
+	c.cpu.SetR(5, c.pc+2)
+	c.cpu.SetR(6, VIP_REGISTERS+(c.oc&0x0F00)>>8)
+	c.cpu.SetR(7, VIP_REGISTERS+(c.oc&0x00F0)>>4)
+	c.cpu.SetR(0xA, c.i)
+	c.cpu.SetR(0xB, VIP_DISPLAY)
+	c.cpu.SetX(2)
+	c.cpu.SetP(3)
+	c.machineCode = true
+}
+
+// Runs one 1802 instruction of the current machine code routine
+func (c *Chip8) stepMachineCode() {
+	// IDL waits for the display's DMA, which comes with the next frame
+	if c.cpu.Idle() {
+		c.waitForFrame()
+		c.cpu.Wake()
+		return
+	}
+
+	// The VIP keypad: OUT 2 picks a key, and EF3 says if it's down
+	c.cpu.SetEF(3, c.ks[c.cpu.Output(2)&0xF] == 1)
+
+	c.spendMachineCycles(c.cpu.Step(vipBus{c}))
+
+	// D4 hands control back to the interpreter
+	if c.cpu.P() == 4 {
+		c.machineCode = false
+		c.pc = c.cpu.R(5) & 0x0FFF
+		c.i = c.cpu.R(0xA) & 0x0FFF
+		c.iHigh = 0
+	}
+}
+
+// Spends the machine cycles an 1802 instruction took
+func (c *Chip8) spendMachineCycles(cycles uint64) {
+	if c.timing == TIMING_VIP {
+		c.spend(cycles)
+		return
+	}
+	c.machineCycles += cycles
+	for c.machineCycles >= MACHINE_CYCLES_PER_INSTRUCTION {
+		c.machineCycles -= MACHINE_CYCLES_PER_INSTRUCTION
+		c.spend(1)
+	}
+}
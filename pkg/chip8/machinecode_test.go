@@ -0,0 +1,96 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "testing"
+
+func TestMachineCodeRoundTrip(t *testing.T) {
+	// 0300 calls a routine that adds 1 to V3 through R6, moves I on by 2
+	// through RA, and hands back with D4
+	//
+	//	200: 6341    V3 = 0x41
+	//	202: A210    I = 0x210
+	//	204: 0300    call 300
+	//	206: 1206    done
+	//
+	//	300: 06      LDN 6       D = V3
+	//	301: FC01    ADI 01
+	//	303: 56      STR 6       V3 = D
+	//	304: 8A      GLO A
+	//	305: FC02    ADI 02
+	//	307: AA      PLO A       I += 2
+	//	308: D4      SEP 4       back to the interpreter
+	rom := make([]byte, 0x109)
+	copy(rom, []byte{0x63, 0x41, 0xA2, 0x10, 0x03, 0x00, 0x12, 0x06})
+	copy(rom[0x100:], []byte{0x06, 0xFC, 0x01, 0x56, 0x8A, 0xFC, 0x02, 0xAA, 0xD4})
+	c := runQuirks(t, Quirks{MachineCode: true}, 3, rom...)
+	if !c.InMachineCode() {
+		t.Fatalf("0300 didn't call the routine")
+	}
+	for i := 0; i < 7 && c.InMachineCode(); i++ {
+		c.Step()
+	}
+
+	if c.InMachineCode() {
+		t.Fatalf("routine never returned")
+	}
+	if c.PC() != 0x206 {
+		t.Errorf("PC = %03X, want 206", c.PC())
+	}
+	if c.V(3) != 0x42 {
+		t.Errorf("V3 = %02X, want 42", c.V(3))
+	}
+	if c.I() != 0x212 {
+		t.Errorf("I = %03X, want 212", c.I())
+	}
+
+	// Without the quirk 0NNN is ignored
+	c = runQuirks(t, Quirks{}, 4, rom...)
+	if c.InMachineCode() || c.V(3) != 0x41 || c.PC() != 0x206 {
+		t.Errorf("0300 ran without the quirk, V3 = %02X, PC = %03X", c.V(3), c.PC())
+	}
+}
+
+func TestVIPMemoryLayout(t *testing.T) {
+	// V0 = 7, V1 = 9, I = 0xEF4, F155
+	rom := []byte{0x60, 0x07, 0x61, 0x09, 0xAE, 0xF4, 0xF1, 0x55}
+	c := runQuirks(t, Quirks{MachineCode: true}, 4, rom...)
+	if c.V(4) != 7 || c.V(5) != 9 {
+		t.Errorf("F155 to 0xEF4 left V4, V5 = %d, %d, want 7, 9", c.V(4), c.V(5))
+	}
+	if c.Memory(0xEF4) != 7 {
+		t.Errorf("memory at 0xEF4 = %d, want V4", c.Memory(0xEF4))
+	}
+
+	// Plain memory without the quirk
+	c = runQuirks(t, Quirks{}, 4, rom...)
+	if c.V(4) != 0 || c.Memory(0xEF4) != 7 {
+		t.Errorf("F155 to 0xEF4 without the quirk set V4 = %d, memory = %d", c.V(4), c.Memory(0xEF4))
+	}
+
+	// The display reads back through 0xF00, 8 pixels a byte
+	//
+	//	200: 6000    V0 = 0
+	//	202: F029    I = the 0 in the font
+	//	204: D005    draw it at 0, 0
+	//	206: AF00    I = 0xF00
+	//	208: F065    V0 = the first byte of the display
+	rom = []byte{0x60, 0x00, 0xF0, 0x29, 0xD0, 0x05, 0xAF, 0x00, 0xF0, 0x65}
+	c = runQuirks(t, Quirks{MachineCode: true}, 5, rom...)
+	if c.V(0) != 0xF0 {
+		t.Errorf("F065 from 0xF00 read %02X, want F0", c.V(0))
+	}
+}
@@ -0,0 +1,430 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"encoding/binary"
+	"hash"
+	"image/color"
+)
+
+// MegaChip starts out as a plain CHIP-8, until the ROM turns MegaChip mode
+// on with 0011. Then it has a 256x192 true colour display with a palette of
+// 256 colours, sprites of any size drawn in colour, and digitised sound. It
+// reads sprites, palettes and sounds from up to 16 MiB of memory, through a
+// 24 bit I set by 01NN NNNN.
+//
+// Drawing goes to a back buffer, and 00E0 shows it and starts a new frame.
+
+// MegaChip Display Size, in pixels
+const MEGA_WIDTH = 256
+const MEGA_HEIGHT = 192
+
+// MegaChip memory, everything above MEMORY_SIZE is read only ROM
+const MEGA_MEMORY_SIZE = 16 * 1024 * 1024
+
+// Sprite blend modes, set with 080N
+const (
+	// The sprite is drawn over the screen, as opaque as its colours
+	BLEND_NORMAL uint8 = iota
+
+	// The sprite is drawn 25% and 50% opaque
+	BLEND_25
+	BLEND_50
+
+	// The sprite's colours are added to the screen's
+	BLEND_ADD
+
+	// The screen's colours are multiplied by the sprite's
+	BLEND_MULTIPLY
+)
+
+// Bytes before the samples of a digitised sound: sample rate (16 bits),
+// length in samples (24 bits) and a reserved byte
+const SOUND_HEADER_SIZE = 6
+
+// Silence, for unsigned 8 bit samples
+const SILENCE = 0x80
+
+// Collision colour until 09NN sets one, the first palette colour
+const DEFAULT_COLLISION = 1
+
+// Framebuffer is a true colour display, row by row
+type Framebuffer struct {
+	Width  int
+	Height int
+	Pixels []color.RGBA
+}
+
+// MegaChip palette and display, allocated when the platform is set to
+// MegaChip
+type megaDisplay struct {
+	// Colours 1 to 255 set by 02NN, 0 is transparent
+	palette [256]color.RGBA
+
+	// Display shown and being drawn, and the palette index of every pixel
+	// drawn, for collisions
+	front [MEGA_HEIGHT][MEGA_WIDTH]color.RGBA
+	back  [MEGA_HEIGHT][MEGA_WIDTH]color.RGBA
+	index [MEGA_HEIGHT][MEGA_WIDTH]uint8
+
+	// Pixels handed out by Framebuffer, reused from call to call
+	pixels []color.RGBA
+}
+
+// Returns a copy of the display, nil stays nil
+// The copy gets a Framebuffer of its own
+func (m *megaDisplay) clone() *megaDisplay {
+	if m == nil {
+		return nil
+	}
+	out := *m
+	out.pixels = nil
+	return &out
+}
+
+// A digitised sound, played by 060N
+// Positions count 60ths of a sample, so a frame always moves by the rate
+type megaSound struct {
+	addr    uint32
+	length  uint32
+	rate    uint32
+	loop    bool
+	playing bool
+
+	// Position now, and at the start of the frame that just ended
+	pos         uint64
+	last        uint64
+	lastPlaying bool
+}
+
+// Returns true if the ROM has turned MegaChip mode on
+func (c *Chip8) MegaChip() bool {
+	return c.megaOn
+}
+
+// Returns the MegaChip display as it was last shown by 00E0
+// Blank on other platforms. The pixels are only good until the next call.
+func (c *Chip8) Framebuffer() Framebuffer {
+	fb := Framebuffer{Width: MEGA_WIDTH, Height: MEGA_HEIGHT}
+	if c.mega == nil {
+		fb.Pixels = make([]color.RGBA, MEGA_WIDTH*MEGA_HEIGHT)
+		return fb
+	}
+	if c.mega.pixels == nil {
+		c.mega.pixels = make([]color.RGBA, 0, MEGA_WIDTH*MEGA_HEIGHT)
+	}
+	fb.Pixels = c.mega.pixels[:0]
+	for y := range c.mega.front {
+		for _, p := range c.mega.front[y] {
+			// 05NN fades the whole screen
+			p.R = uint8(uint32(p.R) * uint32(c.screenAlpha) / 0xFF)
+			p.G = uint8(uint32(p.G) * uint32(c.screenAlpha) / 0xFF)
+			p.B = uint8(uint32(p.B) * uint32(c.screenAlpha) / 0xFF)
+			p.A = 0xFF
+			fb.Pixels = append(fb.Pixels, p)
+		}
+	}
+	return fb
+}
+
+// Returns the sound played during the last frame, as unsigned 8 bit samples
+// at the given rate. Silence if nothing was playing.
+func (c *Chip8) FrameSamples(rate int) []uint8 {
+	out := make([]uint8, rate/FRAME_RATE)
+	s := &c.sound
+	for k := range out {
+		out[k] = SILENCE
+		if !s.lastPlaying || s.length == 0 {
+			continue
+		}
+		idx := (s.last + uint64(s.rate)*uint64(k)/uint64(len(out))) / FRAME_RATE
+		if s.loop {
+			idx %= uint64(s.length)
+		} else if idx >= uint64(s.length) {
+			continue
+		}
+		out[k] = c.load(s.addr + uint32(idx))
+	}
+	return out
+}
+
+// Returns the byte at a 24 bit address
+func (c *Chip8) load(addr uint32) uint8 {
+	if addr < MEMORY_SIZE {
+		return c.read(uint16(addr))
+	}
+	if addr -= MEMORY_SIZE; int(addr) < len(c.megaMemory) {
+		return c.megaMemory[addr]
+	}
+	return 0
+}
+
+// Stores a byte at a 24 bit address
+// Memory above 4K is the ROM, and stays as it is
+func (c *Chip8) storeLong(addr uint32, val uint8) {
+	if addr < MEMORY_SIZE {
+		c.store(uint16(addr), val)
+	}
+}
+
+// Returns the address offset bytes on from I
+// MegaChip reaches all of its memory through the long I, everything else
+// wraps at 4K
+func (c *Chip8) addressI(offset uint16) uint32 {
+	if c.platform == PLATFORM_MEGACHIP {
+		return c.longI() + uint32(offset)
+	}
+	return uint32((c.i + offset) & 0x0FFF)
+}
+
+// Returns I, with the high byte set by 01NN NNNN
+func (c *Chip8) longI() uint32 {
+	return uint32(c.iHigh)<<16 | uint32(c.i)
+}
+
+// Adds the MegaChip state to a StateHash
+func (c *Chip8) hashMegaChip(h hash.Hash64) {
+	var on uint8
+	if c.megaOn {
+		on = 1
+	}
+	h.Write([]byte{on, c.spriteWidth, c.spriteHeight, c.screenAlpha, c.blend, c.collision})
+	if m := c.mega; m != nil {
+		for _, p := range m.palette {
+			h.Write([]byte{p.R, p.G, p.B, p.A})
+		}
+		for y := range m.index {
+			h.Write(m.index[y][:])
+		}
+		row := make([]byte, 0, MEGA_WIDTH*3)
+		for _, buff := range []*[MEGA_HEIGHT][MEGA_WIDTH]color.RGBA{&m.front, &m.back} {
+			for y := range buff {
+				row = row[:0]
+				for _, p := range buff[y] {
+					row = append(row, p.R, p.G, p.B)
+				}
+				h.Write(row)
+			}
+		}
+	}
+	binary.Write(h, binary.LittleEndian, []uint64{
+		uint64(c.sound.addr), uint64(c.sound.length), uint64(c.sound.rate),
+		c.sound.pos, uint64(len(c.megaMemory)),
+	})
+}
+
+// Resets MegaChip mode to how it powers on
+func (c *Chip8) initMegaChip() {
+	c.megaOn = false
+	c.spriteWidth = 0
+	c.spriteHeight = 0
+	c.screenAlpha = 0xFF
+	c.blend = BLEND_NORMAL
+	c.collision = DEFAULT_COLLISION
+	c.sound = megaSound{}
+	if c.mega != nil {
+		*c.mega = megaDisplay{}
+	}
+}
+
+// Clears the back buffer
+func (c *Chip8) clearMega() {
+	c.mega.back = [MEGA_HEIGHT][MEGA_WIDTH]color.RGBA{}
+	c.mega.index = [MEGA_HEIGHT][MEGA_WIDTH]uint8{}
+}
+
+// Runs the MegaChip opcodes that start with 0x0
+// Returns false for everything else
+//
+// 0x0010 - Turn MegaChip mode off
+// 0x0011 - Turn MegaChip mode on
+// 0x00E0 - Show the back buffer, and clear it for the next frame
+// 0x01NN - Set I to NN and the next 16 bits, 24 bits in all
+// 0x02NN - Load NN palette colours from I, as ARGB, from colour 1 on
+// 0x03NN - Set the sprite width to NN, 0 is 256
+// 0x04NN - Set the sprite height to NN, 0 is 256
+// 0x05NN - Set the screen alpha to NN
+// 0x060N - Play the digitised sound at I, once if N isn't 0 or looped if it is
+// 0x0700 - Stop the digitised sound
+// 0x080N - Set the sprite blend mode to N
+// 0x09NN - Set the collision colour to NN
+func (c *Chip8) megaOpcode() bool {
+	nn := uint8(c.oc & 0x00FF)
+	switch {
+	case c.oc == 0x0010:
+		c.megaOn = false
+		c.dirty = true
+
+	case c.oc == 0x0011:
+		c.initMegaChip()
+		c.megaOn = true
+		c.dirty = true
+
+	case !c.megaOn:
+		return false
+
+	case c.oc == 0x00E0:
+		c.mega.front = c.mega.back
+		c.clearMega()
+		c.dirty = true
+
+	case c.oc&0xFF00 == 0x0100:
+		c.iHigh = nn
+		c.i = uint16(c.memory[(c.pc+2)&0x0FFF])<<8 | uint16(c.memory[(c.pc+3)&0x0FFF])
+		c.pc += 2
+
+	case c.oc&0xFF00 == 0x0200:
+		addr := c.longI()
+		for n := 1; n <= int(nn) && n < len(c.mega.palette); n++ {
+			c.mega.palette[n] = color.RGBA{A: c.load(addr), R: c.load(addr + 1), G: c.load(addr + 2), B: c.load(addr + 3)}
+			addr += 4
+		}
+
+	case c.oc&0xFF00 == 0x0300:
+		c.spriteWidth = nn
+
+	case c.oc&0xFF00 == 0x0400:
+		c.spriteHeight = nn
+
+	case c.oc&0xFF00 == 0x0500:
+		c.screenAlpha = nn
+		c.dirty = true
+
+	case c.oc&0xFFF0 == 0x0600:
+		addr := c.longI()
+		c.sound = megaSound{
+			addr:    addr + SOUND_HEADER_SIZE,
+			rate:    uint32(c.load(addr))<<8 | uint32(c.load(addr+1)),
+			length:  uint32(c.load(addr+2))<<16 | uint32(c.load(addr+3))<<8 | uint32(c.load(addr+4)),
+			loop:    c.oc&0x000F == 0,
+			playing: true,
+		}
+
+	case c.oc == 0x0700:
+		c.sound.playing = false
+
+	case c.oc&0xFFF0 == 0x0800:
+		c.blend = nn & 0xF
+
+	case c.oc&0xFF00 == 0x0900:
+		c.collision = nn
+
+	default:
+		return false
+	}
+
+	c.pc += 2
+	return true
+}
+
+// 0xDXYN - Draw a sprite at VX, VY in MegaChip mode
+// The sprite is a byte per pixel, sprite width by sprite height, each a
+// palette colour or 0 for transparent. VF is set to 1 if the sprite covers a
+// pixel drawn in the collision colour.
+func (c *Chip8) drawMega() {
+	width, height := int(c.spriteWidth), int(c.spriteHeight)
+	if width == 0 {
+		width = 256
+	}
+	if height == 0 {
+		height = 256
+	}
+	x := int(c.v[(c.oc&0x0F00)>>8])
+	y := int(c.v[(c.oc&0x00F0)>>4])
+	addr := c.longI()
+
+	c.v[0xF] = 0
+	for row := 0; row < height && y+row < MEGA_HEIGHT; row++ {
+		for col := 0; col < width && x+col < MEGA_WIDTH; col++ {
+			index := c.load(addr + uint32(row*width+col))
+			if index == 0 {
+				continue
+			}
+			// Pixels nothing has been drawn on never collide
+			under := c.mega.index[y+row][x+col]
+			if under != 0 && under == c.collision {
+				c.v[0xF] = 1
+			}
+			c.mega.index[y+row][x+col] = index
+			c.mega.back[y+row][x+col] = blend(c.mega.back[y+row][x+col], c.mega.palette[index], c.blend)
+		}
+	}
+}
+
+// Moves the digitised sound on by a frame
+func (c *Chip8) tickSound() {
+	s := &c.sound
+	s.last = s.pos
+	s.lastPlaying = s.playing
+	if !s.playing {
+		return
+	}
+	s.pos += uint64(s.rate)
+	end := uint64(s.length) * FRAME_RATE
+	if s.pos >= end {
+		if s.loop && end > 0 {
+			s.pos %= end
+		} else {
+			s.playing = false
+		}
+	}
+}
+
+// Returns a sprite colour drawn over a screen colour
+func blend(dst color.RGBA, src color.RGBA, mode uint8) color.RGBA {
+	alpha := uint32(src.A)
+	switch mode {
+	case BLEND_25:
+		alpha /= 4
+	case BLEND_50:
+		alpha /= 2
+	case BLEND_ADD:
+		return color.RGBA{
+			R: add(dst.R, src.R, alpha),
+			G: add(dst.G, src.G, alpha),
+			B: add(dst.B, src.B, alpha),
+			A: 0xFF,
+		}
+	case BLEND_MULTIPLY:
+		return color.RGBA{
+			R: uint8(uint32(dst.R) * uint32(src.R) / 0xFF),
+			G: uint8(uint32(dst.G) * uint32(src.G) / 0xFF),
+			B: uint8(uint32(dst.B) * uint32(src.B) / 0xFF),
+			A: 0xFF,
+		}
+	}
+	return color.RGBA{
+		R: mix(dst.R, src.R, alpha),
+		G: mix(dst.G, src.G, alpha),
+		B: mix(dst.B, src.B, alpha),
+		A: 0xFF,
+	}
+}
+
+// Returns a colour channel alpha of the way from dst to src
+func mix(dst, src uint8, alpha uint32) uint8 {
+	return uint8((uint32(src)*alpha + uint32(dst)*(0xFF-alpha)) / 0xFF)
+}
+
+// Returns a colour channel with alpha of src added, saturating
+func add(dst, src uint8, alpha uint32) uint8 {
+	v := uint32(dst) + uint32(src)*alpha/0xFF
+	if v > 0xFF {
+		v = 0xFF
+	}
+	return uint8(v)
+}
@@ -0,0 +1,206 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"image/color"
+	"testing"
+)
+
+// Turns MegaChip mode on, loads colour 1 as opaque red, and draws a 2x2
+// sprite of it at 10,20, then shows it
+//
+//	200: 0011         MegaChip mode on
+//	202: 0100 0300    I = 0x000300
+//	206: 0201         load 1 palette colour from I
+//	208: 0100 0304    I = 0x000304
+//	20C: 0302 0402    sprites are 2x2
+//	210: 600A 6114    V0 = 10, V1 = 20
+//	214: D010         draw
+//	216: 00E0         show it
+//	218: 1218         done
+var megaROM = func() []byte {
+	rom := make([]byte, 0x108)
+	copy(rom, []byte{
+		0x00, 0x11,
+		0x01, 0x00, 0x03, 0x00,
+		0x02, 0x01,
+		0x01, 0x00, 0x03, 0x04,
+		0x03, 0x02, 0x04, 0x02,
+		0x60, 0x0A, 0x61, 0x14,
+		0xD0, 0x10,
+		0x00, 0xE0,
+		0x12, 0x18,
+	})
+	copy(rom[0x100:], []byte{0xFF, 0xFF, 0x00, 0x00, 1, 1, 1, 1})
+	return rom
+}()
+
+// Returns a MegaChip with the ROM loaded, run until it is done
+func runMega(t *testing.T) *Chip8 {
+	t.Helper()
+	c := Init()
+	c.SetPlatform(PLATFORM_MEGACHIP)
+	if _, err := c.LoadROMBytes(megaROM); err != nil {
+		t.Fatal(err)
+	}
+	for c.PC() != 0x218 {
+		c.Cycle()
+	}
+	return &c
+}
+
+func TestMegaDisplayOnlyOnMegaChip(t *testing.T) {
+	c := Init()
+	if c.mega != nil {
+		t.Fatalf("plain CHIP-8 has a MegaChip display")
+	}
+	if fb := c.Framebuffer(); len(fb.Pixels) != MEGA_WIDTH*MEGA_HEIGHT {
+		t.Errorf("framebuffer has %d pixels", len(fb.Pixels))
+	}
+
+	c.SetPlatform(PLATFORM_MEGACHIP)
+	if c.mega == nil {
+		t.Fatalf("MegaChip has no display")
+	}
+	c.SetPlatform(PLATFORM_CHIP8)
+	if c.mega != nil {
+		t.Errorf("MegaChip display kept after switching back")
+	}
+}
+
+func TestMegaDraw(t *testing.T) {
+	c := runMega(t)
+	if !c.MegaChip() {
+		t.Fatalf("MegaChip mode off")
+	}
+	red := color.RGBA{R: 0xFF, A: 0xFF}
+	fb := c.Framebuffer()
+	if p := fb.Pixels[20*MEGA_WIDTH+10]; p != red {
+		t.Errorf("pixel at 10,20 = %v, want %v", p, red)
+	}
+	if p := fb.Pixels[22*MEGA_WIDTH+12]; p != (color.RGBA{A: 0xFF}) {
+		t.Errorf("pixel at 12,22 = %v, want black", p)
+	}
+}
+
+func TestMegaSnapshot(t *testing.T) {
+	c := runMega(t)
+	snapshot := c.Snapshot()
+	hash := c.StateHash()
+
+	// Drawing again after the snapshot mustn't reach into it
+	c.SetPC(0x214)
+	c.Cycle()
+	c.Cycle()
+	if c.StateHash() == hash {
+		t.Fatalf("drawing didn't change the state")
+	}
+	if snapshot.StateHash() != hash {
+		t.Errorf("snapshot changed when the CHIP-8 did")
+	}
+
+	// Restoring twice works, the first restore doesn't share the snapshot
+	c.Restore(snapshot)
+	if c.StateHash() != hash {
+		t.Errorf("restore didn't bring the state back")
+	}
+	c.SetPC(0x214)
+	c.Cycle()
+	c.Cycle()
+	c.Restore(snapshot)
+	if c.StateHash() != hash {
+		t.Errorf("second restore didn't bring the state back")
+	}
+}
+
+func TestMegaCollision(t *testing.T) {
+	c := runMega(t)
+	if c.v[0xF] != 0 {
+		t.Errorf("drawing on a clear screen collided")
+	}
+
+	// 00E0 cleared the back buffer, so the first draw lands on nothing and
+	// the second on colour 1, the collision colour until 09NN says otherwise
+	c.SetPC(0x214)
+	c.Cycle()
+	if c.v[0xF] != 0 {
+		t.Errorf("drawing on a cleared buffer collided")
+	}
+	c.SetPC(0x214)
+	c.Cycle()
+	if c.v[0xF] != 1 {
+		t.Errorf("drawing over colour %d didn't collide", DEFAULT_COLLISION)
+	}
+}
+
+// Reads and writes through the long I
+//
+//	200: 0011         MegaChip mode on
+//	202: 0100 1000    I = 0x001000, past the first 4K
+//	206: F165         V0, V1 = memory at I
+//	208: 0100 0F00    I = 0x000F00
+//	20C: F155         memory at I = V0, V1
+//	20E: 0101 0000    I = 0x010000, in the ROM
+//	212: F033         BCD of V0 at I, ROM stays as it is
+//	214: 1214         done
+func TestMegaLongI(t *testing.T) {
+	rom := make([]byte, 0x10000-0x200+1)
+	copy(rom, []byte{
+		0x00, 0x11,
+		0x01, 0x00, 0x10, 0x00,
+		0xF1, 0x65,
+		0x01, 0x00, 0x0F, 0x00,
+		0xF1, 0x55,
+		0x01, 0x01, 0x00, 0x00,
+		0xF0, 0x33,
+		0x12, 0x14,
+	})
+	copy(rom[0x1000-0x200:], []byte{0xAB, 0xCD})
+	rom[0x10000-0x200] = 0x77
+
+	c := Init()
+	c.SetPlatform(PLATFORM_MEGACHIP)
+	if _, err := c.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	for c.PC() != 0x214 {
+		c.Cycle()
+	}
+
+	if c.v[0] != 0xAB || c.v[1] != 0xCD {
+		t.Errorf("F165 loaded %02X %02X, want AB CD", c.v[0], c.v[1])
+	}
+	if c.memory[0xF00] != 0xAB || c.memory[0xF01] != 0xCD {
+		t.Errorf("F155 stored %02X %02X, want AB CD", c.memory[0xF00], c.memory[0xF01])
+	}
+	if b := c.load(0x010000); b != 0x77 {
+		t.Errorf("F033 wrote %02X into the ROM", b)
+	}
+}
+
+func TestMegaFramebufferReused(t *testing.T) {
+	c := runMega(t)
+	if allocs := testing.AllocsPerRun(10, func() { c.Framebuffer() }); allocs != 0 {
+		t.Errorf("Framebuffer allocated %v times a call", allocs)
+	}
+
+	// A snapshot doesn't draw into the CHIP-8's pixels
+	snapshot := c.Snapshot()
+	if &snapshot.Framebuffer().Pixels[0] == &c.Framebuffer().Pixels[0] {
+		t.Errorf("snapshot shares its framebuffer")
+	}
+}
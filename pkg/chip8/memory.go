@@ -0,0 +1,153 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// Size of the CHIP-8 address space
+const MEMORY_SIZE = 4096
+
+// Where the font set lives in memory, and how big it is
+const FONT_ADDRESS = 0x000
+const FONTSET_SIZE = 80
+
+// Where ROMs are loaded, and start running
+const ROM_ADDRESS = 0x200
+
+// Returns the byte at the given address, wrapping at 4 KiB
+func (c *Chip8) Memory(addr uint16) uint8 {
+	return c.read(addr)
+}
+
+// Sets the byte at the given address, wrapping at 4 KiB
+// Counts as a write for WrittenLastFrame, like one made by the ROM
+func (c *Chip8) SetMemory(addr uint16, val uint8) {
+	c.store(addr, val)
+}
+
+// Returns a copy of n bytes of memory starting at addr, wrapping at 4 KiB
+func (c *Chip8) ReadMemory(addr uint16, n int) []uint8 {
+	out := make([]uint8, n)
+	for i := range out {
+		out[i] = c.read(addr + uint16(i))
+	}
+	return out
+}
+
+// Returns the current value of register VX
+func (c *Chip8) V(x int) uint8 {
+	return c.v[x&0xF]
+}
+
+// Sets register VX
+func (c *Chip8) SetV(x int, val uint8) {
+	c.v[x&0xF] = val
+}
+
+// Sets the CHIP-8 Index Register, wrapping at 4 KiB
+// MegaChip keeps all 16 bits, they are the bottom of a 24 bit address
+func (c *Chip8) SetI(addr uint16) {
+	c.i = addr & c.MaxI()
+}
+
+// Returns the largest value the Index Register holds on this platform
+func (c *Chip8) MaxI() uint16 {
+	if c.platform == PLATFORM_MEGACHIP {
+		return 0xFFFF
+	}
+	return 0x0FFF
+}
+
+// Sets the CHIP-8 Program Counter, the next cycle fetches from here
+// Wraps at 4 KiB, so does the fetch
+func (c *Chip8) SetPC(addr uint16) {
+	c.pc = addr & 0x0FFF
+}
+
+// Returns the current CHIP-8 Stack Pointer
+func (c *Chip8) SP() uint16 {
+	return c.sp
+}
+
+// Returns a copy of the call stack, oldest first
+// Each entry is the address of the 2NNN that made the call
+func (c *Chip8) Stack() []uint16 {
+	n := int(c.sp)
+	if n > len(c.stack) {
+		n = len(c.stack)
+	}
+	return append([]uint16(nil), c.stack[:n]...)
+}
+
+// Returns the current CHIP-8 Delay Timer
+func (c *Chip8) DelayTimer() uint8 {
+	return c.dt
+}
+
+// Sets the CHIP-8 Delay Timer
+func (c *Chip8) SetDelayTimer(val uint8) {
+	c.dt = val
+}
+
+// Returns the current CHIP-8 Sound Timer
+func (c *Chip8) SoundTimer() uint8 {
+	return c.st
+}
+
+// Sets the CHIP-8 Sound Timer
+func (c *Chip8) SetSoundTimer(val uint8) {
+	c.st = val
+}
+
+// Returns the size of the loaded ROM, in bytes
+func (c *Chip8) ROMSize() int {
+	return c.romSize
+}
+
+// Returns true if the address was written during this frame or the last one
+func (c *Chip8) WrittenLastFrame(addr uint16) bool {
+	addr &= 0x0FFF
+	bit := uint64(1) << (addr % 64)
+	return (c.writes[addr/64]|c.lastWrites[addr/64])&bit != 0
+}
+
+// Reads a byte from memory, wrapping at 4 KiB
+// With the MachineCode quirk the top of memory is the VIP's V registers and
+// display, like machine code sees it
+func (c *Chip8) read(addr uint16) uint8 {
+	addr &= 0x0FFF
+	if c.vipMapped(addr) {
+		return vipBus{c}.Read(addr)
+	}
+	return c.memory[addr]
+}
+
+// Writes a byte to memory, remembering the write for WrittenLastFrame
+// With the MachineCode quirk the top of memory is the VIP's V registers and
+// display, like machine code sees it
+func (c *Chip8) store(addr uint16, val uint8) {
+	addr &= 0x0FFF
+	if c.vipMapped(addr) {
+		vipBus{c}.Write(addr, val)
+		return
+	}
+	c.memory[addr] = val
+	c.writes[addr/64] |= uint64(1) << (addr % 64)
+}
+
+// Starts tracking writes for a new frame
+func (c *Chip8) rotateWrites() {
+	c.lastWrites = c.writes
+	c.writes = [MEMORY_SIZE / 64]uint64{}
+}
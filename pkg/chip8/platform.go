@@ -0,0 +1,109 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "fmt"
+
+// Platform is the machine and interpreter a ROM was written for
+// Quirks cover small differences in behaviour, platforms add hardware
+type Platform int
+
+const (
+	// The original CHIP-8, and everything that only changed its quirks
+	PLATFORM_CHIP8 Platform = iota
+
+	// CHIP-8X on a COSMAC VIP with the VP-590 colour board, the VP-595
+	// sound board and a second keypad. See chip8x.go
+	PLATFORM_CHIP8X
+
+	// HIRES CHIP-8, a patched VIP interpreter with a 64x64 display
+	// ROMs start with 1260, and clear the screen with 0230
+	PLATFORM_HIRES
+
+	// MegaChip, a CHIP-8 that can switch to a 256x192 true colour display
+	// with digitised sound and 16 MiB of memory. See megachip.go
+	PLATFORM_MEGACHIP
+)
+
+// Where CHIP-8X ROMs are loaded, the bigger interpreter needs 0x200-0x2FF
+const CHIP8X_ROM_ADDRESS = 0x300
+
+// Where HIRES CHIP-8 programs start
+// HIRES ROMs carry the 1802 code that patches the interpreter in
+// 0x202-0x2BF, behind a 1260 jump. We do the patch's job ourselves, so the
+// jump goes straight to the program after it.
+const HIRES_START = 0x2C0
+
+// Returns the name of the platform, as used on the command line
+func (p Platform) String() string {
+	switch p {
+	case PLATFORM_CHIP8:
+		return "chip8"
+	case PLATFORM_CHIP8X:
+		return "chip8x"
+	case PLATFORM_HIRES:
+		return "hires"
+	case PLATFORM_MEGACHIP:
+		return "megachip"
+	default:
+		return fmt.Sprintf("Platform(%d)", int(p))
+	}
+}
+
+// Parses a platform name, as returned by Platform.String()
+func ParsePlatform(name string) (Platform, error) {
+	switch name {
+	case "chip8":
+		return PLATFORM_CHIP8, nil
+	case "chip8x":
+		return PLATFORM_CHIP8X, nil
+	case "hires":
+		return PLATFORM_HIRES, nil
+	case "megachip":
+		return PLATFORM_MEGACHIP, nil
+	default:
+		return PLATFORM_CHIP8, fmt.Errorf("unknown platform %q, expected chip8, chip8x, hires or megachip :(", name)
+	}
+}
+
+// Returns the current CHIP-8 Platform
+func (c *Chip8) Platform() Platform {
+	return c.platform
+}
+
+// Switches the CHIP-8 to another platform
+// This resets the CHIP-8 and loads the ROM again where the platform wants it,
+// so call it before running anything
+func (c *Chip8) SetPlatform(p Platform) {
+	c.platform = p
+	c.Reset()
+}
+
+// Returns where the current platform loads ROMs, and starts running
+func (c *Chip8) ROMAddress() uint16 {
+	if c.platform == PLATFORM_CHIP8X {
+		return CHIP8X_ROM_ADDRESS
+	}
+	return ROM_ADDRESS
+}
+
+// Returns the biggest ROM the current platform can load
+func (c *Chip8) MaxROMSize() int {
+	if c.platform == PLATFORM_MEGACHIP {
+		return MEGA_MEMORY_SIZE - int(c.ROMAddress())
+	}
+	return MEMORY_SIZE - int(c.ROMAddress())
+}
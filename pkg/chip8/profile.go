@@ -0,0 +1,120 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// Profiler counts where a CHIP-8 spends its time
+// Cycle counts are in the units of Cycles(), so depend on the timing mode
+type Profiler struct {
+	// Instructions executed, per address
+	Exec [MEMORY_SIZE]uint64
+
+	// Cycles spent, per address
+	ExecCycles [MEMORY_SIZE]uint64
+
+	// Last opcode executed, per address
+	Code [MEMORY_SIZE]uint16
+
+	// Bytes read and written by instructions (DXYN, FX33, FX55, FX65),
+	// per address. Instruction fetches count in Exec, not here
+	Reads  [MEMORY_SIZE]uint64
+	Writes [MEMORY_SIZE]uint64
+
+	// Instructions executed, per opcode
+	Opcodes map[uint16]uint64
+
+	// Subroutines called with 2NNN, by address
+	Subroutines map[uint16]*SubroutineProfile
+
+	// Subroutines we are in right now
+	calls []profileCall
+}
+
+// SubroutineProfile is the time spent in one subroutine
+type SubroutineProfile struct {
+	// Times called
+	Calls uint64
+
+	// Cycles from the 2NNN to the 00EE, including the subroutines it calls
+	Cycles uint64
+}
+
+// A subroutine call that hasn't returned yet
+type profileCall struct {
+	addr  uint16
+	start uint64
+}
+
+// Creates an empty profiler
+func NewProfiler() *Profiler {
+	return &Profiler{
+		Opcodes:     map[uint16]uint64{},
+		Subroutines: map[uint16]*SubroutineProfile{},
+	}
+}
+
+// Returns the CHIP-8's profiler, nil if profiling is off
+func (c *Chip8) Profiler() *Profiler {
+	return c.profiler
+}
+
+// Sets the profiler, nil turns profiling off
+func (c *Chip8) SetProfiler(p *Profiler) {
+	c.profiler = p
+}
+
+// Counts the current instruction, before it executes
+func (p *Profiler) record(c *Chip8, cost uint64) {
+	pc := c.pc & 0x0FFF
+	p.Exec[pc]++
+	p.ExecCycles[pc] += cost
+	p.Code[pc] = c.oc
+	p.Opcodes[c.oc]++
+
+	x := (c.oc & 0x0F00) >> 8
+	switch {
+	case c.oc&0xF000 == 0x2000:
+		p.calls = append(p.calls, profileCall{addr: c.oc & 0x0FFF, start: c.cycles})
+
+	case c.oc == 0x00EE:
+		if n := len(p.calls); n > 0 {
+			call := p.calls[n-1]
+			p.calls = p.calls[:n-1]
+			sub := p.Subroutines[call.addr]
+			if sub == nil {
+				sub = &SubroutineProfile{}
+				p.Subroutines[call.addr] = sub
+			}
+			sub.Calls++
+			sub.Cycles += c.cycles + cost - call.start
+		}
+
+	case c.oc&0xF000 == 0xD000:
+		p.touch(&p.Reads, c.i, c.oc&0x000F)
+	case c.oc&0xF0FF == 0xF033:
+		p.touch(&p.Writes, c.i, 3)
+	case c.oc&0xF0FF == 0xF055:
+		p.touch(&p.Writes, c.i, x+1)
+	case c.oc&0xF0FF == 0xF065:
+		p.touch(&p.Reads, c.i, x+1)
+	}
+}
+
+// Counts an access to n bytes starting at addr
+func (p *Profiler) touch(heat *[MEMORY_SIZE]uint64, addr uint16, n uint16) {
+	for i := uint16(0); i < n; i++ {
+		heat[(addr+i)&0x0FFF]++
+	}
+}
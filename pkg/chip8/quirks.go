@@ -0,0 +1,110 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Quirks toggles behaviour that differs between CHIP-8 interpreters
+// The zero value is the modern behaviour most ROMs and test suites expect
+type Quirks struct {
+	// DXYN waits for the next 60Hz interrupt before drawing, like the
+	// original COSMAC VIP interpreter. This limits sprites to 60 per second.
+	DisplayWait bool `json:"displayWait"`
+
+	// DXYN wraps sprite pixels that cross the right or bottom edge around to
+	// the other side of the screen, instead of clipping them. The starting
+	// position always wraps.
+	WrapSprites bool `json:"wrapSprites"`
+
+	// 0NNN runs the COSMAC VIP machine code at NNN on an emulated 1802,
+	// instead of being ignored. See machinecode.go
+	MachineCode bool `json:"machineCode"`
+
+	// 8XY6 and 8XYE shift VY and store the result in VX, like the COSMAC
+	// VIP, instead of shifting VX in place
+	ShiftVY bool `json:"shiftVY"`
+
+	// FX55 and FX65 leave I pointing after the last register, at I+X+1,
+	// instead of leaving it alone
+	MemoryIncrement bool `json:"memoryIncrement"`
+
+	// FX55 and FX65 leave I at I+X, like CHIP-48's off by one
+	// Wins over MemoryIncrement if both are on
+	MemoryIncrementByX bool `json:"memoryIncrementByX"`
+
+	// BNNN jumps to XNN plus VX, like CHIP-48 and SUPER-CHIP, instead of NNN
+	// plus V0
+	JumpVX bool `json:"jumpVX"`
+
+	// 8XY1, 8XY2 and 8XY3 reset VF to 0, like the COSMAC VIP
+	ResetVF bool `json:"resetVF"`
+}
+
+// Quirk presets for well known interpreters, selected by name
+var Profiles = map[string]Quirks{
+	// Modern interpreters, what most test ROMs expect
+	"modern": {},
+
+	// The original CHIP-8 interpreter on the COSMAC VIP
+	"vip": {DisplayWait: true, ShiftVY: true, MemoryIncrement: true, ResetVF: true},
+
+	// CHIP-48 on the HP-48 calculators
+	"chip48": {MemoryIncrementByX: true, JumpVX: true},
+
+	// SUPER-CHIP 1.1
+	"schip": {JumpVX: true},
+
+	// Octo's XO-CHIP
+	"xochip": {WrapSprites: true, ShiftVY: true, MemoryIncrement: true},
+}
+
+// Returns the quirks for the given profile name
+func ProfileQuirks(name string) (Quirks, error) {
+	q, ok := Profiles[name]
+	if !ok {
+		return Quirks{}, fmt.Errorf("unknown profile %q, expected one of %v :(", name, ProfileNames())
+	}
+	return q, nil
+}
+
+// Returns the names of all quirk profiles, sorted
+func ProfileNames() []string {
+	names := make([]string, 0, len(Profiles))
+	for name := range Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the current CHIP-8 Quirks
+func (c *Chip8) Quirks() Quirks {
+	return c.quirks
+}
+
+// Sets the CHIP-8 Quirks
+func (c *Chip8) SetQuirks(q Quirks) {
+	c.quirks = q
+}
+
+// Returns true if DXYN should wait for the next frame before drawing
+// This is up to the quirk alone, the vip profile turns it on
+func (c *Chip8) displayWait() bool {
+	return c.quirks.DisplayWait
+}
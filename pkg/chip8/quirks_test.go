@@ -0,0 +1,101 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "testing"
+
+// Runs the ROM for the given number of instructions with the quirks on
+func runQuirks(t *testing.T, q Quirks, steps int, rom ...byte) *Chip8 {
+	t.Helper()
+	c := Init()
+	c.SetQuirks(q)
+	if _, err := c.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < steps; i++ {
+		c.Step()
+	}
+	return &c
+}
+
+func TestShiftVY(t *testing.T) {
+	// V0 = 1, V1 = 4, V0 = V1 >> 1 or V0 >> 1
+	rom := []byte{0x60, 0x01, 0x61, 0x04, 0x80, 0x16}
+	if c := runQuirks(t, Quirks{}, 3, rom...); c.V(0) != 0 || c.V(0xF) != 1 {
+		t.Errorf("modern: V0, VF = %d, %d, want 0, 1", c.V(0), c.V(0xF))
+	}
+	if c := runQuirks(t, Quirks{ShiftVY: true}, 3, rom...); c.V(0) != 2 || c.V(0xF) != 0 {
+		t.Errorf("ShiftVY: V0, VF = %d, %d, want 2, 0", c.V(0), c.V(0xF))
+	}
+}
+
+func TestMemoryIncrement(t *testing.T) {
+	// I = 300, store V0-V2
+	rom := []byte{0xA3, 0x00, 0xF2, 0x55}
+	tests := []struct {
+		q Quirks
+		i uint16
+	}{
+		{Quirks{}, 0x300},
+		{Quirks{MemoryIncrement: true}, 0x303},
+		{Quirks{MemoryIncrementByX: true}, 0x302},
+		{Quirks{MemoryIncrement: true, MemoryIncrementByX: true}, 0x302},
+	}
+	for _, tt := range tests {
+		if c := runQuirks(t, tt.q, 2, rom...); c.I() != tt.i {
+			t.Errorf("%+v: I = %03X, want %03X", tt.q, c.I(), tt.i)
+		}
+	}
+}
+
+func TestJumpVX(t *testing.T) {
+	// V0 = 1, V3 = 8, jump B300
+	rom := []byte{0x60, 0x01, 0x63, 0x08, 0xB3, 0x00}
+	if c := runQuirks(t, Quirks{}, 3, rom...); c.PC() != 0x301 {
+		t.Errorf("modern: PC = %03X, want 301", c.PC())
+	}
+	if c := runQuirks(t, Quirks{JumpVX: true}, 3, rom...); c.PC() != 0x308 {
+		t.Errorf("JumpVX: PC = %03X, want 308", c.PC())
+	}
+}
+
+func TestResetVF(t *testing.T) {
+	// VF = 7, V0 |= V1
+	rom := []byte{0x6F, 0x07, 0x80, 0x11}
+	if c := runQuirks(t, Quirks{}, 2, rom...); c.V(0xF) != 7 {
+		t.Errorf("modern: VF = %d, want 7", c.V(0xF))
+	}
+	if c := runQuirks(t, Quirks{ResetVF: true}, 2, rom...); c.V(0xF) != 0 {
+		t.Errorf("ResetVF: VF = %d, want 0", c.V(0xF))
+	}
+}
+
+func TestProfilesDiffer(t *testing.T) {
+	seen := map[Quirks]string{}
+	for _, name := range ProfileNames() {
+		q, err := ProfileQuirks(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, ok := seen[q]; ok {
+			t.Errorf("profiles %s and %s have the same quirks", name, other)
+		}
+		seen[q] = name
+	}
+	if _, err := ProfileQuirks("nope"); err == nil {
+		t.Errorf("found a profile called nope")
+	}
+}
@@ -0,0 +1,48 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// The random number generator for CXNN is a xorshift64* kept inside Chip8,
+// so copying a Chip8 copies the generator too, and the same seed always
+// gives the same game.
+
+// Returns the seed the random number generator was last seeded with
+func (c *Chip8) Seed() int64 {
+	return c.seed
+}
+
+// Seeds the random number generator used by CXNN
+func (c *Chip8) SetSeed(seed int64) {
+	c.seed = seed
+
+	// Run the seed through splitmix64, so small seeds still give a
+	// well mixed, non-zero state
+	z := uint64(seed) + 0x9E3779B97F4A7C15
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	c.rng = z ^ (z >> 31)
+	if c.rng == 0 {
+		c.rng = 1
+	}
+}
+
+// Returns the next random byte
+func (c *Chip8) random() uint8 {
+	c.rng ^= c.rng >> 12
+	c.rng ^= c.rng << 25
+	c.rng ^= c.rng >> 27
+	return uint8((c.rng * 0x2545F4914F6CDD1D) >> 56)
+}
@@ -0,0 +1,95 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// Returns a copy of the whole CHIP-8 state, for Restore
+// A Chip8 is a plain value, so this is cheap enough to do every frame. The
+// MegaChip display is the only part behind a pointer, and gets copied too.
+func (c *Chip8) Snapshot() Chip8 {
+	snapshot := *c
+	snapshot.mega = c.mega.clone()
+	return snapshot
+}
+
+// Puts the CHIP-8 back how it was when the snapshot was taken
+// The logger, tracer and profiler attached now are kept, and the snapshot
+// can be restored again
+func (c *Chip8) Restore(snapshot Chip8) {
+	log, tracer, profiler := c.log, c.tracer, c.profiler
+	*c = snapshot
+	c.mega = snapshot.mega.clone()
+	c.log, c.tracer, c.profiler = log, tracer, profiler
+	c.dirty = true
+}
+
+// Returns the keypad state as a bit mask, bit N is key N
+func (c *Chip8) Keys() uint16 {
+	var mask uint16
+	for key, state := range c.ks {
+		if state != 0 {
+			mask |= 1 << uint(key)
+		}
+	}
+	return mask
+}
+
+// Sets the whole keypad state from a bit mask, bit N is key N
+func (c *Chip8) SetKeys(mask uint16) {
+	for key := range c.ks {
+		c.ks[key] = int(mask>>uint(key)) & 1
+	}
+}
+
+// Returns a hash of everything that decides what the CHIP-8 does next
+// Two CHIP-8s with the same hash will stay in step given the same inputs
+func (c *Chip8) StateHash() uint64 {
+	h := fnv.New64a()
+	h.Write(c.memory[:])
+	for row := range c.display {
+		h.Write(c.display[row][:])
+	}
+	h.Write(c.v[:])
+	for row := range c.zones {
+		h.Write(c.zones[row][:])
+	}
+	h.Write([]byte{c.background, c.tone})
+	for _, state := range c.ks2 {
+		h.Write([]byte{uint8(state)})
+	}
+	binary.Write(h, binary.LittleEndian, c.stack)
+	binary.Write(h, binary.LittleEndian, []uint64{
+		uint64(c.pc), uint64(c.i), uint64(c.sp),
+		uint64(c.dt), uint64(c.st), uint64(c.Keys()),
+		c.frame, c.frameCycles, c.rng, uint64(c.platform), uint64(c.iHigh),
+	})
+	if c.quirks.MachineCode {
+		var running uint64
+		if c.machineCode {
+			running = 1
+		}
+		binary.Write(h, binary.LittleEndian, c.cpu)
+		binary.Write(h, binary.LittleEndian, []uint64{running, c.machineCycles})
+	}
+	if c.platform == PLATFORM_MEGACHIP {
+		c.hashMegaChip(h)
+	}
+	return h.Sum64()
+}
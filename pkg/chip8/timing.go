@@ -0,0 +1,261 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import "fmt"
+
+// CHIP-8 Display Refresh Rate (Hz)
+// The timers also tick at this rate
+const FRAME_RATE = 60
+
+// COSMAC VIP machine cycles per 60Hz frame
+// The 1802 runs at 1.7609 MHz and a machine cycle is 8 clocks
+const VIP_CYCLES_PER_FRAME = 3668
+
+// COSMAC VIP machine cycles per frame that the interpreter never sees
+// The CDP1861 steals 1024 cycles for display DMA (128 lines x 8 bytes),
+// and the interrupt routine that feeds it and ticks the timers uses the rest
+const VIP_FRAME_OVERHEAD = 1070
+
+// TimingMode decides how much each instruction costs
+type TimingMode int
+
+const (
+	// Every instruction costs the same, running ClockSpeed() instructions per second
+	TIMING_FIXED TimingMode = iota
+
+	// Every instruction costs its COSMAC VIP machine-cycle count
+	// Sprites wait for the 60Hz interrupt before drawing, like the original interpreter
+	TIMING_VIP
+)
+
+// Returns the name of the timing mode, as used on the command line
+func (m TimingMode) String() string {
+	switch m {
+	case TIMING_FIXED:
+		return "fixed"
+	case TIMING_VIP:
+		return "vip"
+	default:
+		return fmt.Sprintf("TimingMode(%d)", int(m))
+	}
+}
+
+// Parses a timing mode name, as returned by TimingMode.String()
+func ParseTimingMode(name string) (TimingMode, error) {
+	switch name {
+	case "fixed":
+		return TIMING_FIXED, nil
+	case "vip":
+		return TIMING_VIP, nil
+	default:
+		return TIMING_FIXED, fmt.Errorf("unknown timing mode %q :(", name)
+	}
+}
+
+// COSMAC VIP machine cycles the interpreter spends fetching and decoding
+// every instruction, before it jumps to the code that executes it
+const VIP_FETCH_CYCLES = 40
+
+// COSMAC VIP machine cycles to execute each instruction, after the fetch
+// and decode. From Laurence Scotford's walk through the VIP interpreter,
+// "Chip-8 on the COSMAC VIP", which counts the 1802 instructions behind
+// every CHIP-8 one (2 machine cycles each, 3 for long branches).
+// Instructions with variable cost (DXYN, FX55, FX65) are finished off in
+// vipCost. The 0 instructions are keyed by the whole opcode, everything
+// else that starts with 0 is a machine code call.
+var vipCycles = map[uint16]uint64{
+	0x0230: 24,
+	0x02A0: 24,
+	0x00E0: 24,
+	0x00EE: 10,
+	0x1000: 12,
+	0x2000: 26,
+	0x3000: 10,
+	0x4000: 10,
+	0x5000: 14,
+	0x6000: 6,
+	0x7000: 10,
+	0x8000: 44,
+	0x9000: 14,
+	0xA000: 12,
+	0xB000: 22,
+	0xC000: 36,
+	0xD000: 26,
+	0xE000: 14,
+	0xF007: 10,
+	0xF00A: 10,
+	0xF015: 10,
+	0xF018: 10,
+	0xF01E: 16,
+	0xF029: 16,
+	0xF033: 80,
+	0xF055: 14,
+	0xF065: 14,
+	0xF0F8: 10,
+}
+
+// COSMAC VIP machine cycles for 0NNN to hand over to machine code: point R3
+// at NNN and SEP R3. The routine's own cycles are counted as it runs.
+const VIP_MACHINE_CODE_CALL = 4
+
+// Returns the current CHIP-8 Timing Mode
+func (c *Chip8) Timing() TimingMode {
+	return c.timing
+}
+
+// Sets the CHIP-8 Timing Mode
+func (c *Chip8) SetTiming(mode TimingMode) {
+	c.timing = mode
+	c.frameCycles = 0
+}
+
+// Returns the number of cycles spent since Init
+// In TIMING_FIXED this counts instructions, in TIMING_VIP machine cycles
+func (c *Chip8) Cycles() uint64 {
+	return c.cycles
+}
+
+// Returns the number of 60Hz frames elapsed since Init
+func (c *Chip8) Frame() uint64 {
+	return c.frame
+}
+
+// Returns the number of instructions executed since Init
+// Unlike Cycles, this doesn't count time spent waiting for the display
+func (c *Chip8) Instructions() uint64 {
+	return c.instructions
+}
+
+// Runs cycles until one instruction executes, waiting for the display first
+// if it has to. In a machine code routine this runs one 1802 instruction
+// instead, so routines that spin (polling EF3 for a key) can still be stepped.
+func (c *Chip8) Step() {
+	if c.machineCode {
+		c.Cycle()
+		return
+	}
+	executed := c.instructions
+	for c.instructions == executed {
+		c.Cycle()
+	}
+}
+
+// Runs cycles until the next 60Hz frame starts
+func (c *Chip8) RunFrame() {
+	frame := c.frame
+	for c.frame == frame {
+		c.Cycle()
+	}
+}
+
+// Returns the cost of the current opcode in the current timing mode
+func (c *Chip8) opcodeCost() uint64 {
+	if c.timing != TIMING_VIP {
+		return 1
+	}
+	return c.vipCost()
+}
+
+// Returns the COSMAC VIP machine-cycle cost of the current opcode, fetch
+// and decode included
+func (c *Chip8) vipCost() uint64 {
+	return VIP_FETCH_CYCLES + c.vipExecuteCost()
+}
+
+// Returns the COSMAC VIP machine cycles to execute the current opcode
+func (c *Chip8) vipExecuteCost() uint64 {
+	switch c.oc & 0xF000 {
+	case 0x0000:
+		if cost, ok := vipCycles[c.oc]; ok {
+			return cost
+		}
+		return VIP_MACHINE_CODE_CALL
+
+	case 0xD000:
+		// Every sprite row is shifted into place one bit at a time,
+		// so sprites that are not byte aligned cost more per row
+		x := uint64(c.v[(c.oc&0x0F00)>>8] & 0x7)
+		n := uint64(c.oc & 0x000F)
+		return vipCycles[0xD000] + n*(16+4*x)
+
+	case 0xF000:
+		cost := vipCycles[c.oc&0xF0FF]
+		if c.oc&0x00FF == 0x0055 || c.oc&0x00FF == 0x0065 {
+			// One load/store loop iteration per register
+			cost += 14 * uint64(((c.oc&0x0F00)>>8)+1)
+		}
+		return cost
+
+	default:
+		return vipCycles[c.oc&0xF000]
+	}
+}
+
+// Returns how many cycle units fit in one 60Hz frame, and how many units
+// a single cycle is worth. TIMING_FIXED scales instructions by the frame rate
+// so clock speeds that are not a multiple of 60 keep their average speed.
+func (c *Chip8) frameBudget() (budget uint64, scale uint64) {
+	if c.timing == TIMING_VIP {
+		return VIP_CYCLES_PER_FRAME - VIP_FRAME_OVERHEAD, 1
+	}
+	return uint64(c.clockSpeed), FRAME_RATE
+}
+
+// Spends the given cycles, ticking the frame for every 60Hz boundary crossed
+func (c *Chip8) spend(cost uint64) {
+	budget, scale := c.frameBudget()
+	c.cycles += cost
+	c.frameCycles += cost * scale
+	for c.frameCycles >= budget {
+		c.frameCycles -= budget
+		c.tickFrame()
+	}
+}
+
+// Burns the rest of the current frame, as if the CPU idled until the next
+// 60Hz interrupt
+func (c *Chip8) waitForFrame() {
+	budget, scale := c.frameBudget()
+	c.cycles += (budget - c.frameCycles + scale - 1) / scale
+	c.frameCycles = 0
+	c.tickFrame()
+}
+
+// Returns true if we are sitting right on a 60Hz frame boundary
+func (c *Chip8) atFrameStart() bool {
+	return c.frameCycles == 0
+}
+
+// Advances to the next 60Hz frame, decrementing both timers
+func (c *Chip8) tickFrame() {
+	c.frame++
+	c.rotateWrites()
+	c.tickSound()
+
+	if c.dt > 0 {
+		c.dt -= 1
+	}
+	if c.st > 0 {
+		// TODO: Add option for actually making a "beep" sound
+		if c.platform == PLATFORM_CHIP8X {
+			c.log.Debug("Beep!", "st", c.st, "hz", c.ToneFrequency())
+		} else {
+			c.log.Debug("Beep!", "st", c.st)
+		}
+		c.st -= 1
+	}
+}
@@ -0,0 +1,76 @@
+package chip8
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// TraceRecord describes one executed instruction
+type TraceRecord struct {
+	// Cycle count when the instruction started, see Cycles()
+	Cycle uint64
+
+	// Where the instruction was, and what it was
+	PC     uint16
+	Opcode uint16
+
+	// Registers before and after the instruction
+	Before [16]uint8
+	After  [16]uint8
+
+	// Index register and timers after the instruction
+	I  uint16
+	DT uint8
+	ST uint8
+}
+
+// Returns a bit mask of the V registers the instruction changed, bit N is VN
+func (r *TraceRecord) Changed() uint16 {
+	var mask uint16
+	for i := range r.Before {
+		if r.Before[i] != r.After[i] {
+			mask |= 1 << uint(i)
+		}
+	}
+	return mask
+}
+
+// Tracer gets a record for every instruction the CHIP-8 executes
+type Tracer interface {
+	Trace(r *TraceRecord)
+}
+
+// Sets the tracer, nil turns tracing off
+func (c *Chip8) SetTracer(t Tracer) {
+	c.tracer = t
+}
+
+// Starts a trace record for the instruction about to execute
+func (c *Chip8) traceStart() {
+	c.traceRecord = TraceRecord{
+		Cycle:  c.cycles,
+		PC:     c.pc,
+		Opcode: c.oc,
+		Before: c.v,
+	}
+}
+
+// Finishes the trace record, and hands it to the tracer
+func (c *Chip8) traceEnd() {
+	r := &c.traceRecord
+	r.After = c.v
+	r.I = c.i
+	r.DT = c.dt
+	r.ST = c.st
+	c.tracer.Trace(r)
+}
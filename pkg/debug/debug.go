@@ -20,57 +20,119 @@ import (
 	"fmt"
 
 	"github.com/veandco/go-sdl2/sdl"
-	"github.com/veandco/go-sdl2/ttf"
 )
 
-// RenderOverlay renders the CHIP-8 debug overlay, returns an SDL2 texture
-func RenderOverlay(chippy *chip8.Chip8, renderer *sdl.Renderer) *sdl.Texture {
-	// Load font
-	font, err := ttf.OpenFont("./fonts/VT323.ttf", 20)
+// Overlay font size (pt)
+const FONT_SIZE = 20
+
+// Overlay draws CHIP-8 state on top of the display
+// It owns the font and a texture per glyph, so text is only rendered once,
+// and recomposes itself only when the values shown change.
+type Overlay struct {
+	renderer *sdl.Renderer
+	text     *glyphCache
+
+	// Lines currently composed into texture
+	lines   []string
+	texture *sdl.Texture
+	width   int32
+	height  int32
+}
+
+// Creates a debug overlay for the given renderer
+func NewOverlay(renderer *sdl.Renderer) (*Overlay, error) {
+	text, err := newGlyphCache(renderer, FONT_SIZE)
 	if err != nil {
-		fmt.Println("Failed to load font: " + err.Error())
+		return nil, err
 	}
-	defer font.Close()
+	return &Overlay{renderer: renderer, text: text}, nil
+}
 
-	// Create debug overlay surface
-	width := 100
-	height := 100
-	overlay, err := sdl.CreateRGBSurface(0, int32(width), int32(height), 32, 0, 0, 0, 0)
-	if err != nil {
-		fmt.Println("Failed to create debug overlay: " + err.Error())
+// Updates the overlay with the current CHIP-8 state
+// Returns true if anything shown changed
+func (o *Overlay) Update(chippy *chip8.Chip8) bool {
+	lines := []string{
+		fmt.Sprintf("OP [0x%X]", chippy.Opcode()),
+		fmt.Sprintf("PC [0x%X]", chippy.PC()),
+		fmt.Sprintf("I [0x%X]", chippy.I()),
 	}
+	if equal(lines, o.lines) {
+		return false
+	}
+	o.lines = lines
 
-	// Pull current CHIP-8 state
-	opcodeStr := fmt.Sprintf("OP [0x%X]", chippy.Opcode())
-	pcStr := fmt.Sprintf("PC [0x%X]", chippy.PC())
-	iStr := fmt.Sprintf("I [0x%X]", chippy.I())
+	if err := o.compose(); err != nil {
+		fmt.Println("Failed to draw debug overlay: " + err.Error())
+	}
+	return true
+}
 
-	// Render text for each line
-	opcode, err := font.RenderUTF8Blended(opcodeStr, sdl.Color{R: 255, G: 255, B: 255, A: 255})
-	if err != nil {
-		fmt.Println("Failed to render surface: " + err.Error())
+// Copies the overlay to the top left of the renderer
+func (o *Overlay) Draw() {
+	if o.texture == nil {
+		return
 	}
-	pc, err := font.RenderUTF8Blended(pcStr, sdl.Color{R: 255, G: 255, B: 255, A: 255})
-	if err != nil {
-		fmt.Println("Failed to render surface: " + err.Error())
+	o.renderer.Copy(o.texture, nil, &sdl.Rect{X: 0, Y: 0, W: o.width, H: o.height})
+}
+
+// Frees the font, glyphs and overlay texture
+func (o *Overlay) Close() {
+	o.text.close()
+	if o.texture != nil {
+		o.texture.Destroy()
+		o.texture = nil
 	}
-	i, err := font.RenderUTF8Blended(iStr, sdl.Color{R: 255, G: 255, B: 255, A: 255})
-	if err != nil {
-		fmt.Println("Failed to render surface: " + err.Error())
+}
+
+// Draws the current lines into the overlay texture, from cached glyphs
+func (o *Overlay) compose() error {
+	// Grow the texture if the text no longer fits
+	width, height := int32(0), o.text.h*int32(len(o.lines))
+	for _, line := range o.lines {
+		if w := o.text.w * int32(len([]rune(line))); w > width {
+			width = w
+		}
+	}
+	if o.texture == nil || width > o.width || height > o.height {
+		if o.texture != nil {
+			o.texture.Destroy()
+		}
+		texture, err := o.renderer.CreateTexture(sdl.PIXELFORMAT_RGBA8888, sdl.TEXTUREACCESS_TARGET, width, height)
+		if err != nil {
+			return err
+		}
+		texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+		texture.SetAlphaMod(200)
+		o.texture, o.width, o.height = texture, width, height
 	}
 
-	// Render text to overlay for each line
-	opcode.Blit(nil, overlay, &sdl.Rect{X: 0, Y: 0, W: 100, H: 100})
-	pc.Blit(nil, overlay, &sdl.Rect{X: 0, Y: 20, W: 100, H: 100})
-	i.Blit(nil, overlay, &sdl.Rect{X: 0, Y: 40, W: 100, H: 100})
+	// Draw into the overlay texture instead of the window
+	if err := o.renderer.SetRenderTarget(o.texture); err != nil {
+		return err
+	}
+	defer o.renderer.SetRenderTarget(nil)
 
-	// Create SDL2 texture from overlay
-	texture, err := renderer.CreateTextureFromSurface(overlay)
-	if err != nil {
-		fmt.Println("Failed to create texture: " + err.Error())
+	o.renderer.SetDrawColor(0, 0, 0, 0)
+	o.renderer.Clear()
+	white := sdl.Color{R: 255, G: 255, B: 255, A: 255}
+	for y, line := range o.lines {
+		if err := o.text.draw(0, int32(y)*o.text.h, line, white); err != nil {
+			return err
+		}
 	}
-	texture.SetBlendMode(sdl.BLENDMODE_BLEND)
-	texture.SetAlphaMod(200)
 
-	return texture
+	return nil
+}
+
+// Returns true if both sets of lines are the same
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,111 @@
+package debug
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"math"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Heatmap cells per row, 64x64 cells cover the 4 KiB address space
+const HEATMAP_WIDTH = 64
+
+// Heatmap draws the profiler's view of memory as a grid, one cell per address
+// Red is instructions executed, green bytes read and blue bytes written,
+// each on a log scale against the busiest address. The PC is white.
+type Heatmap struct {
+	renderer *sdl.Renderer
+	texture  *sdl.Texture
+	pixels   []byte
+}
+
+// Creates a heatmap for the given renderer
+func NewHeatmap(renderer *sdl.Renderer) (*Heatmap, error) {
+	height := chip8.MEMORY_SIZE / HEATMAP_WIDTH
+	texture, err := renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STREAMING, HEATMAP_WIDTH, int32(height))
+	if err != nil {
+		return nil, err
+	}
+	texture.SetBlendMode(sdl.BLENDMODE_BLEND)
+
+	return &Heatmap{
+		renderer: renderer,
+		texture:  texture,
+		pixels:   make([]byte, chip8.MEMORY_SIZE*4),
+	}, nil
+}
+
+// Uploads the current profile to the heatmap
+func (h *Heatmap) Update(p *chip8.Profiler, pc uint16) error {
+	maxExec, maxReads, maxWrites := peak(&p.Exec), peak(&p.Reads), peak(&p.Writes)
+	for addr := 0; addr < chip8.MEMORY_SIZE; addr++ {
+		r := heat(p.Exec[addr], maxExec)
+		g := heat(p.Reads[addr], maxReads)
+		b := heat(p.Writes[addr], maxWrites)
+		a := uint8(160)
+		if r|g|b != 0 {
+			a = 230
+		}
+		if uint16(addr) == pc&0x0FFF {
+			r, g, b, a = 255, 255, 255, 255
+		}
+		h.pixels[addr*4] = r
+		h.pixels[addr*4+1] = g
+		h.pixels[addr*4+2] = b
+		h.pixels[addr*4+3] = a
+	}
+	return h.texture.Update(nil, h.pixels, HEATMAP_WIDTH*4)
+}
+
+// Copies the heatmap to a square on the right of the renderer
+func (h *Heatmap) Draw() error {
+	ow, oh, err := h.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+	size := oh
+	if ow/2 < size {
+		size = ow / 2
+	}
+	return h.renderer.Copy(h.texture, nil, &sdl.Rect{X: ow - size, Y: (oh - size) / 2, W: size, H: size})
+}
+
+// Frees the heatmap texture
+func (h *Heatmap) Destroy() {
+	h.texture.Destroy()
+}
+
+// Returns the highest count in a heatmap
+func peak(counts *[chip8.MEMORY_SIZE]uint64) uint64 {
+	var max uint64
+	for _, n := range counts {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// Returns the brightness of a count, on a log scale up to max
+// Anything counted at all is visible
+func heat(n, max uint64) uint8 {
+	if n == 0 || max == 0 {
+		return 0
+	}
+	return uint8(48 + 207*math.Log1p(float64(n))/math.Log1p(float64(max)))
+}
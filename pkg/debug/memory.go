@@ -0,0 +1,294 @@
+package debug
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"fmt"
+	"strings"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Memory view font size (pt)
+const MEMORY_FONT_SIZE = 16
+
+// Bytes per memory view row
+const MEMORY_ROW = 16
+
+// Bytes highlighted at I, a font sprite
+const I_BYTES = 5
+
+// Memory view colours
+var (
+	colorText    = sdl.Color{R: 200, G: 200, B: 200, A: 255}
+	colorDim     = sdl.Color{R: 110, G: 110, B: 110, A: 255}
+	colorFont    = sdl.Color{R: 110, G: 160, B: 255, A: 255}
+	colorROM     = sdl.Color{R: 120, G: 230, B: 120, A: 255}
+	colorI       = sdl.Color{R: 255, G: 220, B: 60, A: 255}
+	colorPC      = sdl.Color{R: 255, G: 90, B: 90, A: 255}
+	colorWritten = sdl.Color{R: 255, G: 100, B: 255, A: 255}
+	colorCursor  = sdl.Color{R: 255, G: 255, B: 255, A: 255}
+)
+
+// Registers the editor can change, after V0 to VF
+const (
+	editI = 16 + iota
+	editPC
+	editRegisters
+)
+
+// MemoryView shows the CHIP-8's 4 KiB of memory as a hex and ASCII grid,
+// with the registers underneath, and edits both while the CHIP-8 is paused
+//
+// Colours mark the font (blue), the ROM (green), the bytes at I (yellow), the
+// instruction at PC (red) and bytes written in the last frame (pink)
+type MemoryView struct {
+	renderer *sdl.Renderer
+	text     *glyphCache
+
+	// Selected address, and the first row shown
+	cursor uint16
+	top    int
+
+	// Editing registers instead of memory, and which one
+	registers bool
+	reg       int
+
+	// First hex digit typed for the byte under the cursor, -1 if none
+	nibble int
+}
+
+// Creates a memory view for the given renderer
+func NewMemoryView(renderer *sdl.Renderer) (*MemoryView, error) {
+	text, err := newGlyphCache(renderer, MEMORY_FONT_SIZE)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryView{
+		renderer: renderer,
+		text:     text,
+		cursor:   chip8.ROM_ADDRESS,
+		top:      chip8.ROM_ADDRESS / MEMORY_ROW,
+		nibble:   -1,
+	}, nil
+}
+
+// Draws the memory view down the left of the renderer
+func (m *MemoryView) Draw(c *chip8.Chip8, paused bool) error {
+	_, oh, err := m.renderer.GetOutputSize()
+	if err != nil {
+		return err
+	}
+
+	// Header, memory rows, a gap, then two lines of registers
+	rows := int(oh/m.text.h) - 4
+	if rows < 1 {
+		rows = 1
+	}
+	m.scrollTo(rows)
+
+	width := m.text.w * int32(6+MEMORY_ROW*3+1+MEMORY_ROW)
+	m.renderer.SetDrawBlendMode(sdl.BLENDMODE_BLEND)
+	m.renderer.SetDrawColor(0, 0, 0, 210)
+	m.renderer.FillRect(&sdl.Rect{X: 0, Y: 0, W: width, H: oh})
+
+	// Header
+	state := "running, F5 pauses"
+	if paused {
+		state = "PAUSED, F5 resumes, F10 steps"
+		if m.registers {
+			state += ", Tab edits memory"
+		} else {
+			state += ", Tab edits registers"
+		}
+	}
+	m.text.draw(0, 0, fmt.Sprintf("MEM 0x%03X  %s", m.cursor, state), colorText)
+
+	// Memory rows
+	for row := 0; row < rows; row++ {
+		base := (m.top + row) * MEMORY_ROW
+		if base >= chip8.MEMORY_SIZE {
+			break
+		}
+		y := int32(row+1) * m.text.h
+		m.text.draw(0, y, fmt.Sprintf("%03X", base), colorDim)
+
+		var ascii strings.Builder
+		for col := 0; col < MEMORY_ROW; col++ {
+			addr := uint16(base + col)
+			val := c.Memory(addr)
+			x := m.text.w * int32(5+col*3)
+			color := m.color(c, addr)
+			if !m.registers && addr == m.cursor && paused {
+				m.renderer.SetDrawColor(color.R/3, color.G/3, color.B/3, 255)
+				m.renderer.FillRect(&sdl.Rect{X: x, Y: y, W: m.text.w * 2, H: m.text.h})
+				color = colorCursor
+			}
+			m.text.draw(x, y, fmt.Sprintf("%02X", val), color)
+
+			if val >= 0x20 && val < 0x7F {
+				ascii.WriteByte(val)
+			} else {
+				ascii.WriteByte('.')
+			}
+		}
+		m.text.draw(m.text.w*int32(6+MEMORY_ROW*3), y, ascii.String(), colorDim)
+	}
+
+	// Registers
+	y := int32(rows+2) * m.text.h
+	for x := 0; x < 16; x++ {
+		m.drawRegister(x, int32(x%8)*m.text.w*8, y+int32(x/8)*m.text.h, fmt.Sprintf("V%X %02X", x, c.V(x)), paused)
+	}
+	right := m.text.w * 8 * 8
+	m.drawRegister(editI, right, y, fmt.Sprintf("I %03X", c.I()), paused)
+	m.drawRegister(editPC, right, y+m.text.h, fmt.Sprintf("PC %03X", c.PC()), paused)
+
+	return nil
+}
+
+// Draws one register, highlighted if it is being edited
+func (m *MemoryView) drawRegister(reg int, x, y int32, label string, paused bool) {
+	color := colorText
+	if m.registers && m.reg == reg && paused {
+		m.renderer.SetDrawColor(80, 80, 80, 255)
+		m.renderer.FillRect(&sdl.Rect{X: x, Y: y, W: m.text.w * int32(len(label)), H: m.text.h})
+		color = colorCursor
+	}
+	m.text.draw(x, y, label, color)
+}
+
+// Returns the colour for the byte at the given address
+func (m *MemoryView) color(c *chip8.Chip8, addr uint16) sdl.Color {
+	switch {
+	case addr == c.PC() || addr == c.PC()+1:
+		return colorPC
+	case c.WrittenLastFrame(addr):
+		return colorWritten
+	case addr >= c.I() && addr < c.I()+I_BYTES:
+		return colorI
+	case addr < chip8.FONT_ADDRESS+chip8.FONTSET_SIZE:
+		return colorFont
+	case addr >= c.ROMAddress() && int(addr) < int(c.ROMAddress())+c.ROMSize():
+		return colorROM
+	default:
+		return colorDim
+	}
+}
+
+// Scrolls so the cursor is on screen
+func (m *MemoryView) scrollTo(rows int) {
+	row := int(m.cursor) / MEMORY_ROW
+	if row < m.top {
+		m.top = row
+	}
+	if row >= m.top+rows {
+		m.top = row - rows + 1
+	}
+}
+
+// Handles a key press while the CHIP-8 is paused
+// Arrows and Page Up/Down move, Home jumps to PC, Tab switches between
+// memory and registers, and hex digits type a new value
+// Returns false if the key isn't for the memory view
+func (m *MemoryView) HandleKey(c *chip8.Chip8, key sdl.Keycode) bool {
+	if d, ok := hexDigit(key); ok {
+		m.typeDigit(c, d)
+		return true
+	}
+
+	switch key {
+	case sdl.K_TAB:
+		m.registers = !m.registers
+	case sdl.K_HOME:
+		m.registers = false
+		m.cursor = c.PC()
+	case sdl.K_LEFT:
+		m.move(-1, -1)
+	case sdl.K_RIGHT:
+		m.move(1, 1)
+	case sdl.K_UP:
+		m.move(-MEMORY_ROW, -1)
+	case sdl.K_DOWN:
+		m.move(MEMORY_ROW, 1)
+	case sdl.K_PAGEUP:
+		m.move(-MEMORY_ROW*16, 0)
+	case sdl.K_PAGEDOWN:
+		m.move(MEMORY_ROW*16, 0)
+	default:
+		return false
+	}
+	m.nibble = -1
+	return true
+}
+
+// Moves the memory cursor by bytes, or the register selection by regs
+func (m *MemoryView) move(bytes int, regs int) {
+	if m.registers {
+		m.reg = (m.reg + regs + editRegisters) % editRegisters
+		return
+	}
+	addr := int(m.cursor) + bytes
+	if addr < 0 {
+		addr = 0
+	}
+	if addr >= chip8.MEMORY_SIZE {
+		addr = chip8.MEMORY_SIZE - 1
+	}
+	m.cursor = uint16(addr)
+}
+
+// Types a hex digit into the selected byte or register
+// Bytes take two digits and then move on, registers shift each digit in
+func (m *MemoryView) typeDigit(c *chip8.Chip8, d int) {
+	if m.registers {
+		switch m.reg {
+		case editI:
+			c.SetI((c.I()<<4 | uint16(d)) & 0x0FFF)
+		case editPC:
+			c.SetPC(c.PC()<<4 | uint16(d))
+		default:
+			c.SetV(m.reg, c.V(m.reg)<<4|uint8(d))
+		}
+		return
+	}
+
+	if m.nibble < 0 {
+		m.nibble = d
+		c.SetMemory(m.cursor, uint8(d))
+		return
+	}
+	c.SetMemory(m.cursor, uint8(m.nibble<<4|d))
+	m.nibble = -1
+	m.move(1, 0)
+}
+
+// Frees the font and glyphs
+func (m *MemoryView) Close() {
+	m.text.close()
+}
+
+// Returns the value of a hex digit key
+func hexDigit(key sdl.Keycode) (int, bool) {
+	switch {
+	case key >= sdl.K_0 && key <= sdl.K_9:
+		return int(key - sdl.K_0), true
+	case key >= sdl.K_a && key <= sdl.K_f:
+		return int(key-sdl.K_a) + 0xA, true
+	}
+	return 0, false
+}
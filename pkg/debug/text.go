@@ -0,0 +1,117 @@
+package debug
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"github.com/veandco/go-sdl2/ttf"
+)
+
+// Draws monospaced text from a texture per glyph, so text is only rendered once
+type glyphCache struct {
+	renderer *sdl.Renderer
+	font     *ttf.Font
+
+	// The font file font reads glyphs from, SDL only has a pointer to it
+	// so it has to outlive font
+	data []byte
+
+	// Cached glyph textures, VT323 is monospaced
+	glyphs map[rune]*sdl.Texture
+	w      int32
+	h      int32
+}
+
+// Loads the embedded font at the given size (pt)
+func newGlyphCache(renderer *sdl.Renderer, size int) (*glyphCache, error) {
+	data := chippy.OverlayFont()
+	rw, err := sdl.RWFromMem(data)
+	if err != nil {
+		return nil, err
+	}
+	font, err := ttf.OpenFontRW(rw, 1, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load font: %v", err)
+	}
+
+	w, h, err := font.SizeUTF8("0")
+	if err != nil {
+		font.Close()
+		return nil, err
+	}
+
+	return &glyphCache{
+		renderer: renderer,
+		font:     font,
+		data:     data,
+		glyphs:   map[rune]*sdl.Texture{},
+		w:        int32(w),
+		h:        int32(h),
+	}, nil
+}
+
+// Draws a line of text with its top left at x, y
+func (g *glyphCache) draw(x, y int32, line string, c sdl.Color) error {
+	for i, r := range []rune(line) {
+		if r == ' ' {
+			continue
+		}
+		glyph, err := g.glyph(r)
+		if err != nil {
+			return err
+		}
+		glyph.SetColorMod(c.R, c.G, c.B)
+		g.renderer.Copy(glyph, nil, &sdl.Rect{X: x + int32(i)*g.w, Y: y, W: g.w, H: g.h})
+	}
+	return nil
+}
+
+// Returns the texture for a glyph, rendering it the first time it is used
+// Glyphs are white, draw tints them
+func (g *glyphCache) glyph(r rune) (*sdl.Texture, error) {
+	if glyph, ok := g.glyphs[r]; ok {
+		return glyph, nil
+	}
+
+	surface, err := g.font.RenderUTF8Blended(string(r), sdl.Color{R: 255, G: 255, B: 255, A: 255})
+	if err != nil {
+		return nil, err
+	}
+	defer surface.Free()
+
+	glyph, err := g.renderer.CreateTextureFromSurface(surface)
+	if err != nil {
+		return nil, err
+	}
+	g.glyphs[r] = glyph
+	return glyph, nil
+}
+
+// Frees the font and glyphs
+func (g *glyphCache) close() {
+	for _, glyph := range g.glyphs {
+		glyph.Destroy()
+	}
+	g.glyphs = map[rune]*sdl.Texture{}
+	if g.font != nil {
+		g.font.Close()
+		g.font = nil
+	}
+	g.data = nil
+}
@@ -0,0 +1,425 @@
+package debugserver
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"chippy/pkg/chip8"
+	"chippy/pkg/rsp"
+	"encoding/hex"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Largest packet we accept, and advertise in qSupported
+const PACKET_SIZE = 0x1000
+
+// Server lets one debugger client at a time drive a CHIP-8 over TCP, using
+// the protocol in pkg/rsp
+//
+// The CHIP-8 is shared with whoever runs it, so the server is given a lock:
+// hold it around everything that touches the CHIP-8, including RunFrame.
+// Client requests are served from their own goroutine under it.
+type Server struct {
+	lock sync.Locker
+	chip *chip8.Chip8
+
+	// A client is connected, and the CHIP-8 only runs when it says so
+	attached bool
+	halted   bool
+
+	// The client is waiting for a stop reply to its continue
+	running bool
+
+	// Instruction count when the client last resumed, so we can run off the
+	// breakpoint we are stopped on
+	resumedAt uint64
+
+	// Breakpoint addresses
+	breakpoints map[uint16]bool
+
+	// Stop replies for the client, sent when RunFrame halts
+	stops chan string
+}
+
+// Creates a debug server for the CHIP-8, guarded by the lock
+func New(c *chip8.Chip8, lock sync.Locker) *Server {
+	return &Server{
+		lock:        lock,
+		chip:        c,
+		breakpoints: map[uint16]bool{},
+		stops:       make(chan string, 1),
+	}
+}
+
+// Listens on the address, and serves clients one at a time until the
+// listener fails
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serves clients from the listener one at a time, until it fails
+func (s *Server) Serve(l net.Listener) error {
+	defer l.Close()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		s.serveConn(conn)
+	}
+}
+
+// Runs the CHIP-8 for one 60Hz frame, unless the client has it halted
+// Stops early at breakpoints. Call with the lock held.
+func (s *Server) RunFrame() {
+	if !s.attached {
+		s.chip.RunFrame()
+		return
+	}
+	if s.halted {
+		return
+	}
+	frame := s.chip.Frame()
+	for s.chip.Frame() == frame {
+		if s.breakpoints[s.chip.PC()] && s.chip.Instructions() != s.resumedAt {
+			s.stop(rsp.STOP_TRAP)
+			return
+		}
+		s.chip.Cycle()
+	}
+}
+
+// Returns true while the client has the CHIP-8 halted
+func (s *Server) Halted() bool {
+	return s.attached && s.halted
+}
+
+// Halts the CHIP-8, telling the client why if it is waiting
+// Call with the lock held
+func (s *Server) stop(reason string) {
+	s.halted = true
+	if s.running {
+		s.running = false
+		s.stops <- reason
+	}
+}
+
+// A packet from the client, or an interrupt
+type request struct {
+	data      string
+	interrupt bool
+}
+
+// Serves one client until it detaches or disconnects
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	// Halt on attach, like GDB expects
+	s.lock.Lock()
+	s.attached = true
+	s.halted = true
+	s.running = false
+	s.breakpoints = map[uint16]bool{}
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		s.attached = false
+		s.halted = false
+		s.running = false
+		s.breakpoints = map[uint16]bool{}
+		select {
+		case <-s.stops:
+		default:
+		}
+		s.lock.Unlock()
+	}()
+
+	// Read packets on their own goroutine, so interrupts arrive while the
+	// CHIP-8 runs
+	requests := make(chan request)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(requests)
+		r := bufio.NewReaderSize(conn, PACKET_SIZE)
+		for {
+			data, interrupt, err := rsp.ReadPacket(r)
+			if err != nil {
+				return
+			}
+			select {
+			case requests <- request{data: data, interrupt: interrupt}:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			if req.interrupt {
+				s.lock.Lock()
+				if s.running {
+					s.stop(rsp.STOP_INTERRUPT)
+				}
+				s.lock.Unlock()
+				continue
+			}
+
+			// Ack, then answer
+			if _, err := conn.Write([]byte{'+'}); err != nil {
+				return
+			}
+			reply, send, detach := s.handleLocked(req.data)
+			if send {
+				if err := rsp.WritePacket(conn, reply); err != nil {
+					return
+				}
+			}
+			if detach {
+				return
+			}
+
+		case reason := <-s.stops:
+			if err := rsp.WritePacket(conn, reason); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Handles one packet, taking the lock
+// A CHIP-8 that panics part way through a request is halted and the client
+// gets E02, instead of the panic taking the lock with it
+func (s *Server) handleLocked(data string) (reply string, send bool, detach bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer func() {
+		if r := recover(); r != nil {
+			s.halted = true
+			s.running = false
+			reply, send, detach = "E02", true, false
+		}
+	}()
+	return s.handle(data)
+}
+
+// Handles one packet, with the lock held
+// Returns the reply, whether to send it, and whether the client is leaving
+func (s *Server) handle(data string) (reply string, send bool, detach bool) {
+	if data == "" {
+		return "", true, false
+	}
+	args := data[1:]
+
+	switch data[0] {
+	case '?':
+		return rsp.STOP_TRAP, true, false
+
+	case 'q':
+		switch {
+		case strings.HasPrefix(args, "Supported"):
+			return "PacketSize=" + strconv.FormatInt(PACKET_SIZE, 16) + ";swbreak+", true, false
+		case args == "Attached":
+			return "1", true, false
+		}
+
+	case 'H':
+		// Only one thread
+		return "OK", true, false
+
+	case 'g':
+		return s.registers().Encode(), true, false
+
+	case 'G':
+		r, err := rsp.DecodeRegisters(args)
+		if err != nil || !s.setRegisters(r) {
+			return "E01", true, false
+		}
+		return "OK", true, false
+
+	case 'p':
+		n, err := strconv.ParseUint(args, 16, 8)
+		if err != nil || n >= rsp.REG_COUNT {
+			return "E01", true, false
+		}
+		r := s.registers()
+		return rsp.EncodeRegister(int(n), r.Get(int(n))), true, false
+
+	case 'P':
+		parts := strings.SplitN(args, "=", 2)
+		if len(parts) != 2 {
+			return "E01", true, false
+		}
+		n, err := strconv.ParseUint(parts[0], 16, 8)
+		if err != nil || n >= rsp.REG_COUNT {
+			return "E01", true, false
+		}
+		val, err := rsp.DecodeRegister(int(n), parts[1])
+		if err != nil {
+			return "E01", true, false
+		}
+		r := s.registers()
+		r.Set(int(n), val)
+		if !s.setRegisters(r) {
+			return "E01", true, false
+		}
+		return "OK", true, false
+
+	case 'm':
+		addr, n, ok := parseRange(args)
+		if !ok || n > PACKET_SIZE/2 {
+			return "E01", true, false
+		}
+		return hex.EncodeToString(s.chip.ReadMemory(addr, n)), true, false
+
+	case 'M':
+		parts := strings.SplitN(args, ":", 2)
+		if len(parts) != 2 {
+			return "E01", true, false
+		}
+		addr, n, ok := parseRange(parts[0])
+		if !ok {
+			return "E01", true, false
+		}
+		b, err := hex.DecodeString(parts[1])
+		if err != nil || len(b) != n {
+			return "E01", true, false
+		}
+		for i, val := range b {
+			s.chip.SetMemory(addr+uint16(i), val)
+		}
+		return "OK", true, false
+
+	case 'Z', 'z':
+		// Software and hardware breakpoints are the same thing to us
+		parts := strings.Split(args, ",")
+		if len(parts) < 2 || (parts[0] != "0" && parts[0] != "1") {
+			return "", true, false
+		}
+		addr, err := strconv.ParseUint(parts[1], 16, 16)
+		if err != nil {
+			return "E01", true, false
+		}
+		if data[0] == 'Z' {
+			s.breakpoints[uint16(addr)&0x0FFF] = true
+		} else {
+			delete(s.breakpoints, uint16(addr)&0x0FFF)
+		}
+		return "OK", true, false
+
+	case 'c':
+		if !s.resumeAt(args) {
+			return "E01", true, false
+		}
+		s.halted = false
+		s.running = true
+		s.resumedAt = s.chip.Instructions()
+		// The stop reply comes from RunFrame
+		return "", false, false
+
+	case 's':
+		if !s.resumeAt(args) {
+			return "E01", true, false
+		}
+		s.chip.Step()
+		return rsp.STOP_TRAP, true, false
+
+	case 'D':
+		return "OK", true, true
+
+	case 'k':
+		return "", false, true
+	}
+
+	// Empty reply, unsupported
+	return "", true, false
+}
+
+// Moves the PC to the address given with c or s, if there is one
+// Returns false if the address isn't one the PC can hold
+func (s *Server) resumeAt(args string) bool {
+	if args == "" {
+		return true
+	}
+	addr, err := strconv.ParseUint(args, 16, 16)
+	if err != nil || addr > 0x0FFF {
+		return false
+	}
+	s.chip.SetPC(uint16(addr))
+	return true
+}
+
+// Returns the CHIP-8 registers
+func (s *Server) registers() rsp.Registers {
+	r := rsp.Registers{
+		I:  s.chip.I(),
+		PC: s.chip.PC(),
+		SP: uint8(s.chip.SP()),
+		DT: s.chip.DelayTimer(),
+		ST: s.chip.SoundTimer(),
+	}
+	for x := range r.V {
+		r.V[x] = s.chip.V(x)
+	}
+	return r
+}
+
+// Sets the CHIP-8 registers
+// SP is read only, the stack is not ours to rearrange
+// Returns false, changing nothing, if I or PC are out of range
+func (s *Server) setRegisters(r rsp.Registers) bool {
+	if r.PC > 0x0FFF || r.I > s.chip.MaxI() {
+		return false
+	}
+	for x := range r.V {
+		s.chip.SetV(x, r.V[x])
+	}
+	s.chip.SetI(r.I)
+	s.chip.SetPC(r.PC)
+	s.chip.SetDelayTimer(r.DT)
+	s.chip.SetSoundTimer(r.ST)
+	return true
+}
+
+// Parses addr,length in hex
+func parseRange(args string) (uint16, int, bool) {
+	parts := strings.SplitN(args, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	addr, err := strconv.ParseUint(parts[0], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	n, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint16(addr), int(n), true
+}
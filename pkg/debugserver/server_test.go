@@ -0,0 +1,272 @@
+package debugserver
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bytes"
+	"chippy/pkg/chip8"
+	"chippy/pkg/rsp"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Counts up in V0 forever
+//
+//	200: 6000  V0 = 0
+//	202: 7001  V0 += 1
+//	204: 1202  jump 202
+var counter = []byte{0x60, 0x00, 0x70, 0x01, 0x12, 0x02}
+
+// Starts a debug server for a CHIP-8 running the ROM, with a goroutine
+// running frames like the frontend does, and connects a client to it
+func start(t *testing.T, rom []byte) (*rsp.Client, *chip8.Chip8, sync.Locker) {
+	t.Helper()
+	c := chip8.Init()
+	if _, err := c.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	lock := &sync.Mutex{}
+	s := New(&c, lock)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			lock.Lock()
+			s.RunFrame()
+			lock.Unlock()
+		}
+	}()
+
+	client, err := rsp.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		close(done)
+		l.Close()
+	})
+	return client, &c, lock
+}
+
+func TestRegisters(t *testing.T) {
+	client, _, _ := start(t, counter)
+
+	r, err := client.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PC != chip8.ROM_ADDRESS {
+		t.Errorf("PC = %03X on attach, want %03X", r.PC, chip8.ROM_ADDRESS)
+	}
+
+	r.V[3] = 0x2A
+	r.I = 0x345
+	r.PC = 0x204
+	r.DT = 9
+	if err := client.SetRegisters(r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := client.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != r {
+		t.Errorf("registers = %+v, want %+v", got, r)
+	}
+
+	if err := client.SetRegister(rsp.REG_I, 0x123); err != nil {
+		t.Fatal(err)
+	}
+	if i, err := client.Register(rsp.REG_I); err != nil || i != 0x123 {
+		t.Errorf("I = %03X, %v, want 123", i, err)
+	}
+}
+
+func TestRegistersOutOfRange(t *testing.T) {
+	client, c, lock := start(t, counter)
+
+	if err := client.SetRegister(rsp.REG_I, 0xFFFF); err == nil {
+		t.Errorf("set I to FFFF")
+	}
+	if err := client.SetRegister(rsp.REG_PC, 0x1000); err == nil {
+		t.Errorf("set PC to 1000")
+	}
+	r, err := client.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.I = 0xFFFF
+	if err := client.SetRegisters(r); err == nil {
+		t.Errorf("set I to FFFF with G")
+	}
+	if _, err := client.Request("sFFFF"); err == nil {
+		t.Errorf("stepped from FFFF")
+	}
+
+	// Nothing changed, and stepping still works
+	if _, err := client.Step(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	i, pc := c.I(), c.PC()
+	lock.Unlock()
+	if i != 0 || pc != 0x202 {
+		t.Errorf("I, PC = %03X, %03X after a step, want 000, 202", i, pc)
+	}
+}
+
+func TestStepMachineCode(t *testing.T) {
+	// 0300 calls a routine that spins on BN3 until a key is down
+	rom := make([]byte, 0x104)
+	copy(rom, []byte{0x03, 0x00, 0x12, 0x02})
+	copy(rom[0x100:], []byte{0x3E, 0x00, 0xD4})
+	client, c, lock := start(t, rom)
+
+	lock.Lock()
+	c.SetQuirks(chip8.Quirks{MachineCode: true})
+	c.SetPC(chip8.ROM_ADDRESS)
+	lock.Unlock()
+
+	// Every step comes back, one 1802 instruction at a time
+	for i := 0; i < 10; i++ {
+		if reply, err := client.Step(); err != nil || reply != rsp.STOP_TRAP {
+			t.Fatalf("step %d = %q, %v", i, reply, err)
+		}
+	}
+	lock.Lock()
+	spinning := c.InMachineCode()
+	lock.Unlock()
+	if !spinning {
+		t.Errorf("routine returned without a key")
+	}
+}
+
+func TestLoadAtEndOfMemory(t *testing.T) {
+	// AFFF F165: load V0 and V1 from the last byte of memory and the first
+	client, _, _ := start(t, []byte{0xAF, 0xFF, 0xF1, 0x65})
+
+	for i := 0; i < 2; i++ {
+		if reply, err := client.Step(); err != nil || reply != rsp.STOP_TRAP {
+			t.Fatalf("step %d = %q, %v", i, reply, err)
+		}
+	}
+	r, err := client.Registers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.PC != 0x204 || r.I != 0xFFF {
+		t.Errorf("PC, I = %03X, %03X, want 204, FFF", r.PC, r.I)
+	}
+}
+
+func TestMemory(t *testing.T) {
+	client, _, _ := start(t, counter)
+
+	mem, err := client.ReadMemory(chip8.ROM_ADDRESS, len(counter))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mem, counter) {
+		t.Errorf("ROM reads as % X, want % X", mem, counter)
+	}
+
+	data := []byte{0xDE, 0xAD, 0xBE, 0xEF}
+	if err := client.WriteMemory(0x300, data); err != nil {
+		t.Fatal(err)
+	}
+	if mem, err := client.ReadMemory(0x300, len(data)); err != nil || !bytes.Equal(mem, data) {
+		t.Errorf("memory reads back as % X, %v, want % X", mem, err, data)
+	}
+
+	// Writing past the end wraps, like the CHIP-8 does
+	if err := client.WriteMemory(0xFFF, []byte{0x12, 0x34}); err != nil {
+		t.Fatal(err)
+	}
+	if mem, err := client.ReadMemory(0x000, 1); err != nil || mem[0] != 0x34 {
+		t.Errorf("wrapped write reads back as % X, %v", mem, err)
+	}
+
+	if _, err := client.Request("m300"); err == nil {
+		t.Errorf("read memory without a length")
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	client, _, _ := start(t, counter)
+
+	if err := client.SetBreakpoint(0x204); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every continue runs round the loop once more
+	for want := uint8(1); want <= 3; want++ {
+		reply, err := client.Continue()
+		if err != nil || reply != rsp.STOP_TRAP {
+			t.Fatalf("continue = %q, %v", reply, err)
+		}
+		r, err := client.Registers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.PC != 0x204 || r.V[0] != want {
+			t.Errorf("stopped at %03X with V0 = %d, want 204 with %d", r.PC, r.V[0], want)
+		}
+	}
+
+	// Stepping off the breakpoint
+	if _, err := client.Step(); err != nil {
+		t.Fatal(err)
+	}
+	if pc, err := client.Register(rsp.REG_PC); err != nil || pc != 0x202 {
+		t.Errorf("PC = %03X, %v after a step, want 202", pc, err)
+	}
+
+	// Without the breakpoint it runs until halted
+	if err := client.ClearBreakpoint(0x204); err != nil {
+		t.Fatal(err)
+	}
+	stopped := make(chan string)
+	go func() {
+		reply, _ := client.Continue()
+		stopped <- reply
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := client.Halt(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reply := <-stopped:
+		if reply != rsp.STOP_INTERRUPT {
+			t.Errorf("halt stopped with %q, want %q", reply, rsp.STOP_INTERRUPT)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("halt didn't stop the CHIP-8")
+	}
+}
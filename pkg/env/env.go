@@ -0,0 +1,376 @@
+package env
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/romdb"
+	"chippy/pkg/rominfo"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Frames run per step when the spec doesn't say
+const DEFAULT_FRAME_SKIP = 4
+
+// Spec describes how an agent plays a ROM: the actions it has, and the
+// memory that decides its reward and when the game is over
+type Spec struct {
+	// Settings on top of the ROM database, like the chippy flags
+	Settings romdb.Settings `json:"settings"`
+
+	// 60Hz frames per step, the action is held for all of them
+	FrameSkip int `json:"frameSkip"`
+
+	// The keys held for each action
+	// Defaults to 17 actions: nothing, then each key 0 to F on its own
+	Actions [][]int `json:"actions"`
+
+	// Memory whose change is the reward, summed
+	Reward []Watch `json:"reward"`
+
+	// Memory that ends the game, when any condition holds
+	Done []Watch `json:"done"`
+
+	// Ends the game after this many frames, 0 for never
+	MaxFrames uint64 `json:"maxFrames"`
+
+	// Also read the user's ROM database and overrides from the config dir
+	// Otherwise only the bundled database is used, so runs are the same on
+	// every machine.
+	UserDatabase bool `json:"userDatabase"`
+}
+
+// Watch reads a number from memory
+type Watch struct {
+	// Address, hex with 0x or decimal
+	Addr string `json:"addr"`
+
+	// Bytes to read, 1 by default
+	Length int `json:"length"`
+
+	// How the bytes make a number: "uint" is big endian (default), "bcd" is
+	// one decimal digit per byte, most significant first, like FX33 stores
+	Format string `json:"format"`
+
+	// Reward per unit the number goes up, 1 by default
+	Scale float64 `json:"scale"`
+
+	// Done condition: the number compared to Value with ==, !=, <, <=, > or >=
+	Op    string `json:"op"`
+	Value int64  `json:"value"`
+
+	addr uint16
+}
+
+// Loads a spec from a JSON file
+func LoadSpec(file string) (Spec, error) {
+	var spec Spec
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return spec, err
+	}
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return spec, fmt.Errorf("failed to parse spec %s: %v", file, err)
+	}
+	return spec, nil
+}
+
+// Observation is the display, one pixel value per byte, row by row
+// It is 64x32 on CHIP-8, 64x64 on HIRES CHIP-8 and 256x192 on MegaChip.
+type Observation struct {
+	Width  int
+	Height int
+	Pixels []uint8
+}
+
+// Returns the display packed 8 pixels per byte, row by row, most
+// significant bit first (numpy.unpackbits order)
+func (o *Observation) Packed() []byte {
+	out := make([]byte, 0, len(o.Pixels)/8)
+	for i := 0; i+8 <= len(o.Pixels); i += 8 {
+		var b byte
+		for bit := 0; bit < 8; bit++ {
+			if o.Pixels[i+bit] != 0 {
+				b |= 0x80 >> uint(bit)
+			}
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// Env is a Gym-style environment: Reset, then Step until done
+type Env struct {
+	spec Spec
+
+	// The CHIP-8 as it is after loading, every Reset starts from a copy
+	base chip8.Chip8
+	chip chip8.Chip8
+
+	// Reward watch values after the last step
+	last []int64
+
+	// Frames run since Reset, and whether the game is over
+	frames uint64
+	done   bool
+}
+
+// Creates an environment for a ROM
+func New(rom []byte, spec Spec) (*Env, error) {
+	if spec.FrameSkip <= 0 {
+		spec.FrameSkip = DEFAULT_FRAME_SKIP
+	}
+	if len(spec.Actions) == 0 {
+		spec.Actions = [][]int{{}}
+		for key := 0x0; key <= 0xF; key++ {
+			spec.Actions = append(spec.Actions, []int{key})
+		}
+	}
+	for _, keys := range spec.Actions {
+		for _, key := range keys {
+			if key < 0x0 || key > 0xF {
+				return nil, fmt.Errorf("action key %d is not 0 to F :(", key)
+			}
+		}
+	}
+	for i := range spec.Reward {
+		if err := spec.Reward[i].parse(); err != nil {
+			return nil, err
+		}
+	}
+	for i := range spec.Done {
+		if err := spec.Done[i].parse(); err != nil {
+			return nil, err
+		}
+		if _, ok := compare(spec.Done[i].Op, 0, 0); !ok {
+			return nil, fmt.Errorf("unknown done op %q :(", spec.Done[i].Op)
+		}
+	}
+
+	e := &Env{spec: spec, base: chip8.Init()}
+
+	// Same layering as chippy: analysis, then the database, then the spec
+	report := rominfo.Analyze(rom)
+	settings := romdb.Recommended(report)
+	open := romdb.OpenSeed
+	if spec.UserDatabase {
+		open = romdb.Open
+	}
+	if db, err := open(); err == nil {
+		if known, ok := db.Lookup(report.SHA1); ok {
+			settings = settings.Merge(known)
+		}
+	}
+	if err := settings.Merge(spec.Settings).Apply(&e.base); err != nil {
+		return nil, err
+	}
+
+	// The platform decides where the ROM goes, so load it last
+	if _, err := e.base.LoadROMBytes(rom); err != nil {
+		return nil, err
+	}
+
+	e.Reset(0)
+	return e, nil
+}
+
+// Returns the number of actions
+func (e *Env) ActionCount() int {
+	return len(e.spec.Actions)
+}
+
+// Returns the spec, with defaults filled in
+func (e *Env) Spec() Spec {
+	return e.spec
+}
+
+// Returns the size of the observations, which the platform decides
+func (e *Env) Size() (int, int) {
+	if e.base.Platform() == chip8.PLATFORM_MEGACHIP {
+		return chip8.MEGA_WIDTH, chip8.MEGA_HEIGHT
+	}
+	return int(chip8.DISPLAY_WIDTH), int(e.base.DisplayHeight())
+}
+
+// Returns the frames run since Reset
+func (e *Env) Frame() uint64 {
+	return e.frames
+}
+
+// Returns true if the game ended by running out of frames, rather than by a
+// done condition
+func (e *Env) Truncated() bool {
+	return e.done && e.spec.MaxFrames > 0 && e.frames >= e.spec.MaxFrames && !e.finished()
+}
+
+// Starts a new game, with the random number generator seeded
+func (e *Env) Reset(seed int64) Observation {
+	e.chip = e.base.Snapshot()
+	e.chip.SetSeed(seed)
+	e.frames = 0
+	e.done = false
+	e.last = make([]int64, len(e.spec.Reward))
+	for i, w := range e.spec.Reward {
+		e.last[i] = w.read(&e.chip)
+	}
+	return e.observe()
+}
+
+// Holds the action's keys for FrameSkip frames
+// Returns what the agent sees, the reward earned and whether the game is over
+// Actions out of range hold no keys, steps after the game is over do nothing
+func (e *Env) Step(action int) (Observation, float64, bool) {
+	if e.done {
+		return e.observe(), 0, true
+	}
+
+	// Hold exactly the action's keys
+	held := [0xF + 1]bool{}
+	if action >= 0 && action < len(e.spec.Actions) {
+		for _, key := range e.spec.Actions[action] {
+			held[key] = true
+		}
+	}
+	for key := range held {
+		if held[key] {
+			e.chip.KeyPress(key)
+		} else {
+			e.chip.KeyRelease(key)
+		}
+	}
+
+	for i := 0; i < e.spec.FrameSkip && !e.done; i++ {
+		e.chip.RunFrame()
+		e.frames++
+		e.done = e.finished() || (e.spec.MaxFrames > 0 && e.frames >= e.spec.MaxFrames)
+	}
+
+	reward := 0.0
+	for i, w := range e.spec.Reward {
+		val := w.read(&e.chip)
+		reward += float64(val-e.last[i]) * w.scale()
+		e.last[i] = val
+	}
+
+	return e.observe(), reward, e.done
+}
+
+// Returns true if any done condition holds
+func (e *Env) finished() bool {
+	for _, w := range e.spec.Done {
+		if ok, _ := compare(w.Op, w.read(&e.chip), w.Value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the current display
+// MegaChip shows the CHIP-8 display 4x bigger until the ROM turns MegaChip
+// mode on, then every pixel that isn't black is lit.
+func (e *Env) observe() Observation {
+	w, h := e.Size()
+	obs := Observation{Width: w, Height: h, Pixels: make([]uint8, w*h)}
+	if e.chip.MegaChip() {
+		for i, p := range e.chip.Framebuffer().Pixels {
+			if p.R|p.G|p.B != 0 {
+				obs.Pixels[i] = 1
+			}
+		}
+		return obs
+	}
+
+	buff := e.chip.DisplayBuffer()
+	scale := w / int(chip8.DISPLAY_WIDTH)
+	for y := 0; y < len(buff)*scale && y < h; y++ {
+		for x := 0; x < w; x++ {
+			obs.Pixels[y*w+x] = buff[y/scale][x/scale]
+		}
+	}
+	return obs
+}
+
+// Parses the address, and checks the rest of the watch
+func (w *Watch) parse() error {
+	s := strings.ToLower(strings.TrimSpace(w.Addr))
+	var addr uint64
+	var err error
+	if strings.HasPrefix(s, "0x") {
+		addr, err = strconv.ParseUint(s[2:], 16, 16)
+	} else {
+		addr, err = strconv.ParseUint(s, 10, 16)
+	}
+	if err != nil || addr >= chip8.MEMORY_SIZE {
+		return fmt.Errorf("bad watch address %q :(", w.Addr)
+	}
+	w.addr = uint16(addr)
+
+	if w.Length <= 0 {
+		w.Length = 1
+	}
+	if w.Length > 8 {
+		return fmt.Errorf("watches are up to 8 bytes :(")
+	}
+	if w.Format != "" && w.Format != "uint" && w.Format != "bcd" {
+		return fmt.Errorf("unknown watch format %q :(", w.Format)
+	}
+	return nil
+}
+
+// Reads the number
+func (w *Watch) read(c *chip8.Chip8) int64 {
+	var val int64
+	for _, b := range c.ReadMemory(w.addr, w.Length) {
+		if w.Format == "bcd" {
+			val = val*10 + int64(b%10)
+		} else {
+			val = val<<8 | int64(b)
+		}
+	}
+	return val
+}
+
+// Returns the reward scale
+func (w *Watch) scale() float64 {
+	if w.Scale == 0 {
+		return 1
+	}
+	return w.Scale
+}
+
+// Compares a and b with op
+// Returns false for ok if op is unknown
+func compare(op string, a, b int64) (result bool, ok bool) {
+	switch op {
+	case "==", "":
+		return a == b, true
+	case "!=":
+		return a != b, true
+	case "<":
+		return a < b, true
+	case "<=":
+		return a <= b, true
+	case ">":
+		return a > b, true
+	case ">=":
+		return a >= b, true
+	}
+	return false, false
+}
@@ -0,0 +1,254 @@
+package env
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/romdb"
+	"chippy/pkg/rominfo"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Adds one to the byte at 0x300 every frame, waiting on the delay timer
+//
+//	200: A300  I = 300
+//	202: 6101  V1 = 1
+//	204: F115  DT = V1
+//	206: F007  V0 = DT
+//	208: 3000  skip if V0 == 0
+//	20A: 1206  wait
+//	20C: 7201  V2 += 1
+//	20E: 8020  V0 = V2
+//	210: F055  [300] = V0
+//	212: 1204  again
+var ticker = []byte{
+	0xA3, 0x00, 0x61, 0x01, 0xF1, 0x15, 0xF0, 0x07, 0x30, 0x00,
+	0x12, 0x06, 0x72, 0x01, 0x80, 0x20, 0xF0, 0x55, 0x12, 0x04,
+}
+
+// Turns MegaChip mode on and shows a 2x2 red sprite, then loops, see
+// pkg/chip8's megachip tests
+var megaROM = func() []byte {
+	rom := make([]byte, 0x108)
+	copy(rom, []byte{
+		0x00, 0x11,
+		0x01, 0x00, 0x03, 0x00,
+		0x02, 0x01,
+		0x01, 0x00, 0x03, 0x04,
+		0x03, 0x02, 0x04, 0x02,
+		0x60, 0x0A, 0x61, 0x14,
+		0xD0, 0x10,
+		0x00, 0xE0,
+		0x12, 0x18,
+	})
+	copy(rom[0x100:], []byte{0xFF, 0xFF, 0x00, 0x00, 1, 1, 1, 1})
+	return rom
+}()
+
+// Points the config dir somewhere empty for the test
+func emptyConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	old, had := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	})
+	return dir
+}
+
+// Returns a spec that rewards ticker's count and ends the game at 10
+func tickerSpec() Spec {
+	return Spec{
+		FrameSkip: 2,
+		Reward:    []Watch{{Addr: "0x300"}},
+		Done:      []Watch{{Addr: "0x300", Op: ">=", Value: 10}},
+	}
+}
+
+func TestStepReward(t *testing.T) {
+	emptyConfig(t)
+	e, err := New(ticker, tickerSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0.0
+	steps := 0
+	for done := false; !done; steps++ {
+		if steps > 100 {
+			t.Fatalf("never done")
+		}
+		var reward float64
+		_, reward, done = e.Step(0)
+		total += reward
+	}
+	count := int64(e.chip.Memory(0x300))
+	if count < 10 || total != float64(count) {
+		t.Errorf("count %d, total reward %v, want the same and at least 10", count, total)
+	}
+	// The last step stops on the frame the game ends
+	frame := e.Frame()
+	if frame <= uint64(steps-1)*2 || frame > uint64(steps)*2 || e.Truncated() {
+		t.Errorf("frame %d, truncated %v after %d steps", frame, e.Truncated(), steps)
+	}
+
+	// Steps after the end do nothing
+	if _, reward, done := e.Step(0); reward != 0 || !done || e.Frame() != frame {
+		t.Errorf("step after done = %v, %v at frame %d", reward, done, e.Frame())
+	}
+}
+
+func TestMaxFrames(t *testing.T) {
+	emptyConfig(t)
+	spec := tickerSpec()
+	spec.MaxFrames = 3
+	e, err := New(ticker, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Step(0)
+	if _, _, done := e.Step(0); !done || !e.Truncated() || e.Frame() != 3 {
+		t.Errorf("done %v, truncated %v at frame %d, want done and truncated at 3", done, e.Truncated(), e.Frame())
+	}
+}
+
+func TestReset(t *testing.T) {
+	emptyConfig(t)
+	e, err := New(megaROM, Spec{Settings: romdb.Settings{Platform: "megachip"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := e.base.StateHash()
+
+	// Playing doesn't reach back into the CHIP-8 every game starts from
+	var obs Observation
+	for i := 0; i < 10; i++ {
+		obs, _, _ = e.Step(0)
+	}
+	if obs.Pixels[20*obs.Width+10] != 1 {
+		t.Fatalf("sprite not drawn")
+	}
+	if e.base.StateHash() != base {
+		t.Errorf("playing changed the starting state")
+	}
+
+	obs = e.Reset(0)
+	start := e.base.Snapshot()
+	start.SetSeed(0)
+	if e.Frame() != 0 || e.chip.StateHash() != start.StateHash() {
+		t.Errorf("reset to frame %d, a different state", e.Frame())
+	}
+	for _, p := range obs.Pixels {
+		if p != 0 {
+			t.Fatalf("display not blank after reset")
+		}
+	}
+}
+
+func TestObservationSize(t *testing.T) {
+	emptyConfig(t)
+	tests := []struct {
+		platform      string
+		width, height int
+	}{
+		{"chip8", 64, 32},
+		{"hires", 64, 64},
+		{"megachip", 256, 192},
+	}
+	for _, tt := range tests {
+		e, err := New(ticker, Spec{Settings: romdb.Settings{Platform: tt.platform}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		obs := e.Reset(0)
+		if w, h := e.Size(); w != tt.width || h != tt.height {
+			t.Errorf("%s: size %dx%d, want %dx%d", tt.platform, w, h, tt.width, tt.height)
+		}
+		if obs.Width != tt.width || obs.Height != tt.height || len(obs.Pixels) != tt.width*tt.height {
+			t.Errorf("%s: observation %dx%d with %d pixels", tt.platform, obs.Width, obs.Height, len(obs.Pixels))
+		}
+		if len(obs.Packed()) != tt.width*tt.height/8 {
+			t.Errorf("%s: packed into %d bytes", tt.platform, len(obs.Packed()))
+		}
+	}
+}
+
+func TestPacked(t *testing.T) {
+	obs := Observation{Width: 16, Height: 1, Pixels: make([]uint8, 16)}
+	obs.Pixels[0] = 1
+	obs.Pixels[9] = 2
+	obs.Pixels[15] = 1
+	if p := obs.Packed(); len(p) != 2 || p[0] != 0x80 || p[1] != 0x41 {
+		t.Errorf("Packed = % X, want 80 41", p)
+	}
+}
+
+func TestUserDatabase(t *testing.T) {
+	// The user's overrides make ticker a MegaChip ROM
+	dir := emptyConfig(t)
+	overrides := map[string]romdb.Settings{rominfo.Analyze(ticker).SHA1: {Platform: "megachip"}}
+	data, err := json.Marshal(overrides)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "chippy"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "chippy", "overrides.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(ticker, Spec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := e.Size(); w != 64 {
+		t.Errorf("overrides used without userDatabase")
+	}
+	e, err = New(ticker, Spec{UserDatabase: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w, _ := e.Size(); w != 256 {
+		t.Errorf("overrides not used with userDatabase")
+	}
+}
+
+func TestBadSpecs(t *testing.T) {
+	emptyConfig(t)
+	tests := []struct {
+		name string
+		spec Spec
+	}{
+		{"key", Spec{Actions: [][]int{{0x10}}}},
+		{"address", Spec{Reward: []Watch{{Addr: "0x1000"}}}},
+		{"length", Spec{Reward: []Watch{{Addr: "0", Length: 9}}}},
+		{"format", Spec{Reward: []Watch{{Addr: "0", Format: "float"}}}},
+		{"op", Spec{Done: []Watch{{Addr: "0", Op: "=~"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(ticker, tt.spec); err == nil {
+			t.Errorf("%s: New took it", tt.name)
+		}
+	}
+}
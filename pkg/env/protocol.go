@@ -0,0 +1,113 @@
+package env
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// A request from the trainer, one JSON object per line:
+//
+//	{"cmd": "spec"}
+//	{"cmd": "reset", "seed": 42}
+//	{"cmd": "step", "action": 3}
+//	{"cmd": "close"}
+type Request struct {
+	Cmd    string `json:"cmd"`
+	Seed   int64  `json:"seed"`
+	Action int    `json:"action"`
+}
+
+// A reply to the trainer, one JSON object per line
+// obs is the display packed by Observation.Packed, base64 encoded
+type Reply struct {
+	Obs       string  `json:"obs,omitempty"`
+	Reward    float64 `json:"reward"`
+	Done      bool    `json:"done"`
+	Truncated bool    `json:"truncated"`
+	Frame     uint64  `json:"frame"`
+
+	// Only for spec
+	Actions   int `json:"actions,omitempty"`
+	Width     int `json:"width,omitempty"`
+	Height    int `json:"height,omitempty"`
+	FrameSkip int `json:"frameSkip,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Serves the environment over line-delimited JSON until close or EOF
+func Serve(e *Env, r io.Reader, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	enc := json.NewEncoder(w)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var req Request
+		var reply Reply
+		if err := json.Unmarshal(line, &req); err != nil {
+			reply.Error = fmt.Sprintf("bad request: %v", err)
+		} else {
+			switch req.Cmd {
+			case "spec":
+				width, height := e.Size()
+				reply = Reply{
+					Actions:   e.ActionCount(),
+					Width:     width,
+					Height:    height,
+					FrameSkip: e.Spec().FrameSkip,
+				}
+			case "reset":
+				obs := e.Reset(req.Seed)
+				reply = e.reply(&obs, 0, false)
+			case "step":
+				if req.Action < 0 || req.Action >= e.ActionCount() {
+					reply.Error = fmt.Sprintf("action must be 0 to %d :(", e.ActionCount()-1)
+					break
+				}
+				obs, reward, done := e.Step(req.Action)
+				reply = e.reply(&obs, reward, done)
+			case "close":
+				return nil
+			default:
+				reply.Error = fmt.Sprintf("unknown cmd %q :(", req.Cmd)
+			}
+		}
+
+		if err := enc.Encode(reply); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Builds the reply to a reset or step
+func (e *Env) reply(obs *Observation, reward float64, done bool) Reply {
+	return Reply{
+		Obs:       base64.StdEncoding.EncodeToString(obs.Packed()),
+		Reward:    reward,
+		Done:      done,
+		Truncated: e.Truncated(),
+		Frame:     e.frames,
+	}
+}
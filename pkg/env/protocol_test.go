@@ -0,0 +1,107 @@
+package env
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Serves the requests, one per line, and returns the replies
+func serve(t *testing.T, e *Env, requests ...string) []Reply {
+	t.Helper()
+	var out bytes.Buffer
+	if err := Serve(e, strings.NewReader(strings.Join(requests, "\n")), &out); err != nil {
+		t.Fatal(err)
+	}
+	var replies []Reply
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var reply Reply
+		if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+			t.Fatalf("reply %q: %v", scanner.Text(), err)
+		}
+		replies = append(replies, reply)
+	}
+	return replies
+}
+
+func TestServe(t *testing.T) {
+	emptyConfig(t)
+	e, err := New(ticker, tickerSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := serve(t, e,
+		`{"cmd": "spec"}`,
+		`{"cmd": "reset", "seed": 7}`,
+		``,
+		`{"cmd": "step", "action": 0}`,
+		`{"cmd": "close"}`,
+		`{"cmd": "step", "action": 0}`,
+	)
+	if len(replies) != 3 {
+		t.Fatalf("%d replies, want 3 and nothing after close", len(replies))
+	}
+
+	spec := replies[0]
+	if spec.Actions != 17 || spec.Width != 64 || spec.Height != 32 || spec.FrameSkip != 2 {
+		t.Errorf("spec = %+v", spec)
+	}
+
+	for _, reply := range replies[1:] {
+		obs, err := base64.StdEncoding.DecodeString(reply.Obs)
+		if err != nil || len(obs) != 64*32/8 || reply.Error != "" {
+			t.Errorf("reply = %+v, %d bytes of obs, %v", reply, len(obs), err)
+		}
+	}
+	if step := replies[2]; step.Frame != 2 || step.Reward <= 0 || step.Done {
+		t.Errorf("step = %+v, want a reward at frame 2", step)
+	}
+}
+
+func TestServeBadRequests(t *testing.T) {
+	emptyConfig(t)
+	e, err := New(ticker, tickerSpec())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := []string{
+		`not json`,
+		`{"cmd": "dance"}`,
+		`{"cmd": "step", "action": 17}`,
+		`{"cmd": "step", "action": -1}`,
+		`{"cmd": "step", "action": "up"}`,
+	}
+	replies := serve(t, e, requests...)
+	if len(replies) != len(requests) {
+		t.Fatalf("%d replies to %d requests", len(replies), len(requests))
+	}
+	for i, reply := range replies {
+		if reply.Error == "" {
+			t.Errorf("%s: no error", requests[i])
+		}
+	}
+	if e.Frame() != 0 {
+		t.Errorf("bad requests ran %d frames", e.Frame())
+	}
+}
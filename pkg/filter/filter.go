@@ -0,0 +1,221 @@
+package filter
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/palette"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+// Options configures the post-processing pipeline
+type Options struct {
+	// Fraction of a pixel's glow kept every frame after it turns off
+	// 0 turns pixels off instantly, values close to 1 leave long trails.
+	// This hides the flicker from sprites being XORed off and on again.
+	Persistence float64
+
+	// Darken the last row of every scaled pixel, like CRT scanlines
+	Scanlines bool
+
+	// Draw a thin gap between pixels, like an LCD grid
+	PixelGap bool
+
+	// How much darker scanlines and gaps are, 0 to 1
+	Shade float64
+
+	// Output pixels per CHIP-8 pixel, in both directions
+	Scale int
+}
+
+// Default options, used as the base for ParseOptions
+var DefaultOptions = Options{
+	Persistence: 0.75,
+	Shade:       0.45,
+	Scale:       4,
+}
+
+// Parses a -filter value, a comma separated list of phosphor, scanlines and
+// gaps, or crt for all of them. Returns false if no filter was asked for.
+func ParseOptions(spec string, persistence float64) (Options, bool, error) {
+	opts := DefaultOptions
+	opts.Persistence = 0
+	if spec == "" || spec == "none" {
+		return opts, false, nil
+	}
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.TrimSpace(name) {
+		case "phosphor":
+			opts.Persistence = persistence
+		case "scanlines":
+			opts.Scanlines = true
+		case "gaps":
+			opts.PixelGap = true
+		case "crt":
+			opts.Persistence = persistence
+			opts.Scanlines = true
+			opts.PixelGap = true
+		default:
+			return opts, false, fmt.Errorf("unknown filter %q, expected phosphor, scanlines, gaps or crt :(", name)
+		}
+	}
+	if persistence < 0 || persistence >= 1 {
+		return opts, false, fmt.Errorf("persistence must be between 0 and 1 :(")
+	}
+	return opts, true, nil
+}
+
+// Filter turns CHIP-8 frames into RGBA pixels, one frame at a time
+// Phosphor persistence means it keeps state between frames, so every
+// frame should go through the same Filter. Glow fades by 60Hz frames told to
+// Advance, not by calls to Apply, so it fades at the same speed whatever the
+// tick rate and however often the screen is redrawn.
+type Filter struct {
+	opts Options
+
+	// Source size, in CHIP-8 pixels
+	width  int
+	height int
+
+	// Output width asked for, Resize picks scales that stay close to it
+	target int
+
+	// Current glow of every CHIP-8 pixel, RGB
+	glow []float64
+
+	// RGBA output, width*scale by height*scale
+	out []byte
+
+	// 60Hz frames gone by since the last Apply, that glow hasn't faded for
+	frames uint64
+
+	// Colour of every CHIP-8 pixel, when Apply looks them up in a palette
+	colors []color.RGBA
+}
+
+// Creates a filter for frames of the given size
+func New(opts Options, width, height int) *Filter {
+	if opts.Scale < 1 {
+		opts.Scale = 1
+	}
+	f := &Filter{opts: opts, target: width * opts.Scale}
+	f.Resize(width, height)
+	return f
+}
+
+// Changes the source size, for displays that change size as they run
+// The scale changes to keep the output about as wide, and glow starts over
+func (f *Filter) Resize(width, height int) {
+	f.opts.Scale = f.target / width
+	if f.opts.Scale < 1 {
+		f.opts.Scale = 1
+	}
+	f.width = width
+	f.height = height
+	f.glow = make([]float64, width*height*3)
+	f.out = make([]byte, width*f.opts.Scale*height*f.opts.Scale*4)
+	f.colors = make([]color.RGBA, width*height)
+}
+
+// Returns the output size, in pixels
+func (f *Filter) Size() (int, int) {
+	return f.width * f.opts.Scale, f.height * f.opts.Scale
+}
+
+// Returns the bytes per output row, for SDL texture updates
+func (f *Filter) Pitch() int {
+	return f.width * f.opts.Scale * 4
+}
+
+// Tells the filter that CHIP-8 frames went by, the next Apply fades the glow
+// by that many frames
+func (f *Filter) Advance(frames uint64) {
+	f.frames += frames
+}
+
+// Runs one frame through the pipeline
+// pixels holds one CHIP-8 pixel value per byte, row by row. The returned
+// RGBA buffer is reused by the next call.
+func (f *Filter) Apply(pixels []uint8, pal palette.Palette) []byte {
+	for i := 0; i < len(f.colors) && i < len(pixels); i++ {
+		f.colors[i] = pal.Color(pixels[i])
+	}
+	return f.ApplyColors(pixels, f.colors, pal.Color(0))
+}
+
+// Runs one frame through the pipeline, with its own colour for every pixel
+// pixels says which CHIP-8 pixels are lit, colors holds the colour of each,
+// lit or not, and scanlines and gaps fade towards bg.
+func (f *Filter) ApplyColors(pixels []uint8, colors []color.RGBA, bg color.RGBA) []byte {
+	f.decay(pixels, colors)
+
+	scale := f.opts.Scale
+	outWidth := f.width * scale
+	for y := 0; y < f.height; y++ {
+		for x := 0; x < f.width; x++ {
+			g := f.glow[(y*f.width+x)*3:]
+			for sy := 0; sy < scale; sy++ {
+				for sx := 0; sx < scale; sx++ {
+					r, gr, b := g[0], g[1], g[2]
+
+					// Darken scanlines and the gaps between pixels towards the background
+					edge := scale > 1 && ((f.opts.Scanlines && sy == scale-1) ||
+						(f.opts.PixelGap && (sx == scale-1 || sy == scale-1)))
+					if edge {
+						r = shade(r, float64(bg.R), f.opts.Shade)
+						gr = shade(gr, float64(bg.G), f.opts.Shade)
+						b = shade(b, float64(bg.B), f.opts.Shade)
+					}
+
+					o := ((y*scale+sy)*outWidth + x*scale + sx) * 4
+					f.out[o] = uint8(r)
+					f.out[o+1] = uint8(gr)
+					f.out[o+2] = uint8(b)
+					f.out[o+3] = 255
+				}
+			}
+		}
+	}
+
+	return f.out
+}
+
+// Updates the glow of every pixel, lit pixels are instantly at full
+// brightness and unlit ones fade back towards their colour, once for every
+// frame since the last Apply
+func (f *Filter) decay(pixels []uint8, colors []color.RGBA) {
+	p := math.Pow(f.opts.Persistence, float64(f.frames))
+	f.frames = 0
+	for i := 0; i < f.width*f.height && i < len(pixels) && i < len(colors); i++ {
+		c := colors[i]
+		target := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+		g := f.glow[i*3 : i*3+3]
+		for ch := 0; ch < 3; ch++ {
+			if pixels[i] != 0 {
+				g[ch] = target[ch]
+			} else {
+				g[ch] = target[ch] + (g[ch]-target[ch])*p
+			}
+		}
+	}
+}
+
+// Moves a colour channel the given amount towards the background
+func shade(v, bg, amount float64) float64 {
+	return v + (bg-v)*amount
+}
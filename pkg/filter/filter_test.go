@@ -0,0 +1,110 @@
+package filter
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/palette"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	tests := []struct {
+		spec        string
+		persistence float64
+		on          bool
+		want        Options
+		wantErr     bool
+	}{
+		{"", 0.5, false, Options{}, false},
+		{"none", 0.5, false, Options{}, false},
+		{"phosphor", 0.5, true, Options{Persistence: 0.5}, false},
+		{"scanlines, gaps", 0.5, true, Options{Scanlines: true, PixelGap: true}, false},
+		{"crt", 0.25, true, Options{Persistence: 0.25, Scanlines: true, PixelGap: true}, false},
+		{"blur", 0.5, false, Options{}, true},
+		{"phosphor", 1, false, Options{}, true},
+		{"phosphor", -0.1, false, Options{}, true},
+	}
+	for _, tt := range tests {
+		opts, on, err := ParseOptions(tt.spec, tt.persistence)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseOptions(%q, %v) error = %v", tt.spec, tt.persistence, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		want := tt.want
+		want.Shade = DefaultOptions.Shade
+		want.Scale = DefaultOptions.Scale
+		if on != tt.on || opts != want {
+			t.Errorf("ParseOptions(%q, %v) = %+v, %v, want %+v, %v", tt.spec, tt.persistence, opts, on, want, tt.on)
+		}
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	pal := palette.Presets[0]
+	f := New(Options{Persistence: 0.5, Scale: 1}, 2, 1)
+
+	out := f.Apply([]uint8{1, 0}, pal)
+	if out[0] != 255 || out[4] != 0 {
+		t.Fatalf("lit, unlit = %d, %d, want 255, 0", out[0], out[4])
+	}
+
+	// Nothing fades until frames go by
+	out = f.Apply([]uint8{0, 0}, pal)
+	if out[0] != 255 {
+		t.Errorf("glow faded without any frames: %d", out[0])
+	}
+	f.Advance(1)
+	out = f.Apply([]uint8{0, 0}, pal)
+	if out[0] != 127 {
+		t.Errorf("glow after a frame = %d, want 127", out[0])
+	}
+	f.Advance(2)
+	out = f.Apply([]uint8{0, 0}, pal)
+	if out[0] != 31 {
+		t.Errorf("glow after three frames = %d, want 31", out[0])
+	}
+}
+
+func TestScanlines(t *testing.T) {
+	pal := palette.Presets[0]
+	f := New(Options{Scanlines: true, Shade: 0.5, Scale: 2}, 1, 1)
+	if w, h := f.Size(); w != 2 || h != 2 || f.Pitch() != 8 {
+		t.Fatalf("size %dx%d, pitch %d", w, h, f.Pitch())
+	}
+
+	out := f.Apply([]uint8{1}, pal)
+	if out[0] != 255 || out[4] != 255 {
+		t.Errorf("top row = %d, %d, want 255", out[0], out[4])
+	}
+	if out[8] != 127 || out[12] != 127 {
+		t.Errorf("scanline = %d, %d, want 127", out[8], out[12])
+	}
+}
+
+func TestResize(t *testing.T) {
+	f := New(Options{Scale: 4}, 64, 32)
+	f.Resize(128, 64)
+	if w, h := f.Size(); w != 256 || h != 128 {
+		t.Errorf("after Resize, size %dx%d, want 256x128", w, h)
+	}
+	f.Resize(512, 256)
+	if w, _ := f.Size(); w != 512 {
+		t.Errorf("scale dropped below 1, width %d", w)
+	}
+}
@@ -0,0 +1,358 @@
+package httpapi
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bytes"
+	"chippy"
+	"chippy/pkg/chip8"
+	"chippy/pkg/palette"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Largest number of cycles one step request may run
+const MAX_STEP = 1000000
+
+// Largest PNG scale
+const MAX_SCALE = 32
+
+// Server is a JSON control API for a running CHIP-8
+//
+// The CHIP-8 is shared with whoever runs it, so the server is given a lock,
+// and every request is served under it. Whoever runs the CHIP-8 should skip
+// frames while Paused() returns true.
+type Server struct {
+	lock sync.Locker
+	chip *chip8.Chip8
+
+	// Paused through the API
+	paused bool
+
+	mux *http.ServeMux
+}
+
+// Registers, as returned by GET /registers
+type Registers struct {
+	V      [16]uint8 `json:"v"`
+	I      uint16    `json:"i"`
+	PC     uint16    `json:"pc"`
+	SP     uint16    `json:"sp"`
+	DT     uint8     `json:"dt"`
+	ST     uint8     `json:"st"`
+	Stack  []uint16  `json:"stack"`
+	Opcode uint16    `json:"opcode"`
+	Frame  uint64    `json:"frame"`
+	Cycles uint64    `json:"cycles"`
+	Paused bool      `json:"paused"`
+}
+
+// Creates an API server for the CHIP-8, guarded by the lock
+func New(c *chip8.Chip8, lock sync.Locker) *Server {
+	s := &Server{lock: lock, chip: c, mux: http.NewServeMux()}
+	s.handle("/rom", http.MethodPost, s.loadROM)
+	s.handle("/reset", http.MethodPost, s.reset)
+	s.handle("/pause", http.MethodPost, s.pause)
+	s.handle("/resume", http.MethodPost, s.resume)
+	s.handle("/step", http.MethodPost, s.step)
+	s.handle("/press", http.MethodPost, s.key)
+	s.handle("/release", http.MethodPost, s.key)
+	s.handle("/registers", http.MethodGet, s.registers)
+	s.handle("/memory", http.MethodGet, s.memory)
+	s.handle("/display", http.MethodGet, s.display)
+	return s
+}
+
+// Serves the API on a listener until it fails
+func (s *Server) Serve(l net.Listener) error {
+	return http.Serve(l, s)
+}
+
+// Serves one request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Returns true while the CHIP-8 is paused through the API
+// Call with the lock held
+func (s *Server) Paused() bool {
+	return s.paused
+}
+
+// Serves one request with the lock held
+// Returns the JSON to reply with, a rawReply, or an error for a 400
+type handler func(r *http.Request) (interface{}, error)
+
+// A reply that isn't JSON, encoded and written once the lock is released
+type rawReply struct {
+	contentType string
+	encode      func() ([]byte, error)
+}
+
+// An error replied with its own status, instead of a 400
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e statusError) Error() string {
+	return e.err.Error()
+}
+
+// Registers a handler for one method
+func (s *Server) handle(path string, method string, h handler) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s only :(", method))
+			return
+		}
+
+		reply, status, err := s.run(h, r)
+		if err != nil {
+			writeError(w, status, err)
+			return
+		}
+		if raw, ok := reply.(rawReply); ok {
+			data, err := raw.encode()
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.Header().Set("Content-Type", raw.contentType)
+			w.Write(data)
+			return
+		}
+		if reply != nil {
+			writeJSON(w, http.StatusOK, reply)
+		}
+	})
+}
+
+// Runs a handler with the lock held
+// Returns its reply, or an error and the status to send it with: 400 for
+// errors the handler returns, 500 if the CHIP-8 panicked. A CHIP-8 that
+// panics is paused, so whoever runs it doesn't trip over the same thing.
+func (s *Server) run(h handler, r *http.Request) (reply interface{}, status int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	defer func() {
+		if p := recover(); p != nil {
+			s.paused = true
+			reply, status, err = nil, http.StatusInternalServerError, fmt.Errorf("CHIP-8 crashed: %v :(", p)
+		}
+	}()
+	reply, err = h(r)
+	if serr, ok := err.(statusError); ok {
+		return nil, serr.status, serr
+	}
+	return reply, http.StatusBadRequest, err
+}
+
+// POST /rom loads the ROM in the body, or ?builtin=<name>, and resets
+func (s *Server) loadROM(r *http.Request) (interface{}, error) {
+	var rom []byte
+	var err error
+	if name := r.URL.Query().Get("builtin"); name != "" {
+		rom, err = chippy.ReadBuiltinROM(name)
+	} else {
+		// Read one byte more than fits, so we can tell the ROM is too large
+		rom, err = io.ReadAll(io.LimitReader(r.Body, int64(s.chip.MaxROMSize())+1))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rom) > s.chip.MaxROMSize() {
+		return nil, statusError{http.StatusRequestEntityTooLarge,
+			fmt.Errorf("ROM is over %d bytes, too large to fit in memory :(", s.chip.MaxROMSize())}
+	}
+	if _, err := s.chip.LoadROMBytes(rom); err != nil {
+		return nil, err
+	}
+	s.chip.Reset()
+	return map[string]interface{}{"size": len(rom), "sha1": s.chip.ROMHash()}, nil
+}
+
+// POST /reset resets the CHIP-8, and loads the ROM again
+func (s *Server) reset(r *http.Request) (interface{}, error) {
+	s.chip.Reset()
+	return s.state(), nil
+}
+
+// POST /pause stops the CHIP-8 running frames
+func (s *Server) pause(r *http.Request) (interface{}, error) {
+	s.paused = true
+	return s.state(), nil
+}
+
+// POST /resume lets the CHIP-8 run frames again
+func (s *Server) resume(r *http.Request) (interface{}, error) {
+	s.paused = false
+	return s.state(), nil
+}
+
+// POST /step?cycles=N runs N cycles, 1 by default
+func (s *Server) step(r *http.Request) (interface{}, error) {
+	n := 1
+	if v := r.URL.Query().Get("cycles"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 1 || n > MAX_STEP {
+			return nil, fmt.Errorf("cycles must be 1 to %d :(", MAX_STEP)
+		}
+	}
+	for i := 0; i < n; i++ {
+		s.chip.Cycle()
+	}
+	return s.state(), nil
+}
+
+// POST /press?key=K and POST /release?key=K work the keypad
+func (s *Server) key(r *http.Request) (interface{}, error) {
+	kc, err := strconv.ParseUint(r.URL.Query().Get("key"), 16, 4)
+	if err != nil {
+		return nil, fmt.Errorf("key must be a hex digit :(")
+	}
+	if r.URL.Path == "/press" {
+		s.chip.KeyPress(int(kc))
+	} else {
+		s.chip.KeyRelease(int(kc))
+	}
+	return map[string]interface{}{"key": kc, "pressed": r.URL.Path == "/press"}, nil
+}
+
+// GET /registers returns the registers, stack and timing counters
+func (s *Server) registers(r *http.Request) (interface{}, error) {
+	return s.state(), nil
+}
+
+// GET /memory?addr=A&len=N returns N bytes from A (hex with 0x, or decimal)
+// as hex in JSON, or raw with &format=raw
+func (s *Server) memory(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	addr, err := parseNumber(q.Get("addr"), 0)
+	if err != nil || addr >= chip8.MEMORY_SIZE {
+		return nil, fmt.Errorf("addr must be below 0x%X :(", chip8.MEMORY_SIZE)
+	}
+	n, err := parseNumber(q.Get("len"), chip8.MEMORY_SIZE-addr)
+	if err != nil || addr+n > chip8.MEMORY_SIZE {
+		return nil, fmt.Errorf("len runs past the end of memory :(")
+	}
+	data := s.chip.ReadMemory(uint16(addr), n)
+
+	if q.Get("format") == "raw" {
+		return rawReply{"application/octet-stream", func() ([]byte, error) { return data, nil }}, nil
+	}
+	return map[string]interface{}{"addr": addr, "data": hex.EncodeToString(data)}, nil
+}
+
+// GET /display returns the display as a PNG, scaled by &scale=N in the
+// colours of &palette=name, or as rows of pixel values with ?format=json
+func (s *Server) display(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	buff := s.chip.DisplayBuffer()
+
+	if q.Get("format") == "json" {
+		rows := make([][]uint8, len(buff))
+		for y := range buff {
+			rows[y] = append([]uint8(nil), buff[y][:]...)
+		}
+		return map[string]interface{}{
+			"width":  chip8.DISPLAY_WIDTH,
+			"height": len(buff),
+			"pixels": rows,
+		}, nil
+	}
+
+	scale, err := parseNumber(q.Get("scale"), 1)
+	if err != nil || scale < 1 || scale > MAX_SCALE {
+		return nil, fmt.Errorf("scale must be 1 to %d :(", MAX_SCALE)
+	}
+	pal := palette.Presets[0]
+	if name := q.Get("palette"); name != "" {
+		if pal, err = palette.Lookup(name); err != nil {
+			return nil, err
+		}
+	}
+
+	// buff is a copy, so the PNG can be drawn without the lock
+	return rawReply{"image/png", func() ([]byte, error) {
+		img := image.NewRGBA(image.Rect(0, 0, int(chip8.DISPLAY_WIDTH)*scale, len(buff)*scale))
+		for y := 0; y < img.Rect.Dy(); y++ {
+			for x := 0; x < img.Rect.Dx(); x++ {
+				img.SetRGBA(x, y, pal.Color(buff[y/scale][x/scale]))
+			}
+		}
+		var out bytes.Buffer
+		if err := png.Encode(&out, img); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	}}, nil
+}
+
+// Returns the registers, stack and timing counters
+func (s *Server) state() Registers {
+	regs := Registers{
+		I:      s.chip.I(),
+		PC:     s.chip.PC(),
+		SP:     s.chip.SP(),
+		DT:     s.chip.DelayTimer(),
+		ST:     s.chip.SoundTimer(),
+		Stack:  s.chip.Stack(),
+		Opcode: s.chip.Opcode(),
+		Frame:  s.chip.Frame(),
+		Cycles: s.chip.Cycles(),
+		Paused: s.paused,
+	}
+	for x := range regs.V {
+		regs.V[x] = s.chip.V(x)
+	}
+	return regs
+}
+
+// Parses a number, hex with 0x, otherwise decimal, or def if it is empty
+func parseNumber(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+	if strings.HasPrefix(strings.ToLower(s), "0x") {
+		n, err := strconv.ParseUint(s[2:], 16, 32)
+		return int(n), err
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	return int(n), err
+}
+
+// Writes a JSON reply
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Writes a JSON error reply
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,249 @@
+package httpapi
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bytes"
+	"chippy/pkg/chip8"
+	"encoding/hex"
+	"encoding/json"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Starts an API server for a fresh CHIP-8
+func start(t *testing.T) (*httptest.Server, *sync.Mutex) {
+	t.Helper()
+	c := chip8.Init()
+	lock := &sync.Mutex{}
+	ts := httptest.NewServer(New(&c, lock))
+	t.Cleanup(ts.Close)
+	return ts, lock
+}
+
+// Makes a request, checks the status and decodes the JSON reply into out
+func call(t *testing.T, ts *httptest.Server, method string, path string, body []byte, status int, out interface{}) {
+	t.Helper()
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		t.Fatalf("%s %s = %d, want %d", method, path, resp.StatusCode, status)
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+}
+
+func TestStep(t *testing.T) {
+	ts, _ := start(t)
+
+	// 6005 7003: V0 = 5, V0 += 3
+	call(t, ts, "POST", "/rom", []byte{0x60, 0x05, 0x70, 0x03}, http.StatusOK, nil)
+
+	var regs Registers
+	call(t, ts, "POST", "/step?cycles=2", nil, http.StatusOK, &regs)
+	if regs.V[0] != 8 || regs.PC != 0x204 {
+		t.Errorf("V0, PC = %d, %03X after two steps, want 8, 204", regs.V[0], regs.PC)
+	}
+
+	call(t, ts, "POST", "/step?cycles=0", nil, http.StatusBadRequest, nil)
+	call(t, ts, "GET", "/step", nil, http.StatusMethodNotAllowed, nil)
+
+	call(t, ts, "POST", "/reset", nil, http.StatusOK, &regs)
+	if regs.V[0] != 0 || regs.PC != 0x200 {
+		t.Errorf("V0, PC = %d, %03X after reset, want 0, 200", regs.V[0], regs.PC)
+	}
+}
+
+func TestLoadAtEndOfMemory(t *testing.T) {
+	ts, _ := start(t)
+
+	// AFFF F165: load V0 and V1 from the last byte of memory and the first
+	call(t, ts, "POST", "/rom", []byte{0xAF, 0xFF, 0xF1, 0x65}, http.StatusOK, nil)
+	var regs Registers
+	call(t, ts, "POST", "/step?cycles=2", nil, http.StatusOK, &regs)
+	if regs.PC != 0x204 || regs.I != 0xFFF {
+		t.Errorf("PC, I = %03X, %03X, want 204, FFF", regs.PC, regs.I)
+	}
+}
+
+// Tracer that panics, standing in for a bug in the core
+type panicTracer struct{}
+
+func (panicTracer) Trace(r *chip8.TraceRecord) {
+	panic("oops")
+}
+
+func TestCrash(t *testing.T) {
+	c := chip8.Init()
+	c.SetTracer(panicTracer{})
+	lock := &sync.Mutex{}
+	ts := httptest.NewServer(New(&c, lock))
+	defer ts.Close()
+
+	call(t, ts, "POST", "/rom", []byte{0x12, 0x00}, http.StatusOK, nil)
+	var reply map[string]string
+	call(t, ts, "POST", "/step?cycles=100", nil, http.StatusInternalServerError, &reply)
+	if !strings.Contains(reply["error"], "crashed") {
+		t.Errorf("error = %q", reply["error"])
+	}
+
+	// The lock is free again, and the CHIP-8 paused
+	locked := make(chan struct{})
+	go func() {
+		lock.Lock()
+		lock.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the crash left the lock held")
+	}
+	var regs Registers
+	call(t, ts, "GET", "/registers", nil, http.StatusOK, &regs)
+	if !regs.Paused {
+		t.Errorf("CHIP-8 still running after a crash")
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	ts, _ := start(t)
+
+	var regs Registers
+	call(t, ts, "POST", "/pause", nil, http.StatusOK, &regs)
+	if !regs.Paused {
+		t.Errorf("not paused")
+	}
+	call(t, ts, "POST", "/resume", nil, http.StatusOK, &regs)
+	if regs.Paused {
+		t.Errorf("still paused")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ts, _ := start(t)
+	rom := []byte{0x12, 0x00}
+	call(t, ts, "POST", "/rom", rom, http.StatusOK, nil)
+
+	var reply struct {
+		Addr int    `json:"addr"`
+		Data string `json:"data"`
+	}
+	call(t, ts, "GET", "/memory?addr=0x200&len=2", nil, http.StatusOK, &reply)
+	if reply.Addr != 0x200 || reply.Data != hex.EncodeToString(rom) {
+		t.Errorf("memory = %+v, want %s at 512", reply, hex.EncodeToString(rom))
+	}
+
+	call(t, ts, "GET", "/memory?addr=0x1000", nil, http.StatusBadRequest, nil)
+	call(t, ts, "GET", "/memory?addr=0xFFF&len=2", nil, http.StatusBadRequest, nil)
+}
+
+func TestROMTooLarge(t *testing.T) {
+	ts, _ := start(t)
+	c := chip8.Init()
+
+	rom := make([]byte, c.MaxROMSize())
+	call(t, ts, "POST", "/rom", rom, http.StatusOK, nil)
+	call(t, ts, "POST", "/rom", append(rom, 0), http.StatusRequestEntityTooLarge, nil)
+}
+
+func TestRawMemory(t *testing.T) {
+	ts, _ := start(t)
+	call(t, ts, "POST", "/rom", []byte{0x12, 0x00}, http.StatusOK, nil)
+
+	resp, err := ts.Client().Get(ts.URL + "/memory?addr=0x200&len=2&format=raw")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header.Get("Content-Type") != "application/octet-stream" || !bytes.Equal(data, []byte{0x12, 0x00}) {
+		t.Errorf("raw memory = %s % X", resp.Header.Get("Content-Type"), data)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	ts, _ := start(t)
+
+	var reply struct {
+		Key     int  `json:"key"`
+		Pressed bool `json:"pressed"`
+	}
+	call(t, ts, "POST", "/press?key=a", nil, http.StatusOK, &reply)
+	if reply.Key != 0xA || !reply.Pressed {
+		t.Errorf("press = %+v", reply)
+	}
+	call(t, ts, "POST", "/release?key=a", nil, http.StatusOK, &reply)
+	if reply.Pressed {
+		t.Errorf("release = %+v", reply)
+	}
+	call(t, ts, "POST", "/press?key=10", nil, http.StatusBadRequest, nil)
+}
+
+func TestDisplay(t *testing.T) {
+	ts, _ := start(t)
+
+	// A000 D005: draw the 0 glyph at 0,0
+	call(t, ts, "POST", "/rom", []byte{0xA0, 0x00, 0xD0, 0x05}, http.StatusOK, nil)
+	call(t, ts, "POST", "/step?cycles=2", nil, http.StatusOK, nil)
+
+	var reply struct {
+		Width  int       `json:"width"`
+		Height int       `json:"height"`
+		Pixels [][]uint8 `json:"pixels"`
+	}
+	call(t, ts, "GET", "/display?format=json", nil, http.StatusOK, &reply)
+	if reply.Width != int(chip8.DISPLAY_WIDTH) || len(reply.Pixels) != reply.Height {
+		t.Fatalf("display is %dx%d with %d rows", reply.Width, reply.Height, len(reply.Pixels))
+	}
+	if reply.Pixels[0][0] != 1 || reply.Pixels[1][1] != 0 {
+		t.Errorf("0 glyph not drawn: % d", reply.Pixels[0][:4])
+	}
+
+	resp, err := ts.Client().Get(ts.URL + "/display?scale=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	img, err := png.Decode(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != reply.Width*2 || img.Bounds().Dy() != reply.Height*2 {
+		t.Errorf("PNG is %v, want %dx%d", img.Bounds(), reply.Width*2, reply.Height*2)
+	}
+
+	call(t, ts, "GET", "/display?scale=100", nil, http.StatusBadRequest, nil)
+}
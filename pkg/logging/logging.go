@@ -0,0 +1,118 @@
+package logging
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is how important a log message is
+type Level int
+
+const (
+	LEVEL_DEBUG Level = iota
+	LEVEL_INFO
+	LEVEL_WARN
+	LEVEL_ERROR
+
+	// Drops every message
+	LEVEL_OFF
+)
+
+// Returns the name of the level, as used on the command line
+func (l Level) String() string {
+	switch l {
+	case LEVEL_DEBUG:
+		return "debug"
+	case LEVEL_INFO:
+		return "info"
+	case LEVEL_WARN:
+		return "warn"
+	case LEVEL_ERROR:
+		return "error"
+	case LEVEL_OFF:
+		return "off"
+	default:
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+}
+
+// Parses a level name, as returned by Level.String()
+func ParseLevel(name string) (Level, error) {
+	for l := LEVEL_DEBUG; l <= LEVEL_OFF; l++ {
+		if strings.EqualFold(name, l.String()) {
+			return l, nil
+		}
+	}
+	return LEVEL_INFO, fmt.Errorf("unknown log level %q :(", name)
+}
+
+// Logger writes messages at or above its level as lines of text:
+//
+//	15:04:05.000 INFO Loading ROM into memory... size=132
+//
+// It satisfies chip8.Logger, and is safe to share between goroutines
+type Logger struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level Level
+}
+
+// Creates a logger writing to w
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{w: w, level: level}
+}
+
+// Returns true if messages at the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, args ...interface{}) { l.log(LEVEL_DEBUG, msg, args) }
+func (l *Logger) Info(msg string, args ...interface{})  { l.log(LEVEL_INFO, msg, args) }
+func (l *Logger) Warn(msg string, args ...interface{})  { l.log(LEVEL_WARN, msg, args) }
+func (l *Logger) Error(msg string, args ...interface{}) { l.log(LEVEL_ERROR, msg, args) }
+
+// Formats and writes one message
+func (l *Logger) log(level Level, msg string, args []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(time.Now().Format("15:04:05.000"))
+	b.WriteByte(' ')
+	b.WriteString(strings.ToUpper(level.String()))
+	b.WriteByte(' ')
+	b.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+		} else {
+			// A key without a value, like slog we keep it anyway
+			fmt.Fprintf(&b, " !BADKEY=%v", args[i])
+		}
+	}
+	b.WriteByte('\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	io.WriteString(l.w, b.String())
+}
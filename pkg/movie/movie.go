@@ -0,0 +1,239 @@
+package movie
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Movie file format version
+const VERSION = 1
+
+// Movie is a recording of every input to a CHIP-8 run, plus everything else
+// needed to play it back exactly: the ROM, the random seed and the settings
+type Movie struct {
+	Version int `json:"version"`
+
+	// SHA-1 of the ROM the movie was recorded with
+	ROM string `json:"rom"`
+
+	// Random number generator seed
+	Seed int64 `json:"seed"`
+
+	// Emulation settings, these change what the ROM does
+	Platform   string       `json:"platform,omitempty"`
+	Timing     string       `json:"timing"`
+	ClockSpeed uint32       `json:"clockSpeed"`
+	Quirks     chip8.Quirks `json:"quirks"`
+
+	// Length of the movie, in 60Hz frames
+	Frames uint64 `json:"frames"`
+
+	// SHA-1 of the display at the end of the movie, checked on playback
+	Display string `json:"display,omitempty"`
+
+	// Every key press and release, in order
+	Events []Event `json:"events"`
+}
+
+// Event is a key press or release, at the start of the given frame
+type Event struct {
+	Frame   uint64 `json:"frame"`
+	Key     int    `json:"key"`
+	Pressed bool   `json:"pressed"`
+}
+
+// Loads a movie from a file
+func Load(file string) (*Movie, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	m := &Movie{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse movie %s: %v", file, err)
+	}
+	if m.Version != VERSION {
+		return nil, fmt.Errorf("movie %s is version %d, we only play version %d :(", file, m.Version, VERSION)
+	}
+	return m, nil
+}
+
+// Saves the movie to a file
+func (m *Movie) Save(file string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+// Returns the SHA-1 of the CHIP-8 display, hex encoded
+// MegaChip mode hashes its true colour display instead
+func DisplayHash(c *chip8.Chip8) string {
+	h := sha1.New()
+	if c.MegaChip() {
+		for _, p := range c.Framebuffer().Pixels {
+			h.Write([]byte{p.R, p.G, p.B})
+		}
+		return hex.EncodeToString(h.Sum(nil))
+	}
+	buff := c.DisplayBuffer()
+	for row := range buff {
+		h.Write(buff[row][:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Recorder records key presses on their way to a CHIP-8
+type Recorder struct {
+	chip  *chip8.Chip8
+	movie Movie
+}
+
+// Starts recording a CHIP-8, which should have its ROM loaded and settings
+// applied, but not have run yet
+func NewRecorder(c *chip8.Chip8) *Recorder {
+	return &Recorder{
+		chip: c,
+		movie: Movie{
+			Version:    VERSION,
+			ROM:        c.ROMHash(),
+			Seed:       c.Seed(),
+			Platform:   c.Platform().String(),
+			Timing:     c.Timing().String(),
+			ClockSpeed: c.ClockSpeed(),
+			Quirks:     c.Quirks(),
+			Events:     []Event{},
+		},
+	}
+}
+
+// Records a key press, and passes it on
+func (r *Recorder) KeyPress(kc int) {
+	r.record(kc, true)
+	r.chip.KeyPress(kc)
+}
+
+// Records a key release, and passes it on
+func (r *Recorder) KeyRelease(kc int) {
+	r.record(kc, false)
+	r.chip.KeyRelease(kc)
+}
+
+// Adds an event at the current frame
+func (r *Recorder) record(kc int, pressed bool) {
+	if kc < 0x0 || kc > 0xF {
+		return
+	}
+	r.movie.Events = append(r.movie.Events, Event{Frame: r.chip.Frame(), Key: kc, Pressed: pressed})
+}
+
+// Stops recording, and returns the movie
+func (r *Recorder) Finish() *Movie {
+	m := r.movie
+	m.Frames = r.chip.Frame()
+	m.Display = DisplayHash(r.chip)
+	return &m
+}
+
+// Player feeds a movie's key presses back into a CHIP-8
+type Player struct {
+	movie *Movie
+
+	// Next event to play
+	next int
+}
+
+// Creates a player for the given movie
+func NewPlayer(m *Movie) *Player {
+	return &Player{movie: m}
+}
+
+// Returns the movie being played
+func (p *Player) Movie() *Movie {
+	return p.movie
+}
+
+// Gets a CHIP-8 ready to play the movie, it should have the movie's ROM
+// loaded but not have run yet
+func (p *Player) Setup(c *chip8.Chip8) error {
+	if c.ROMHash() != p.movie.ROM {
+		return fmt.Errorf("movie was recorded with ROM %s, but %s is loaded :(", p.movie.ROM, c.ROMHash())
+	}
+	// Older movies were all plain CHIP-8
+	platform := chip8.PLATFORM_CHIP8
+	if p.movie.Platform != "" {
+		var err error
+		if platform, err = chip8.ParsePlatform(p.movie.Platform); err != nil {
+			return err
+		}
+	}
+	mode, err := chip8.ParseTimingMode(p.movie.Timing)
+	if err != nil {
+		return err
+	}
+	if platform != c.Platform() {
+		c.SetPlatform(platform)
+	}
+	c.SetTiming(mode)
+	c.SetClockSpeed(p.movie.ClockSpeed)
+	c.SetQuirks(p.movie.Quirks)
+	c.SetSeed(p.movie.Seed)
+	p.next = 0
+	return nil
+}
+
+// Presses and releases keys for the frame the CHIP-8 is about to run
+// Call this before every frame
+func (p *Player) Feed(c *chip8.Chip8) {
+	for p.next < len(p.movie.Events) && p.movie.Events[p.next].Frame <= c.Frame() {
+		e := p.movie.Events[p.next]
+		if e.Pressed {
+			c.KeyPress(e.Key)
+		} else {
+			c.KeyRelease(e.Key)
+		}
+		p.next++
+	}
+}
+
+// Returns true once the CHIP-8 has run every frame of the movie
+func (p *Player) Done(c *chip8.Chip8) bool {
+	return c.Frame() >= p.movie.Frames
+}
+
+// Plays a whole movie as fast as possible, without a window
+// Returns an error if the display at the end differs from the recording
+func Replay(c *chip8.Chip8, m *Movie) error {
+	p := NewPlayer(m)
+	if err := p.Setup(c); err != nil {
+		return err
+	}
+	for !p.Done(c) {
+		p.Feed(c)
+		c.RunFrame()
+	}
+	if m.Display != "" && DisplayHash(c) != m.Display {
+		return fmt.Errorf("display differs from the recording after %d frames :(", m.Frames)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package movie
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy"
+	"chippy/pkg/chip8"
+	"path/filepath"
+	"testing"
+)
+
+// Frames to record, ten seconds
+const testFrames = 600
+
+// Scripted input for Space Invaders: move left, fire, move right, fire
+var script = []Event{
+	{Frame: 30, Key: 0x5, Pressed: true},
+	{Frame: 35, Key: 0x5, Pressed: false},
+	{Frame: 60, Key: 0x4, Pressed: true},
+	{Frame: 120, Key: 0x4, Pressed: false},
+	{Frame: 150, Key: 0x5, Pressed: true},
+	{Frame: 151, Key: 0x5, Pressed: false},
+	{Frame: 200, Key: 0x6, Pressed: true},
+	{Frame: 320, Key: 0x6, Pressed: false},
+	{Frame: 321, Key: 0x5, Pressed: true},
+	{Frame: 400, Key: 0x5, Pressed: false},
+}
+
+// Returns a CHIP-8 with the bundled ROM loaded
+func loadBuiltin(t *testing.T, name string) *chip8.Chip8 {
+	t.Helper()
+	rom, err := chippy.ReadBuiltinROM(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := chip8.Init()
+	if _, err := c.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func TestRecordReplay(t *testing.T) {
+	// Record, remembering the state after every frame
+	c := loadBuiltin(t, "space_invaders")
+	c.SetTiming(chip8.TIMING_VIP)
+	rec := NewRecorder(c)
+	hashes := make([]uint64, 0, testFrames)
+	next := 0
+	for c.Frame() < testFrames {
+		for next < len(script) && script[next].Frame == c.Frame() {
+			if script[next].Pressed {
+				rec.KeyPress(script[next].Key)
+			} else {
+				rec.KeyRelease(script[next].Key)
+			}
+			next++
+		}
+		c.RunFrame()
+		hashes = append(hashes, c.StateHash())
+	}
+
+	// Round trip it through a file
+	file := filepath.Join(t.TempDir(), "invaders.json")
+	if err := rec.Finish().Save(file); err != nil {
+		t.Fatal(err)
+	}
+	m, err := Load(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Events) != len(script) || m.Frames != testFrames {
+		t.Fatalf("movie has %d events over %d frames, want %d over %d", len(m.Events), m.Frames, len(script), testFrames)
+	}
+
+	// Play it back on a fresh CHIP-8, it must match frame by frame
+	c = loadBuiltin(t, "space_invaders")
+	p := NewPlayer(m)
+	if err := p.Setup(c); err != nil {
+		t.Fatal(err)
+	}
+	for !p.Done(c) {
+		p.Feed(c)
+		c.RunFrame()
+		if got, want := c.StateHash(), hashes[c.Frame()-1]; got != want {
+			t.Fatalf("state differs from the recording at frame %d: %016x, want %016x", c.Frame(), got, want)
+		}
+	}
+
+	// And in one go
+	c = loadBuiltin(t, "space_invaders")
+	if err := Replay(c, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayWrongROM(t *testing.T) {
+	c := loadBuiltin(t, "ibm_logo")
+	rec := NewRecorder(c)
+	for c.Frame() < 10 {
+		c.RunFrame()
+	}
+	m := rec.Finish()
+
+	if err := Replay(loadBuiltin(t, "tron"), m); err == nil {
+		t.Errorf("replayed a movie on the wrong ROM")
+	}
+}
+
+func TestReplayDisplayMismatch(t *testing.T) {
+	c := loadBuiltin(t, "ibm_logo")
+	rec := NewRecorder(c)
+	for c.Frame() < 10 {
+		c.RunFrame()
+	}
+	m := rec.Finish()
+	m.Display = "0000000000000000000000000000000000000000"
+
+	if err := Replay(loadBuiltin(t, "ibm_logo"), m); err == nil {
+		t.Errorf("replay didn't notice the display differs")
+	}
+}
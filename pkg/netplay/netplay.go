@@ -0,0 +1,428 @@
+package netplay
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"chippy/pkg/chip8"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Frames of input delay used when none is given
+// Two frames hides a ~30ms round trip, so rollbacks stay rare on a LAN
+const DEFAULT_DELAY = 2
+
+// Most frames we run ahead of the peer's confirmed keys before waiting
+// for them, any of these frames may have to run again
+const MAX_ROLLBACK = 12
+
+// Frames between state hash checks
+const HASH_INTERVAL = 60
+
+// Options for our side of a session
+type Options struct {
+	// Keys we control, bit N is key N
+	// Zero takes every key the peer doesn't
+	Keys uint16
+
+	// Frames between a key press and the CHIP-8 seeing it
+	// Only the host's counts, the peer plays with the same delay
+	Delay int
+}
+
+// Stats counts the work a session has done
+type Stats struct {
+	Frames      uint64
+	Rollbacks   uint64
+	Resimulated uint64
+	Stalls      uint64
+}
+
+// Session runs a CHIP-8 in lockstep with a peer's, each side pressing its
+// own keys. Our keys are sent to the peer and both sides apply them after
+// the input delay. Until the peer's keys for a frame arrive we guess they're
+// still holding what they held last, and if we guessed wrong we roll back
+// to a snapshot and run those frames again with the real keys.
+type Session struct {
+	chip *chip8.Chip8
+	conn net.Conn
+	w    *bufio.Writer
+	enc  *json.Encoder
+	dec  *json.Decoder
+
+	// Messages from the reader goroutine
+	incoming chan message
+	done     chan struct{}
+
+	delay  uint64
+	local  uint16
+	remote uint16
+
+	// Our keys held down right now
+	held uint16
+
+	// Next frame to run
+	frame uint64
+
+	// The peer's keys are known for every frame before this
+	confirmed  uint64
+	lastRemote uint16
+
+	// Keys for each frame that may still run again
+	localInputs  map[uint64]uint16
+	remoteInputs map[uint64]uint16
+	predicted    map[uint64]uint16
+
+	// Earliest frame we guessed the peer's keys wrong for
+	mispredicted bool
+	rollbackTo   uint64
+
+	// The CHIP-8 at the start of each of the last MAX_ROLLBACK frames
+	snapshots [MAX_ROLLBACK]chip8.Chip8
+
+	// State hashes waiting for the other side's to compare with
+	nextHash   uint64
+	hashes     map[uint64]uint64
+	peerHashes map[uint64]uint64
+
+	stats Stats
+
+	// Set once the peer hangs up, or we fall out of sync
+	hungUp error
+	err    error
+}
+
+// Creates a session on a fresh connection, ready for the handshake
+func newSession(c *chip8.Chip8, conn net.Conn) *Session {
+	w := bufio.NewWriter(conn)
+	return &Session{
+		chip:         c,
+		conn:         conn,
+		w:            w,
+		enc:          json.NewEncoder(w),
+		dec:          json.NewDecoder(bufio.NewReader(conn)),
+		incoming:     make(chan message, 4*MAX_ROLLBACK),
+		done:         make(chan struct{}),
+		localInputs:  map[uint64]uint16{},
+		remoteInputs: map[uint64]uint16{},
+		predicted:    map[uint64]uint16{},
+		hashes:       map[uint64]uint64{},
+		peerHashes:   map[uint64]uint64{},
+	}
+}
+
+// Starts playing, once both sides have agreed how
+func (s *Session) start(local uint16, remote uint16, delay uint64) {
+	s.local = local
+	s.remote = remote
+	s.delay = delay
+
+	// Nobody presses anything during the first delay frames
+	s.confirmed = delay
+
+	s.conn.SetDeadline(time.Time{})
+	go s.read()
+}
+
+// Returns the keys we control
+func (s *Session) LocalKeys() uint16 {
+	return s.local
+}
+
+// Returns the keys the peer controls
+func (s *Session) RemoteKeys() uint16 {
+	return s.remote
+}
+
+// Returns the input delay, in frames
+func (s *Session) Delay() uint64 {
+	return s.delay
+}
+
+// Returns the work done so far
+func (s *Session) Stats() Stats {
+	stats := s.stats
+	stats.Frames = s.frame
+	return stats
+}
+
+// Presses one of our keys, keys the peer controls are ignored
+func (s *Session) KeyPress(kc int) {
+	s.held |= s.local & (1 << uint(kc))
+}
+
+// Releases one of our keys
+func (s *Session) KeyRelease(kc int) {
+	s.held &^= 1 << uint(kc)
+}
+
+// Runs the next frame, with our keys and the peer's
+// Returns false without running anything if we're MAX_ROLLBACK frames ahead
+// of the peer, they need to catch up first
+func (s *Session) Advance() (bool, error) {
+	s.receive(false)
+	s.settle()
+	if s.err != nil {
+		return false, s.err
+	}
+
+	if s.frame >= s.confirmed+MAX_ROLLBACK {
+		if s.hungUp != nil {
+			return false, s.hungUp
+		}
+		s.stats.Stalls++
+		return false, s.flush()
+	}
+
+	// Our keys take effect after the input delay, on both sides
+	input := s.frame + s.delay
+	s.localInputs[input] = s.held
+	s.send(message{Type: "input", Frame: input, Keys: s.held})
+
+	s.simulate()
+	s.settle()
+	if s.err != nil {
+		return true, s.err
+	}
+	return true, s.flush()
+}
+
+// Runs the given number of frames as fast as the peer keeps up, without
+// pressing any keys of our own, then waits for the peer to catch up
+func (s *Session) Run(frames uint64) error {
+	for s.frame < frames {
+		ran, err := s.Advance()
+		if err != nil {
+			return err
+		}
+		if !ran {
+			s.receive(true)
+		}
+	}
+	return s.Sync()
+}
+
+// Waits until the peer's keys are known for every frame we've run, rolling
+// back if they weren't what we guessed
+func (s *Session) Sync() error {
+	for s.confirmed < s.frame && s.hungUp == nil && s.err == nil {
+		s.receive(true)
+	}
+	s.settle()
+	if s.err != nil {
+		return s.err
+	}
+	if s.confirmed < s.frame {
+		return s.hungUp
+	}
+	return s.flush()
+}
+
+// Hangs up on the peer
+func (s *Session) Close() error {
+	close(s.done)
+	return s.conn.Close()
+}
+
+// Reads messages from the peer until they hang up
+func (s *Session) read() {
+	defer close(s.incoming)
+	for {
+		var m message
+		if err := s.dec.Decode(&m); err != nil {
+			m = message{Type: "hangup", Error: err.Error()}
+		}
+		select {
+		case s.incoming <- m:
+		case <-s.done:
+			return
+		}
+		if m.Type == "hangup" {
+			return
+		}
+	}
+}
+
+// Handles every message that has arrived, waiting for one first if block is set
+func (s *Session) receive(block bool) {
+	for s.hungUp == nil {
+		var m message
+		var ok bool
+		if block {
+			m, ok = <-s.incoming
+			block = false
+		} else {
+			select {
+			case m, ok = <-s.incoming:
+			default:
+				return
+			}
+		}
+		if !ok {
+			return
+		}
+		s.handle(m)
+	}
+}
+
+// Handles a message from the peer
+func (s *Session) handle(m message) {
+	switch m.Type {
+	case "input":
+		// Inputs arrive in order, one per frame
+		if m.Frame != s.confirmed {
+			s.err = fmt.Errorf("expected the peer's keys for frame %d, got frame %d :(", s.confirmed, m.Frame)
+			return
+		}
+		keys := m.Keys & s.remote
+		s.remoteInputs[m.Frame] = keys
+		s.lastRemote = keys
+		s.confirmed++
+
+		// Roll back if we already ran this frame with the wrong keys
+		if guess, ok := s.predicted[m.Frame]; ok {
+			delete(s.predicted, m.Frame)
+			if guess != keys && (!s.mispredicted || m.Frame < s.rollbackTo) {
+				s.mispredicted = true
+				s.rollbackTo = m.Frame
+			}
+		}
+
+	case "hash":
+		s.peerHashes[m.Frame] = m.Hash
+		s.compare(m.Frame)
+
+	case "error":
+		s.err = fmt.Errorf("peer stopped: %s", m.Error)
+
+	case "hangup":
+		s.hungUp = fmt.Errorf("peer hung up: %s", m.Error)
+	}
+}
+
+// Catches up after new keys arrive or a frame runs: rolls back if we
+// guessed wrong, checks hashes, and forgets what can't run again
+func (s *Session) settle() {
+	s.rollBack()
+	s.checkHash()
+	s.forget()
+}
+
+// Runs the current frame with both sides' keys
+func (s *Session) simulate() {
+	s.snapshots[s.frame%MAX_ROLLBACK] = s.chip.Snapshot()
+	s.chip.SetKeys(s.input(s.frame))
+	s.chip.RunFrame()
+	s.frame++
+}
+
+// Returns the keypad for a frame, guessing the peer's keys if they
+// haven't arrived yet
+func (s *Session) input(frame uint64) uint16 {
+	remote := s.remoteInputs[frame]
+	if frame >= s.confirmed {
+		remote = s.lastRemote
+		s.predicted[frame] = remote
+	}
+	return s.localInputs[frame] | remote
+}
+
+// Runs every frame since the first wrong guess again, with the real keys
+func (s *Session) rollBack() {
+	if !s.mispredicted {
+		return
+	}
+	s.mispredicted = false
+	s.stats.Rollbacks++
+
+	end := s.frame
+	s.chip.Restore(s.snapshots[s.rollbackTo%MAX_ROLLBACK])
+	for s.frame = s.rollbackTo; s.frame < end; {
+		s.simulate()
+		s.stats.Resimulated++
+	}
+}
+
+// Sends the state hash for every HASH_INTERVAL'th frame once the keys for
+// every frame before it are known, so it can't change any more
+func (s *Session) checkHash() {
+	for s.nextHash <= s.frame && s.nextHash <= s.confirmed {
+		frame := s.nextHash
+		var hash uint64
+		if frame == s.frame {
+			hash = s.chip.StateHash()
+		} else {
+			hash = s.snapshots[frame%MAX_ROLLBACK].StateHash()
+		}
+		s.hashes[frame] = hash
+		s.send(message{Type: "hash", Frame: frame, Hash: hash})
+		s.compare(frame)
+		s.nextHash += HASH_INTERVAL
+	}
+}
+
+// Compares our state hash for a frame with the peer's, once we have both
+func (s *Session) compare(frame uint64) {
+	ours, ok := s.hashes[frame]
+	if !ok {
+		return
+	}
+	theirs, ok := s.peerHashes[frame]
+	if !ok {
+		return
+	}
+	delete(s.hashes, frame)
+	delete(s.peerHashes, frame)
+	if ours != theirs && s.err == nil {
+		s.err = fmt.Errorf("out of sync at frame %d, our state hash is %016x but the peer's is %016x :(", frame, ours, theirs)
+	}
+}
+
+// Forgets the keys for frames that can't run again
+func (s *Session) forget() {
+	final := s.frame
+	if s.confirmed < final {
+		final = s.confirmed
+	}
+	for frame := range s.localInputs {
+		if frame < final {
+			delete(s.localInputs, frame)
+		}
+	}
+	for frame := range s.remoteInputs {
+		if frame < final {
+			delete(s.remoteInputs, frame)
+		}
+	}
+}
+
+// Queues a message for the peer, it goes out on the next flush
+func (s *Session) send(m message) {
+	if err := s.enc.Encode(m); err != nil && s.err == nil {
+		s.err = err
+	}
+}
+
+// Sends every queued message
+func (s *Session) flush() error {
+	if err := s.w.Flush(); err != nil && s.err == nil {
+		s.err = err
+	}
+	return s.err
+}
@@ -0,0 +1,292 @@
+package netplay
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy"
+	"chippy/pkg/chip8"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Frames each side plays
+const testFrames = 240
+
+// How late everything the peers send each other arrives
+const testLatency = 30 * time.Millisecond
+
+// Where the guest process joins, when the test binary runs as one
+const GUEST_ENV = "CHIPPY_NETPLAY_JOIN"
+
+// What each side presses, and on which frames
+var (
+	hostScript  = map[uint64][]int{10: {0x1}, 40: {0x1}, 90: {0x2}, 95: {0x2}}
+	guestScript = map[uint64][]int{20: {0xC}, 60: {0xC}, 100: {0xD}, 101: {0xD}, 150: {0xC}}
+)
+
+// Returns a CHIP-8 with Tron loaded, it has a player on each side of the keypad
+func loadTron(t *testing.T) *chip8.Chip8 {
+	t.Helper()
+	rom, err := chippy.ReadBuiltinROM("tron")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := chip8.Init()
+	if _, err := c.LoadROMBytes(rom); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+// Forwards connections from the listener to addr, holding everything
+// back by latency on the way, in both directions
+func lag(t *testing.T, l net.Listener, addr string, latency time.Duration) {
+	t.Helper()
+	go func() {
+		for {
+			in, err := l.Accept()
+			if err != nil {
+				return
+			}
+			out, err := net.Dial("tcp", addr)
+			if err != nil {
+				in.Close()
+				return
+			}
+			go relay(in, out, latency)
+			go relay(out, in, latency)
+		}
+	}()
+}
+
+// Copies src to dst, each read arriving latency after it was sent
+func relay(src net.Conn, dst net.Conn, latency time.Duration) {
+	type chunk struct {
+		data []byte
+		at   time.Time
+	}
+	chunks := make(chan chunk, 1024)
+	go func() {
+		defer close(chunks)
+		for {
+			buf := make([]byte, 4096)
+			n, err := src.Read(buf)
+			if n > 0 {
+				chunks <- chunk{buf[:n], time.Now().Add(latency)}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for c := range chunks {
+		time.Sleep(time.Until(c.at))
+		if _, err := dst.Write(c.data); err != nil {
+			break
+		}
+	}
+	io.Copy(io.Discard, src)
+	dst.Close()
+}
+
+// Plays the session to the end, pressing keys from the script on the frames
+// it gives. Returns the first error.
+func play(s *Session, script map[uint64][]int) error {
+	pressed := map[int]bool{}
+	for s.frame < testFrames {
+		for _, kc := range script[s.frame] {
+			if pressed[kc] {
+				s.KeyRelease(kc)
+			} else {
+				s.KeyPress(kc)
+			}
+			pressed[kc] = !pressed[kc]
+		}
+		ran, err := s.Advance()
+		if err != nil {
+			return err
+		}
+		if !ran {
+			s.receive(true)
+		}
+	}
+	return s.Sync()
+}
+
+func TestLateInput(t *testing.T) {
+	host := loadTron(t)
+	guest := loadTron(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	lag(t, proxy, l.Addr().String(), testLatency)
+
+	// The host takes keys 0 to B, the guest the rest
+	hosted := make(chan *Session)
+	go func() {
+		s, err := Host(l, host, Options{Keys: 0x0FFF, Delay: DEFAULT_DELAY})
+		if err != nil {
+			t.Error(err)
+		}
+		hosted <- s
+	}()
+	joined, err := Join(proxy.Addr().String(), guest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer joined.Close()
+	hostSession := <-hosted
+	if hostSession == nil {
+		t.FailNow()
+	}
+	defer hostSession.Close()
+
+	if host.Seed() != guest.Seed() {
+		t.Fatalf("guest seed %d, host seed %d", guest.Seed(), host.Seed())
+	}
+
+	// Both sides steer, the other side hears about it late and guesses
+	// wrong until it does
+	errs := make(chan error, 1)
+	go func() {
+		errs <- play(hostSession, hostScript)
+	}()
+	if err := play(joined, guestScript); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if host.Frame() != guest.Frame() {
+		t.Fatalf("host ran %d frames, guest ran %d", host.Frame(), guest.Frame())
+	}
+	if h, g := host.StateHash(), guest.StateHash(); h != g {
+		t.Errorf("out of sync after %d frames: host %016x, guest %016x", host.Frame(), h, g)
+	}
+	if hostSession.Stats().Rollbacks == 0 && joined.Stats().Rollbacks == 0 {
+		t.Errorf("late input never made anyone roll back")
+	}
+}
+
+func TestAcrossProcesses(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts another process")
+	}
+	host := loadTron(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// The guest is this test binary again, running TestGuestProcess
+	cmd := exec.Command(os.Args[0], "-test.run=^TestGuestProcess$")
+	cmd.Env = append(os.Environ(), GUEST_ENV+"="+l.Addr().String())
+	var out strings.Builder
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer cmd.Process.Kill()
+
+	s, err := Host(l, host, Options{Keys: 0x0FFF, Delay: DEFAULT_DELAY})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := play(s, hostScript); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatalf("guest: %v\n%s", err, out.String())
+	}
+
+	want := fmt.Sprintf("guest %d %016x", host.Frame(), host.StateHash())
+	if !strings.Contains(out.String(), want) {
+		t.Errorf("out of sync, host has %q, guest said:\n%s", want, out.String())
+	}
+}
+
+// Plays the guest side of TestAcrossProcesses, when run as its guest
+func TestGuestProcess(t *testing.T) {
+	addr := os.Getenv(GUEST_ENV)
+	if addr == "" {
+		t.Skip("only runs as the guest of TestAcrossProcesses")
+	}
+	guest := loadTron(t)
+	s, err := Join(addr, guest, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if err := play(s, guestScript); err != nil {
+		t.Fatal(err)
+	}
+	fmt.Printf("guest %d %016x\n", guest.Frame(), guest.StateHash())
+}
+
+func TestResolveKeys(t *testing.T) {
+	tests := []struct {
+		ours, theirs  uint16
+		local, remote uint16
+		wantErr       bool
+	}{
+		{0x00FF, 0, 0x00FF, 0xFF00, false},
+		{0, 0x00FF, 0xFF00, 0x00FF, false},
+		{0x000F, 0x00F0, 0x000F, 0x00F0, false},
+		{0, 0, 0, 0, true},
+		{0x0003, 0x0002, 0, 0, true},
+	}
+	for _, tt := range tests {
+		local, remote, err := resolveKeys(tt.ours, tt.theirs)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveKeys(%04X, %04X) error = %v", tt.ours, tt.theirs, err)
+			continue
+		}
+		if err == nil && (local != tt.local || remote != tt.remote) {
+			t.Errorf("resolveKeys(%04X, %04X) = %04X, %04X, want %04X, %04X", tt.ours, tt.theirs, local, remote, tt.local, tt.remote)
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	mask, err := ParseKeys("01aF")
+	if err != nil || mask != 0x8403 {
+		t.Errorf("ParseKeys = %04X, %v, want 8403", mask, err)
+	}
+	if FormatKeys(mask) != "01AF" {
+		t.Errorf("FormatKeys = %q, want 01AF", FormatKeys(mask))
+	}
+	if _, err := ParseKeys("0g"); err == nil {
+		t.Errorf("ParseKeys took a g")
+	}
+}
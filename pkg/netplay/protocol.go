@@ -0,0 +1,264 @@
+package netplay
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Netplay protocol version, both sides must match
+const VERSION = 1
+
+// How long the peer gets to answer while we say hello
+const HANDSHAKE_TIMEOUT = 10 * time.Second
+
+// A message between peers, one JSON object per line:
+//
+//	{"type": "hello", "version": 1, "rom": "...", "seed": 42, ...}
+//	{"type": "start"}
+//	{"type": "input", "frame": 120, "keys": 16}
+//	{"type": "hash", "frame": 120, "hash": 1234}
+//	{"type": "error", "error": "..."}
+type message struct {
+	Type string `json:"type"`
+
+	// Only for hello, the joiner only sends version, rom and keys
+	Version    int           `json:"version,omitempty"`
+	ROM        string        `json:"rom,omitempty"`
+	Seed       int64         `json:"seed,omitempty"`
+	Platform   string        `json:"platform,omitempty"`
+	Timing     string        `json:"timing,omitempty"`
+	ClockSpeed uint32        `json:"clockSpeed,omitempty"`
+	Quirks     *chip8.Quirks `json:"quirks,omitempty"`
+	Delay      int           `json:"delay,omitempty"`
+
+	// Keys claimed in hello, keys held down in input
+	Keys uint16 `json:"keys,omitempty"`
+
+	// Only for input and hash
+	Frame uint64 `json:"frame,omitempty"`
+	Hash  uint64 `json:"hash,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// Waits for a peer to join on the listener, then starts a session
+// The CHIP-8 should have its ROM loaded and settings applied, but not have
+// run yet. The peer plays with our settings and input delay.
+func Host(l net.Listener, c *chip8.Chip8, opts Options) (*Session, error) {
+	if opts.Delay < 0 {
+		return nil, fmt.Errorf("input delay can't be negative :(")
+	}
+	conn, err := l.Accept()
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(c, conn)
+	conn.SetDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+
+	// Tell the peer how to set up
+	quirks := c.Quirks()
+	s.send(message{
+		Type:       "hello",
+		Version:    VERSION,
+		ROM:        c.ROMHash(),
+		Seed:       c.Seed(),
+		Platform:   c.Platform().String(),
+		Timing:     c.Timing().String(),
+		ClockSpeed: c.ClockSpeed(),
+		Quirks:     &quirks,
+		Delay:      opts.Delay,
+		Keys:       opts.Keys,
+	})
+	if err := s.flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// The peer checks everything and says hello back
+	var reply message
+	if err := s.dec.Decode(&reply); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.agree(reply, "hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	local, remote, err := resolveKeys(opts.Keys, reply.Keys)
+	if err == nil && reply.ROM != c.ROMHash() {
+		err = fmt.Errorf("peer has ROM %s loaded, but we have %s :(", reply.ROM, c.ROMHash())
+	}
+	if err != nil {
+		s.refuse(err)
+		return nil, err
+	}
+	s.send(message{Type: "start"})
+	if err := s.flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s.start(local, remote, uint64(opts.Delay))
+	return s, nil
+}
+
+// Joins a peer hosting on the given address, and starts a session
+// The CHIP-8 should have its ROM loaded but not have run yet, it takes the
+// host's settings.
+func Join(addr string, c *chip8.Chip8, opts Options) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, HANDSHAKE_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+	s := newSession(c, conn)
+	conn.SetDeadline(time.Now().Add(HANDSHAKE_TIMEOUT))
+
+	// Check we can play with the host
+	var hello message
+	if err := s.dec.Decode(&hello); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.agree(hello, "hello"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	local, remote, err := resolveKeys(opts.Keys, hello.Keys)
+	if err == nil && hello.ROM != c.ROMHash() {
+		err = fmt.Errorf("host has ROM %s loaded, but we have %s :(", hello.ROM, c.ROMHash())
+	}
+	if err == nil {
+		err = s.setup(hello)
+	}
+	if err != nil {
+		s.refuse(err)
+		return nil, err
+	}
+
+	// Say hello back, and wait for the go ahead
+	s.send(message{Type: "hello", Version: VERSION, ROM: c.ROMHash(), Keys: opts.Keys})
+	if err := s.flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var start message
+	if err := s.dec.Decode(&start); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := s.agree(start, "start"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s.start(local, remote, uint64(hello.Delay))
+	return s, nil
+}
+
+// Returns an error unless the message is the one we were expecting, from a
+// peer speaking our version
+func (s *Session) agree(m message, want string) error {
+	switch {
+	case m.Type == "error":
+		return fmt.Errorf("peer refused: %s", m.Error)
+	case m.Type != want:
+		return fmt.Errorf("expected %s from the peer, got %q :(", want, m.Type)
+	case want == "hello" && m.Version != VERSION:
+		return fmt.Errorf("peer speaks netplay version %d, we speak %d :(", m.Version, VERSION)
+	}
+	return nil
+}
+
+// Tells the peer why we won't play, and hangs up
+func (s *Session) refuse(err error) {
+	s.send(message{Type: "error", Error: err.Error()})
+	s.flush()
+	s.conn.Close()
+}
+
+// Sets the CHIP-8 up the way the host has theirs
+func (s *Session) setup(hello message) error {
+	mode, err := chip8.ParseTimingMode(hello.Timing)
+	if err != nil {
+		return err
+	}
+	if hello.Delay < 0 {
+		return fmt.Errorf("host asked for a negative input delay :(")
+	}
+	if hello.Platform != "" {
+		platform, err := chip8.ParsePlatform(hello.Platform)
+		if err != nil {
+			return err
+		}
+		if platform != s.chip.Platform() {
+			s.chip.SetPlatform(platform)
+		}
+	}
+	s.chip.SetTiming(mode)
+	s.chip.SetClockSpeed(hello.ClockSpeed)
+	if hello.Quirks != nil {
+		s.chip.SetQuirks(*hello.Quirks)
+	}
+	s.chip.SetSeed(hello.Seed)
+	return nil
+}
+
+// Splits the keypad between the two sides, given the keys each claimed
+// A side claiming nothing gets every key the other side didn't claim
+func resolveKeys(ours uint16, theirs uint16) (local uint16, remote uint16, err error) {
+	switch {
+	case ours == 0 && theirs == 0:
+		return 0, 0, fmt.Errorf("neither side picked any keys, one of us needs -netplay-keys :(")
+	case ours == 0:
+		ours = ^theirs
+	case theirs == 0:
+		theirs = ^ours
+	}
+	if ours&theirs != 0 {
+		return 0, 0, fmt.Errorf("both sides want keys %s :(", FormatKeys(ours&theirs))
+	}
+	return ours, theirs, nil
+}
+
+// Parses a set of CHIP-8 keys given as hex digits, like 0123 or 0,1,2,3
+func ParseKeys(s string) (uint16, error) {
+	var mask uint16
+	for _, r := range strings.ReplaceAll(s, ",", "") {
+		key, err := strconv.ParseUint(string(r), 16, 8)
+		if err != nil {
+			return 0, fmt.Errorf("bad CHIP-8 key %q :(", r)
+		}
+		mask |= 1 << key
+	}
+	return mask, nil
+}
+
+// Returns the keys in a mask as hex digits, the way ParseKeys takes them
+func FormatKeys(mask uint16) string {
+	var keys strings.Builder
+	for key := 0; key <= 0xF; key++ {
+		if mask&(1<<uint(key)) != 0 {
+			fmt.Fprintf(&keys, "%X", key)
+		}
+	}
+	return keys.String()
+}
@@ -0,0 +1,116 @@
+package palette
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Palette maps CHIP-8 pixel values to colours
+// Colour 0 is the background and colour 1 the foreground. Colours 2 and 3
+// are for multi-plane modes, where a pixel can be set in either or both
+// planes.
+type Palette struct {
+	Name   string
+	Colors [4]color.RGBA
+}
+
+// Built-in palettes, in the order the hotkey cycles through them
+var Presets = []Palette{
+	{"classic", [4]color.RGBA{rgb(0x000000), rgb(0xFFFFFF), rgb(0xAAAAAA), rgb(0x555555)}},
+	{"green", [4]color.RGBA{rgb(0x0A1A0A), rgb(0x33FF33), rgb(0x1FA01F), rgb(0x99FF99)}},
+	{"amber", [4]color.RGBA{rgb(0x1A0F00), rgb(0xFFB000), rgb(0xA06E00), rgb(0xFFD480)}},
+	{"gameboy", [4]color.RGBA{rgb(0x9BBC0F), rgb(0x0F380F), rgb(0x8BAC0F), rgb(0x306230)}},
+	{"octo", [4]color.RGBA{rgb(0x996600), rgb(0xFFCC00), rgb(0xFF6600), rgb(0x662200)}},
+	{"lcd", [4]color.RGBA{rgb(0xC7D0B5), rgb(0x2B3323), rgb(0x7A8566), rgb(0x4F5A42)}},
+}
+
+// VP-590 colour board colours, for CHIP-8X
+// Bit 0 is red, bit 1 blue and bit 2 green
+var VP590 = [8]color.RGBA{
+	rgb(0x000000), rgb(0xFF0000), rgb(0x0000FF), rgb(0xFF00FF),
+	rgb(0x00FF00), rgb(0xFFFF00), rgb(0x00FFFF), rgb(0xFFFFFF),
+}
+
+// Returns the built-in palette with the given name
+func Lookup(name string) (Palette, error) {
+	for _, p := range Presets {
+		if p.Name == name {
+			return p, nil
+		}
+	}
+	return Palette{}, fmt.Errorf("unknown palette %q, expected one of %v :(", name, Names())
+}
+
+// Returns the names of all built-in palettes
+func Names() []string {
+	names := make([]string, len(Presets))
+	for i, p := range Presets {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// Returns the built-in palette after the given one, wrapping around
+// Custom palettes cycle back to the first preset
+func Next(p Palette) Palette {
+	for i := range Presets {
+		if Presets[i].Name == p.Name {
+			return Presets[(i+1)%len(Presets)]
+		}
+	}
+	return Presets[0]
+}
+
+// Returns the colour for the given CHIP-8 pixel value
+func (p Palette) Color(pixel uint8) color.RGBA {
+	return p.Colors[pixel&0x3]
+}
+
+// Returns a copy of the palette with its first colours replaced by the given
+// HTML hex colours (#RRGGBB), background first
+func (p Palette) WithColors(hex []string) (Palette, error) {
+	if len(hex) > len(p.Colors) {
+		return p, fmt.Errorf("too many colours, a palette has %d :(", len(p.Colors))
+	}
+	for i, h := range hex {
+		c, err := ParseColor(h)
+		if err != nil {
+			return p, err
+		}
+		p.Colors[i] = c
+	}
+	p.Name = "custom"
+	return p, nil
+}
+
+// Parses an HTML hex colour, like #FF00FF
+func ParseColor(hex string) (color.RGBA, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("bad colour %q, expected #RRGGBB :(", hex)
+	}
+	return rgb(uint32(v)), nil
+}
+
+// Returns the opaque colour for a 0xRRGGBB value
+func rgb(v uint32) color.RGBA {
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+}
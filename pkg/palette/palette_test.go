@@ -0,0 +1,99 @@
+package palette
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		hex     string
+		want    color.RGBA
+		wantErr bool
+	}{
+		{"#FF00FF", color.RGBA{R: 0xFF, B: 0xFF, A: 0xFF}, false},
+		{" 0a1b2c ", color.RGBA{R: 0x0A, G: 0x1B, B: 0x2C, A: 0xFF}, false},
+		{"#FFF", color.RGBA{}, true},
+		{"#FF00FF00", color.RGBA{}, true},
+		{"#GG0000", color.RGBA{}, true},
+		{"", color.RGBA{}, true},
+	}
+	for _, tt := range tests {
+		c, err := ParseColor(tt.hex)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseColor(%q) error = %v", tt.hex, err)
+			continue
+		}
+		if err == nil && c != tt.want {
+			t.Errorf("ParseColor(%q) = %v, want %v", tt.hex, c, tt.want)
+		}
+	}
+}
+
+func TestWithColors(t *testing.T) {
+	classic, err := Lookup("classic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := classic.WithColors([]string{"#102030", "#405060"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "custom" || p.Color(0) != rgb(0x102030) || p.Color(1) != rgb(0x405060) {
+		t.Errorf("WithColors = %+v", p)
+	}
+	if p.Color(2) != classic.Color(2) || p.Color(3) != classic.Color(3) {
+		t.Errorf("WithColors changed the colours it wasn't given")
+	}
+	if classic.Color(0) != rgb(0x000000) {
+		t.Errorf("WithColors changed the preset")
+	}
+
+	if _, err := classic.WithColors([]string{"#000000", "nope"}); err == nil {
+		t.Errorf("WithColors took a bad colour")
+	}
+	if _, err := classic.WithColors(make([]string, 5)); err == nil {
+		t.Errorf("WithColors took 5 colours")
+	}
+}
+
+func TestLookupNext(t *testing.T) {
+	for i, name := range Names() {
+		p, err := Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if next := Next(p); next.Name != Presets[(i+1)%len(Presets)].Name {
+			t.Errorf("Next(%s) = %s", name, next.Name)
+		}
+	}
+	if _, err := Lookup("nope"); err == nil {
+		t.Errorf("found a palette called nope")
+	}
+	if next := Next(Palette{Name: "custom"}); next.Name != Presets[0].Name {
+		t.Errorf("Next(custom) = %s, want %s", next.Name, Presets[0].Name)
+	}
+}
+
+func TestColorMasks(t *testing.T) {
+	p := Presets[0]
+	if p.Color(5) != p.Color(1) {
+		t.Errorf("Color(5) = %v, want colour 1", p.Color(5))
+	}
+}
@@ -0,0 +1,159 @@
+package profiler
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/rominfo"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Rows shown in each of the report's top lists
+const TOP = 20
+
+// Writes a human readable report of a profile
+// Cycles are in the units of the timing mode that was profiled
+func WriteReport(w io.Writer, p *chip8.Profiler, timing chip8.TimingMode) {
+	var instructions, cycles uint64
+	for addr := range p.Exec {
+		instructions += p.Exec[addr]
+		cycles += p.ExecCycles[addr]
+	}
+	fmt.Fprintf(w, "chippy profile (%s timing)\n", timing)
+	fmt.Fprintf(w, "Instructions: %d\n", instructions)
+	fmt.Fprintf(w, "Cycles:       %d\n", cycles)
+	if instructions == 0 {
+		return
+	}
+
+	// Hottest addresses, by cycles
+	addrs := []int{}
+	for addr := range p.Exec {
+		if p.Exec[addr] > 0 {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.SliceStable(addrs, func(a, b int) bool {
+		return p.ExecCycles[addrs[a]] > p.ExecCycles[addrs[b]]
+	})
+	fmt.Fprintf(w, "\nHot addresses:\n")
+	fmt.Fprintf(w, "  %-5s %-6s %-18s %12s %12s %7s\n", "ADDR", "OP", "", "EXECUTED", "CYCLES", "%")
+	for _, addr := range top(addrs) {
+		fmt.Fprintf(w, "  0x%03X %04X   %-18s %12d %12d %6.2f%%\n",
+			addr, p.Code[addr], chip8.Disassemble(p.Code[addr]),
+			p.Exec[addr], p.ExecCycles[addr], percent(p.ExecCycles[addr], cycles))
+	}
+
+	// Opcode classes, by times executed
+	classes := map[string]uint64{}
+	for oc, n := range p.Opcodes {
+		class, _ := rominfo.Classify(oc)
+		if class == "" {
+			class = "????"
+		}
+		classes[class] += n
+	}
+	names := make([]string, 0, len(classes))
+	for class := range classes {
+		names = append(names, class)
+	}
+	sort.Slice(names, func(a, b int) bool {
+		if classes[names[a]] != classes[names[b]] {
+			return classes[names[a]] > classes[names[b]]
+		}
+		return names[a] < names[b]
+	})
+	fmt.Fprintf(w, "\nOpcode classes:\n")
+	for _, class := range names {
+		fmt.Fprintf(w, "  %-5s %12d %6.2f%%\n", class, classes[class], percent(classes[class], instructions))
+	}
+
+	// Subroutines, by inclusive cycles
+	if len(p.Subroutines) > 0 {
+		subs := make([]uint16, 0, len(p.Subroutines))
+		for addr := range p.Subroutines {
+			subs = append(subs, addr)
+		}
+		sort.Slice(subs, func(a, b int) bool {
+			if p.Subroutines[subs[a]].Cycles != p.Subroutines[subs[b]].Cycles {
+				return p.Subroutines[subs[a]].Cycles > p.Subroutines[subs[b]].Cycles
+			}
+			return subs[a] < subs[b]
+		})
+		fmt.Fprintf(w, "\nSubroutines (cycles include callees):\n")
+		fmt.Fprintf(w, "  %-5s %10s %12s %10s %7s\n", "ADDR", "CALLS", "CYCLES", "AVERAGE", "%")
+		for _, addr := range subs {
+			sub := p.Subroutines[addr]
+			fmt.Fprintf(w, "  0x%03X %10d %12d %10d %6.2f%%\n",
+				addr, sub.Calls, sub.Cycles, sub.Cycles/sub.Calls, percent(sub.Cycles, cycles))
+		}
+	}
+
+	writeHeat(w, "Memory reads", &p.Reads)
+	writeHeat(w, "Memory writes", &p.Writes)
+}
+
+// Writes the busiest runs of consecutive addresses in a heatmap
+func writeHeat(w io.Writer, title string, heat *[chip8.MEMORY_SIZE]uint64) {
+	type run struct {
+		start, end int
+		total      uint64
+	}
+	runs := []run{}
+	for addr := 0; addr < len(heat); addr++ {
+		if heat[addr] == 0 {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1].end == addr-1 {
+			runs[n-1].end = addr
+			runs[n-1].total += heat[addr]
+		} else {
+			runs = append(runs, run{start: addr, end: addr, total: heat[addr]})
+		}
+	}
+	if len(runs) == 0 {
+		return
+	}
+	sort.SliceStable(runs, func(a, b int) bool {
+		return runs[a].total > runs[b].total
+	})
+	if len(runs) > TOP {
+		runs = runs[:TOP]
+	}
+
+	fmt.Fprintf(w, "\n%s:\n", title)
+	for _, r := range runs {
+		fmt.Fprintf(w, "  0x%03X-0x%03X %12d\n", r.start, r.end, r.total)
+	}
+}
+
+// Returns the first TOP entries
+func top(addrs []int) []int {
+	if len(addrs) > TOP {
+		return addrs[:TOP]
+	}
+	return addrs
+}
+
+// Returns n as a percentage of total
+func percent(n, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
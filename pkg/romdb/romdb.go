@@ -0,0 +1,435 @@
+package romdb
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"chippy/pkg/rominfo"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Seed database, in the community chip-8-database format
+// https://github.com/chip-8/chip-8-database
+//
+//go:embed db/programs.json db/sha1-hashes.json
+var seed embed.FS
+
+// Name of the user overrides file in the config dir
+const overridesFile = "overrides.json"
+
+// Community platform IDs, mapped to chippy quirk profiles
+var platformProfiles = map[string]string{
+	"originalChip8": "vip",
+	"hybridVIP":     "vip",
+	"modernChip8":   "modern",
+	"chip48":        "chip48",
+	"superchip1":    "schip",
+	"superchip":     "schip",
+	"xochip":        "xochip",
+	"chip8x":        "vip",
+	"megachip8":     "schip",
+}
+
+// Community platform IDs that need more than quirks, mapped to chippy platforms
+var platformIDs = map[string]string{
+	"chip8x":    "chip8x",
+	"megachip8": "megachip",
+}
+
+// Community key actions, mapped to SDL key names
+var actionKeys = map[string]string{
+	"up":    "Up",
+	"down":  "Down",
+	"left":  "Left",
+	"right": "Right",
+	"a":     "Space",
+	"b":     "Return",
+}
+
+// Settings are everything chippy knows about running a ROM
+// Zero values mean "no opinion", so settings can be layered
+type Settings struct {
+	// Program title, for display only
+	Title string `json:"title,omitempty"`
+
+	// chippy quirk profile, see chip8.Profiles
+	Profile string `json:"profile,omitempty"`
+
+	// Platform, see chip8.ParsePlatform
+	Platform string `json:"platform,omitempty"`
+
+	// Individual quirks on top of the profile, using the community names
+	// (vblank, wrap, shift, memoryLeaveIUnchanged, memoryIncrementByX, jump,
+	// logic) and machineCode
+	Quirks map[string]bool `json:"quirks,omitempty"`
+
+	// Instructions per 60Hz frame
+	TickRate int `json:"tickrate,omitempty"`
+
+	// Timing mode, see chip8.ParseTimingMode
+	Timing string `json:"timing,omitempty"`
+
+	// Built-in palette name, see palette.Presets
+	Palette string `json:"palette,omitempty"`
+
+	// Pixel colours as HTML hex strings, background first, up to four
+	// These replace the first colours of the palette
+	Colors []string `json:"colors,omitempty"`
+
+	// Key bindings, CHIP-8 key (hex digit) to SDL key name
+	Keys map[string]string `json:"keys,omitempty"`
+}
+
+// Returns the settings with every non-zero field of o layered on top
+func (s Settings) Merge(o Settings) Settings {
+	if o.Title != "" {
+		s.Title = o.Title
+	}
+	if o.Profile != "" {
+		s.Profile = o.Profile
+	}
+	if o.Platform != "" {
+		s.Platform = o.Platform
+	}
+	if o.TickRate != 0 {
+		s.TickRate = o.TickRate
+	}
+	if o.Timing != "" {
+		s.Timing = o.Timing
+	}
+	if o.Palette != "" {
+		s.Palette = o.Palette
+	}
+	if len(o.Colors) > 0 {
+		s.Colors = o.Colors
+	}
+	s.Quirks = mergeMap(s.Quirks, o.Quirks)
+	s.Keys = mergeKeys(s.Keys, o.Keys)
+	return s
+}
+
+// Returns the settings the static analysis recommends, for ROMs the
+// database doesn't know
+func Recommended(r rominfo.Report) Settings {
+	s := Settings{Profile: r.Profile}
+	switch r.Platform {
+	case rominfo.PLATFORM_HIRES:
+		s.Platform = chip8.PLATFORM_HIRES.String()
+	case rominfo.PLATFORM_MEGACHIP:
+		s.Platform = chip8.PLATFORM_MEGACHIP.String()
+	}
+	return s
+}
+
+// Returns the quirks for these settings, starting from the profile
+func (s Settings) ChipQuirks() (chip8.Quirks, error) {
+	q, _, err := s.chipQuirks()
+	return q, err
+}
+
+// Returns the quirks for these settings, starting from the profile, and the
+// names of the quirks chippy doesn't know, sorted
+func (s Settings) chipQuirks() (chip8.Quirks, []string, error) {
+	profile := s.Profile
+	if profile == "" {
+		profile = "modern"
+	}
+	q, err := chip8.ProfileQuirks(profile)
+	if err != nil {
+		return q, nil, err
+	}
+	var ignored []string
+	for name, on := range s.Quirks {
+		switch name {
+		case "vblank":
+			q.DisplayWait = on
+		case "wrap":
+			q.WrapSprites = on
+		case "machineCode":
+			q.MachineCode = on
+		case "shift":
+			// The community quirk is shifting VX in place
+			q.ShiftVY = !on
+		case "memoryLeaveIUnchanged":
+			q.MemoryIncrement = !on
+		case "memoryIncrementByX":
+			q.MemoryIncrementByX = on
+		case "jump":
+			q.JumpVX = on
+		case "logic":
+			q.ResetVF = on
+		default:
+			ignored = append(ignored, name)
+		}
+	}
+	sort.Strings(ignored)
+	return q, ignored, nil
+}
+
+// Applies the settings that change how the ROM runs (platform, timing,
+// tickrate and quirks) to a CHIP-8. Palette, colours and keys are up to the
+// frontend. Changing platform resets the CHIP-8.
+func (s Settings) Apply(c *chip8.Chip8) error {
+	if s.Platform != "" {
+		platform, err := chip8.ParsePlatform(s.Platform)
+		if err != nil {
+			return err
+		}
+		if platform != c.Platform() {
+			c.SetPlatform(platform)
+		}
+	}
+
+	timing := s.Timing
+	if timing == "" {
+		timing = chip8.TIMING_FIXED.String()
+	}
+	mode, err := chip8.ParseTimingMode(timing)
+	if err != nil {
+		return err
+	}
+	c.SetTiming(mode)
+	if s.TickRate > 0 {
+		c.SetClockSpeed(uint32(s.TickRate * chip8.FRAME_RATE))
+	}
+
+	quirks, ignored, err := s.chipQuirks()
+	if err != nil {
+		return err
+	}
+	for _, name := range ignored {
+		c.Logger().Warn("Ignoring unknown quirk", "quirk", name, "on", s.Quirks[name])
+	}
+	c.SetQuirks(quirks)
+	return nil
+}
+
+// DB is the ROM settings database, keyed by the SHA-1 of the ROM
+type DB struct {
+	// Programs, in the community format
+	programs []program
+
+	// ROM SHA-1 to index in programs
+	hashes map[string]int
+
+	// User overrides, ROM SHA-1 to settings
+	overrides map[string]Settings
+
+	// Directory overrides are saved to, empty if there is none
+	dir string
+}
+
+// A program in the community programs.json
+type program struct {
+	Title       string             `json:"title"`
+	Description string             `json:"description"`
+	ROMs        map[string]romInfo `json:"roms"`
+}
+
+// A ROM of a program in the community programs.json
+type romInfo struct {
+	File            string                     `json:"file"`
+	Platforms       []string                   `json:"platforms"`
+	QuirkyPlatforms map[string]map[string]bool `json:"quirkyPlatforms"`
+	TickRate        int                        `json:"tickrate"`
+	Keys            map[string]int             `json:"keys"`
+	Colors          struct {
+		Pixels []string `json:"pixels"`
+	} `json:"colors"`
+}
+
+// Opens the ROM settings database
+// The embedded seed is used unless the config dir has its own copy of the
+// community database (programs.json and sha1-hashes.json). User overrides
+// are read from, and saved to, overrides.json in the config dir.
+func Open() (*DB, error) {
+	db := &DB{overrides: map[string]Settings{}}
+
+	// Find our config dir, if we can't we still have the seed
+	if dir, err := os.UserConfigDir(); err == nil {
+		db.dir = filepath.Join(dir, "chippy")
+	}
+
+	// Prefer the full community database, if the user has one
+	loaded := false
+	if db.dir != "" {
+		programs, errP := os.ReadFile(filepath.Join(db.dir, "programs.json"))
+		hashes, errH := os.ReadFile(filepath.Join(db.dir, "sha1-hashes.json"))
+		if errP == nil && errH == nil {
+			if err := db.load(programs, hashes); err != nil {
+				return nil, err
+			}
+			loaded = true
+		}
+	}
+	if !loaded {
+		if err := db.loadSeed(); err != nil {
+			return nil, err
+		}
+	}
+
+	// Load user overrides
+	if db.dir != "" {
+		data, err := os.ReadFile(filepath.Join(db.dir, overridesFile))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &db.overrides); err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %v", overridesFile, err)
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// Opens the seed database on its own, without the user's database or
+// overrides, for runs that have to be the same on every machine
+// Overrides can't be saved to it.
+func OpenSeed() (*DB, error) {
+	db := &DB{overrides: map[string]Settings{}}
+	if err := db.loadSeed(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// Loads the embedded seed database
+func (db *DB) loadSeed() error {
+	programs, _ := seed.ReadFile("db/programs.json")
+	hashes, _ := seed.ReadFile("db/sha1-hashes.json")
+	return db.load(programs, hashes)
+}
+
+// Parses the community programs.json and sha1-hashes.json
+func (db *DB) load(programs []byte, hashes []byte) error {
+	if err := json.Unmarshal(programs, &db.programs); err != nil {
+		return fmt.Errorf("failed to parse programs.json: %v", err)
+	}
+	if err := json.Unmarshal(hashes, &db.hashes); err != nil {
+		return fmt.Errorf("failed to parse sha1-hashes.json: %v", err)
+	}
+	return nil
+}
+
+// Looks up the settings for the ROM with the given SHA-1
+// User overrides are layered on top of the database entry
+// Returns false if neither knows about the ROM
+func (db *DB) Lookup(hash string) (Settings, bool) {
+	hash = strings.ToLower(hash)
+	s, found := db.entry(hash)
+	if o, ok := db.overrides[hash]; ok {
+		s = s.Merge(o)
+		found = true
+	}
+	return s, found
+}
+
+// Returns the settings from the database entry for the given SHA-1
+func (db *DB) entry(hash string) (Settings, bool) {
+	idx, ok := db.hashes[hash]
+	if !ok || idx < 0 || idx >= len(db.programs) {
+		return Settings{}, false
+	}
+	p := db.programs[idx]
+	r := p.ROMs[hash]
+
+	s := Settings{
+		Title:    p.Title,
+		TickRate: r.TickRate,
+		Colors:   r.Colors.Pixels,
+	}
+
+	// The first platform we know is the one the ROM was written for
+	for _, platform := range r.Platforms {
+		if profile, ok := platformProfiles[platform]; ok {
+			s.Profile = profile
+			s.Platform = platformIDs[platform]
+			s.Quirks = r.QuirkyPlatforms[platform]
+			break
+		}
+	}
+
+	// Bind the game's actions to arrow keys and friends
+	for action, key := range r.Keys {
+		name, ok := actionKeys[action]
+		if !ok || key < 0x0 || key > 0xF {
+			continue
+		}
+		if s.Keys == nil {
+			s.Keys = map[string]string{}
+		}
+		s.Keys[fmt.Sprintf("%X", key)] = name
+	}
+
+	return s, true
+}
+
+// Saves user overrides for the ROM with the given SHA-1, layered on top of
+// any the user already has
+func (db *DB) SaveOverride(hash string, s Settings) error {
+	if db.dir == "" {
+		return fmt.Errorf("no config dir to save settings to :(")
+	}
+	hash = strings.ToLower(hash)
+	db.overrides[hash] = db.overrides[hash].Merge(s)
+
+	data, err := json.MarshalIndent(db.overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(db.dir, 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(db.dir, overridesFile), data, 0644)
+}
+
+// Returns a with every entry of b layered on top
+func mergeMap(a, b map[string]bool) map[string]bool {
+	if len(b) == 0 {
+		return a
+	}
+	out := make(map[string]bool, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
+
+// Returns a with every entry of b layered on top
+func mergeKeys(a, b map[string]string) map[string]string {
+	if len(b) == 0 {
+		return a
+	}
+	out := make(map[string]string, len(a)+len(b))
+	for k, v := range a {
+		out[k] = v
+	}
+	for k, v := range b {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,209 @@
+package romdb
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/chip8"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// SHA-1s of bundled ROMs in the seed database
+const (
+	ibmLogo = "1ba58656810b67fd131eb9af3e3987863bf26c90"
+	bcTest  = "9df1689015a0d1d95144f141903296f9f1c35fc5"
+)
+
+// Logger that remembers every warning
+type warnings []string
+
+func (w *warnings) Debug(msg string, args ...interface{}) {}
+func (w *warnings) Info(msg string, args ...interface{})  {}
+func (w *warnings) Warn(msg string, args ...interface{}) {
+	*w = append(*w, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+func (w *warnings) Error(msg string, args ...interface{}) {}
+
+// Opens the seed database, with a config dir of its own so the user's
+// database and overrides stay out of it
+func openSeed(t *testing.T) *DB {
+	t.Helper()
+	old, had := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Cleanup(func() {
+		if had {
+			os.Setenv("XDG_CONFIG_HOME", old)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	})
+
+	db, err := Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return db
+}
+
+func TestLookupApply(t *testing.T) {
+	db := openSeed(t)
+
+	tests := []struct {
+		hash    string
+		title   string
+		profile string
+		quirks  chip8.Quirks
+	}{
+		// First known platform is originalChip8
+		{ibmLogo, "IBM Logo", "vip", chip8.Profiles["vip"]},
+		{bcTest, "BC Test", "modern", chip8.Profiles["modern"]},
+	}
+	for _, tt := range tests {
+		s, ok := db.Lookup(tt.hash)
+		if !ok {
+			t.Fatalf("Lookup(%s): not found", tt.hash)
+		}
+		if s.Title != tt.title || s.Profile != tt.profile {
+			t.Errorf("Lookup(%s) = %q/%q, want %q/%q", tt.hash, s.Title, s.Profile, tt.title, tt.profile)
+		}
+
+		c := chip8.Init()
+		if err := s.Apply(&c); err != nil {
+			t.Fatalf("Apply(%s): %v", tt.hash, err)
+		}
+		if c.Quirks() != tt.quirks {
+			t.Errorf("Apply(%s) quirks = %+v, want %+v", tt.hash, c.Quirks(), tt.quirks)
+		}
+	}
+
+	if _, ok := db.Lookup("0000000000000000000000000000000000000000"); ok {
+		t.Errorf("Lookup of an unknown ROM found something")
+	}
+}
+
+func TestOpenSeed(t *testing.T) {
+	// Overrides in the config dir don't reach the seed
+	user := openSeed(t)
+	if err := user.SaveOverride(ibmLogo, Settings{TickRate: 99}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := OpenSeed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := db.Lookup(ibmLogo)
+	if !ok || s.TickRate == 99 {
+		t.Errorf("Lookup = %+v, %v, want the seed entry", s, ok)
+	}
+	if err := db.SaveOverride(ibmLogo, Settings{TickRate: 99}); err == nil {
+		t.Errorf("saved an override to the seed")
+	}
+}
+
+func TestLookupUppercase(t *testing.T) {
+	db := openSeed(t)
+	if _, ok := db.Lookup("1BA58656810B67FD131EB9AF3E3987863BF26C90"); !ok {
+		t.Errorf("Lookup is case sensitive")
+	}
+}
+
+func TestCommunityQuirks(t *testing.T) {
+	tests := []struct {
+		quirks map[string]bool
+		want   chip8.Quirks
+	}{
+		// Everything the other way from the vip profile
+		{
+			map[string]bool{
+				"vblank":                false,
+				"wrap":                  true,
+				"machineCode":           false,
+				"shift":                 true,
+				"memoryLeaveIUnchanged": true,
+				"jump":                  true,
+				"logic":                 false,
+			},
+			chip8.Quirks{WrapSprites: true, JumpVX: true},
+		},
+		// CHIP-48's I
+		{
+			map[string]bool{"memoryIncrementByX": true},
+			func() chip8.Quirks {
+				q := chip8.Profiles["vip"]
+				q.MemoryIncrementByX = true
+				return q
+			}(),
+		},
+	}
+	for _, tt := range tests {
+		s := Settings{Profile: "vip", Quirks: tt.quirks}
+		q, ignored, err := s.chipQuirks()
+		if err != nil {
+			t.Fatalf("chipQuirks: %v", err)
+		}
+		if q != tt.want || len(ignored) != 0 {
+			t.Errorf("chipQuirks(%v) = %+v, ignoring %v, want %+v", tt.quirks, q, ignored, tt.want)
+		}
+	}
+}
+
+func TestUnknownQuirks(t *testing.T) {
+	s := Settings{Quirks: map[string]bool{"wrap": true, "someday": true, "zzz": false}}
+
+	q, ignored, err := s.chipQuirks()
+	if err != nil {
+		t.Fatalf("chipQuirks: %v", err)
+	}
+	want := chip8.Quirks{WrapSprites: true}
+	if q != want {
+		t.Errorf("quirks = %+v, want %+v", q, want)
+	}
+	if names := []string{"someday", "zzz"}; !reflect.DeepEqual(ignored, names) {
+		t.Errorf("ignored = %v, want %v", ignored, names)
+	}
+
+	var log warnings
+	c := chip8.Init()
+	c.SetLogger(&log)
+	if err := s.Apply(&c); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if c.Quirks() != want {
+		t.Errorf("Apply quirks = %+v, want %+v", c.Quirks(), want)
+	}
+	if len(log) != len(ignored) {
+		t.Errorf("Apply logged %v, want a warning per unknown quirk", log)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Settings{Profile: "vip", TickRate: 10, Quirks: map[string]bool{"vblank": true}}
+	over := Settings{TickRate: 30, Quirks: map[string]bool{"wrap": true}}
+
+	got := base.Merge(over)
+	if got.Profile != "vip" || got.TickRate != 30 {
+		t.Errorf("Merge = %+v", got)
+	}
+	if !reflect.DeepEqual(got.Quirks, map[string]bool{"vblank": true, "wrap": true}) {
+		t.Errorf("Merge quirks = %v", got.Quirks)
+	}
+	if len(base.Quirks) != 1 {
+		t.Errorf("Merge changed the base quirks: %v", base.Quirks)
+	}
+}
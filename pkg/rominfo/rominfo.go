@@ -0,0 +1,422 @@
+package rominfo
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Where CHIP-8 programs are loaded, and start running
+const ENTRY_POINT = 0x200
+
+// Largest ROM that fits in 4K of memory after the interpreter
+const MAX_SIZE = 4096 - ENTRY_POINT
+
+// HIRES CHIP-8 ROMs start with this jump, over 1802 code that patches the
+// interpreter, and their CHIP-8 code starts at HIRES_START
+const HIRES_ENTRY = 0x1260
+const HIRES_START = 0x2C0
+
+// Platform is a CHIP-8 variant a ROM was written for
+type Platform string
+
+const (
+	PLATFORM_CHIP8    Platform = "chip8"
+	PLATFORM_HIRES    Platform = "hires"
+	PLATFORM_SCHIP    Platform = "schip"
+	PLATFORM_XOCHIP   Platform = "xochip"
+	PLATFORM_MEGACHIP Platform = "megachip"
+)
+
+// Report is what we learned about a ROM without running it
+type Report struct {
+	// Size of the ROM, in bytes
+	Size int
+
+	// SHA-1 of the ROM, hex encoded
+	SHA1 string
+
+	// First instruction, at the entry point
+	Entry uint16
+
+	// Bytes reached by following the code from the entry point
+	CodeBytes int
+
+	// Instructions found in reachable code, by class (like 8XY6)
+	Opcodes map[string]int
+
+	// Best guess at the platform the ROM was written for
+	Platform Platform
+
+	// Opcodes that gave the platform away
+	Evidence []string
+
+	// chippy quirk profile to run the ROM with
+	Profile string
+
+	// Things that look wrong
+	Warnings []string
+
+	// Quirk advice, based on the instructions used
+	Notes []string
+}
+
+// Returns true if the ROM looks like something we can run
+func (r Report) OK() bool {
+	return len(r.Warnings) == 0
+}
+
+// Analyses a ROM, statically
+func Analyze(rom []byte) Report {
+	sum := sha1.Sum(rom)
+	r := Report{
+		Size:     len(rom),
+		SHA1:     hex.EncodeToString(sum[:]),
+		Opcodes:  map[string]int{},
+		Platform: PLATFORM_CHIP8,
+	}
+
+	// Size sanity
+	switch {
+	case len(rom) == 0:
+		r.Warnings = append(r.Warnings, "ROM is empty")
+		return r
+	case len(rom) > MAX_SIZE:
+		r.Warnings = append(r.Warnings, fmt.Sprintf("ROM is %d bytes, only %d fit in 4K (SCHIP/XO-CHIP/MegaChip?)", len(rom), MAX_SIZE))
+	}
+	if len(rom)%2 != 0 {
+		// Common for ROMs with sprite data at the end, so only worth a note
+		r.Notes = append(r.Notes, "ROM has an odd length, data after the code is not word aligned")
+	}
+
+	// Memory as the interpreter sees it
+	mem := make([]byte, ENTRY_POINT+len(rom)+2)
+	copy(mem[ENTRY_POINT:], rom)
+	r.Entry = uint16(mem[ENTRY_POINT])<<8 | uint16(mem[ENTRY_POINT+1])
+	end := ENTRY_POINT + len(rom)
+
+	// Entry point sanity
+	if r.Entry == 0x0000 {
+		r.Warnings = append(r.Warnings, "entry point is 0000, this does not look like a CHIP-8 program")
+	} else if r.Entry&0xF000 == 0x1000 || r.Entry&0xF000 == 0x2000 {
+		if target := int(r.Entry & 0x0FFF); target < ENTRY_POINT || target >= end {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("entry point jumps to 0x%03X, outside the ROM", target))
+		}
+	}
+
+	// HIRES CHIP-8 code starts after the interpreter patch
+	start := ENTRY_POINT
+	if r.Entry == HIRES_ENTRY && end > HIRES_START {
+		r.Platform = PLATFORM_HIRES
+		r.Evidence = []string{"1260"}
+		start = HIRES_START
+	}
+
+	// Follow the code from the entry point
+	t := tracer{mem: mem, end: end, seen: map[int]bool{}, report: &r}
+	t.trace(start)
+	r.CodeBytes = len(t.seen) * 2
+
+	r.guessPlatform(t.platformOps)
+	r.advise()
+	sort.Strings(r.Evidence)
+
+	return r
+}
+
+// Follows every path through the code, the way the interpreter would
+type tracer struct {
+	mem    []byte
+	end    int
+	seen   map[int]bool
+	report *Report
+
+	// Platform specific opcodes seen, by platform
+	platformOps map[Platform][]string
+}
+
+// Traces code starting at the given address, queueing up branches
+func (t *tracer) trace(start int) {
+	queue := []int{start}
+	for len(queue) > 0 {
+		pc := queue[0]
+		queue = queue[1:]
+
+		for pc >= ENTRY_POINT && pc < t.end && !t.seen[pc] {
+			t.seen[pc] = true
+			oc := uint16(t.mem[pc])<<8 | uint16(t.mem[pc+1])
+			class, platform := Classify(oc)
+			if class == "" {
+				// Not an instruction, we ran into data
+				break
+			}
+			t.report.Opcodes[class]++
+			if platform != PLATFORM_CHIP8 {
+				if t.platformOps == nil {
+					t.platformOps = map[Platform][]string{}
+				}
+				t.platformOps[platform] = append(t.platformOps[platform], fmt.Sprintf("%04X@%03X", oc, pc))
+			}
+
+			next := pc + 2
+			stop := false
+			switch {
+			case oc == 0x00EE, oc == 0x00FD, class == "BNNN":
+				// Return, exit, or a computed jump we can't follow
+				stop = true
+			case oc&0xF000 == 0x1000:
+				queue = append(queue, int(oc&0x0FFF))
+				stop = true
+			case oc&0xF000 == 0x2000:
+				queue = append(queue, int(oc&0x0FFF))
+			case oc == 0xF000:
+				// XO-CHIP long I load, the address follows the opcode
+				next = pc + 4
+			case isSkip(oc):
+				queue = append(queue, pc+4)
+			}
+			if stop {
+				break
+			}
+			pc = next
+		}
+	}
+}
+
+// Returns true for instructions that may skip the next one
+func isSkip(oc uint16) bool {
+	switch oc & 0xF000 {
+	case 0x3000, 0x4000, 0x5000, 0x9000, 0xE000:
+		return true
+	}
+	return false
+}
+
+// Classifies an opcode, returning its class (like 8XY6) and the platform that
+// introduced it. Returns an empty class for words that are not instructions.
+func Classify(oc uint16) (string, Platform) {
+	n := oc & 0x000F
+	nn := oc & 0x00FF
+	switch oc & 0xF000 {
+	case 0x0000:
+		switch {
+		case oc == 0x00E0:
+			return "00E0", PLATFORM_CHIP8
+		case oc == 0x00EE:
+			return "00EE", PLATFORM_CHIP8
+		case oc&0xFFF0 == 0x00C0:
+			return "00CN", PLATFORM_SCHIP
+		case oc&0xFFF0 == 0x00D0:
+			return "00DN", PLATFORM_XOCHIP
+		case oc == 0x00FB, oc == 0x00FC, oc == 0x00FD, oc == 0x00FE, oc == 0x00FF:
+			return fmt.Sprintf("%04X", oc), PLATFORM_SCHIP
+		case oc == 0x0230:
+			// HIRES CHIP-8 clears its 64x64 display with this
+			return "0230", PLATFORM_HIRES
+		case oc == 0x0010, oc == 0x0011:
+			// MegaChip mode off/on, the rest of its 0NNN opcodes look
+			// just like machine code calls so they don't count
+			return fmt.Sprintf("%04X", oc), PLATFORM_MEGACHIP
+		default:
+			// Machine code call, only the COSMAC VIP can run these
+			return "0NNN", PLATFORM_CHIP8
+		}
+	case 0x1000:
+		return "1NNN", PLATFORM_CHIP8
+	case 0x2000:
+		return "2NNN", PLATFORM_CHIP8
+	case 0x3000:
+		return "3XNN", PLATFORM_CHIP8
+	case 0x4000:
+		return "4XNN", PLATFORM_CHIP8
+	case 0x5000:
+		switch n {
+		case 0x0:
+			return "5XY0", PLATFORM_CHIP8
+		case 0x2:
+			return "5XY2", PLATFORM_XOCHIP
+		case 0x3:
+			return "5XY3", PLATFORM_XOCHIP
+		}
+	case 0x6000:
+		return "6XNN", PLATFORM_CHIP8
+	case 0x7000:
+		return "7XNN", PLATFORM_CHIP8
+	case 0x8000:
+		switch n {
+		case 0x0, 0x1, 0x2, 0x3, 0x4, 0x5, 0x6, 0x7, 0xE:
+			return fmt.Sprintf("8XY%X", n), PLATFORM_CHIP8
+		}
+	case 0x9000:
+		if n == 0 {
+			return "9XY0", PLATFORM_CHIP8
+		}
+	case 0xA000:
+		return "ANNN", PLATFORM_CHIP8
+	case 0xB000:
+		return "BNNN", PLATFORM_CHIP8
+	case 0xC000:
+		return "CXNN", PLATFORM_CHIP8
+	case 0xD000:
+		if n == 0 {
+			// 16x16 sprites on SUPER-CHIP, nothing on CHIP-8
+			return "DXY0", PLATFORM_SCHIP
+		}
+		return "DXYN", PLATFORM_CHIP8
+	case 0xE000:
+		switch nn {
+		case 0x9E:
+			return "EX9E", PLATFORM_CHIP8
+		case 0xA1:
+			return "EXA1", PLATFORM_CHIP8
+		}
+	case 0xF000:
+		switch {
+		case oc == 0xF000:
+			return "F000", PLATFORM_XOCHIP
+		case oc == 0xF002:
+			return "F002", PLATFORM_XOCHIP
+		case nn == 0x01:
+			return "FN01", PLATFORM_XOCHIP
+		case nn == 0x3A:
+			return "FX3A", PLATFORM_XOCHIP
+		case nn == 0x30, nn == 0x75, nn == 0x85:
+			return fmt.Sprintf("FX%02X", nn), PLATFORM_SCHIP
+		case nn == 0x07, nn == 0x0A, nn == 0x15, nn == 0x18, nn == 0x1E,
+			nn == 0x29, nn == 0x33, nn == 0x55, nn == 0x65:
+			return fmt.Sprintf("FX%02X", nn), PLATFORM_CHIP8
+		}
+	}
+	return "", ""
+}
+
+// Returns every opcode class Classify knows, sorted
+func Classes() []string {
+	seen := map[string]bool{}
+	var classes []string
+	for oc := 0; oc <= 0xFFFF; oc++ {
+		if class, _ := Classify(uint16(oc)); class != "" && !seen[class] {
+			seen[class] = true
+			classes = append(classes, class)
+		}
+	}
+	sort.Strings(classes)
+	return classes
+}
+
+// Picks the most capable platform whose opcodes showed up
+// XO-CHIP and MegaChip are both supersets of SUPER-CHIP
+func (r *Report) guessPlatform(ops map[Platform][]string) {
+	// HIRES CHIP-8 gives itself away before we look at any opcodes, and only
+	// ever ran on the VIP
+	if r.Platform == PLATFORM_HIRES {
+		r.Profile = "vip"
+		return
+	}
+
+	for _, p := range []Platform{PLATFORM_MEGACHIP, PLATFORM_XOCHIP, PLATFORM_SCHIP} {
+		if len(ops[p]) > 0 {
+			r.Platform = p
+			r.Evidence = ops[p]
+			break
+		}
+	}
+	switch r.Platform {
+	case PLATFORM_SCHIP:
+		r.Profile = "schip"
+	case PLATFORM_XOCHIP:
+		r.Profile = "xochip"
+	case PLATFORM_MEGACHIP:
+		// MegaChip builds on SUPER-CHIP
+		r.Profile = "schip"
+	default:
+		// Machine code calls only make sense on the original interpreter,
+		// running them is still up to -machine-code
+		if r.Opcodes["0NNN"] > 0 {
+			r.Profile = "vip"
+		} else {
+			r.Profile = "modern"
+		}
+	}
+}
+
+// Adds quirk advice, for instructions that behave differently between
+// interpreters
+func (r *Report) advise() {
+	if r.Opcodes["0NNN"] > 0 {
+		r.Notes = append(r.Notes, "calls COSMAC VIP machine code (0NNN), try -machine-code to run it on an 1802 core")
+	}
+	if r.Opcodes["8XY6"]+r.Opcodes["8XYE"] > 0 {
+		r.Notes = append(r.Notes, "shifts (8XY6/8XYE), CHIP-8 shifts VY into VX while later interpreters shift VX in place")
+	}
+	if r.Opcodes["FX55"]+r.Opcodes["FX65"] > 0 {
+		r.Notes = append(r.Notes, "bulk loads/stores (FX55/FX65), CHIP-8 increments I while later interpreters leave it")
+	}
+	if r.Opcodes["BNNN"] > 0 {
+		r.Notes = append(r.Notes, "jumps with offset (BNNN), SUPER-CHIP reads the offset from VX instead of V0")
+	}
+	if r.Opcodes["8XY1"]+r.Opcodes["8XY2"]+r.Opcodes["8XY3"] > 0 && r.Platform == PLATFORM_CHIP8 {
+		r.Notes = append(r.Notes, "logic ops (8XY1/8XY2/8XY3), CHIP-8 resets VF after these")
+	}
+	if r.Opcodes["DXYN"] > 0 && r.Profile == "vip" {
+		r.Notes = append(r.Notes, "draws sprites, try -display-wait if it runs too fast")
+	}
+}
+
+// Prints a human readable report
+func (r Report) Print(w io.Writer) {
+	fmt.Fprintf(w, "Size:      %d bytes\n", r.Size)
+	fmt.Fprintf(w, "SHA-1:     %s\n", r.SHA1)
+	fmt.Fprintf(w, "Entry:     %04X\n", r.Entry)
+	fmt.Fprintf(w, "Code:      %d bytes reachable from 0x%03X\n", r.CodeBytes, ENTRY_POINT)
+	fmt.Fprintf(w, "Platform:  %s\n", r.Platform)
+	if len(r.Evidence) > 0 {
+		evidence := r.Evidence
+		if len(evidence) > 8 {
+			evidence = append(evidence[:8:8], fmt.Sprintf("and %d more", len(r.Evidence)-8))
+		}
+		fmt.Fprintf(w, "Evidence:  %v\n", evidence)
+	}
+	fmt.Fprintf(w, "Profile:   %s\n", r.Profile)
+
+	// Opcode classes, most used first
+	classes := make([]string, 0, len(r.Opcodes))
+	for class := range r.Opcodes {
+		classes = append(classes, class)
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if r.Opcodes[classes[i]] != r.Opcodes[classes[j]] {
+			return r.Opcodes[classes[i]] > r.Opcodes[classes[j]]
+		}
+		return classes[i] < classes[j]
+	})
+	fmt.Fprintf(w, "Opcodes:  ")
+	for _, class := range classes {
+		fmt.Fprintf(w, " %s:%d", class, r.Opcodes[class])
+	}
+	fmt.Fprintln(w)
+
+	for _, warning := range r.Warnings {
+		fmt.Fprintf(w, "Warning:   %s\n", warning)
+	}
+	for _, note := range r.Notes {
+		fmt.Fprintf(w, "Note:      %s\n", note)
+	}
+}
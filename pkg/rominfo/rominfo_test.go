@@ -0,0 +1,121 @@
+package rominfo
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name     string
+		rom      []byte
+		platform Platform
+		evidence []string
+		profile  string
+	}{
+		{
+			// 6005 A20A D015 1206, sprite data after the loop
+			"chip8",
+			[]byte{0x60, 0x05, 0xA2, 0x0A, 0xD0, 0x15, 0x12, 0x06, 0x00, 0x00, 0xF0, 0x90},
+			PLATFORM_CHIP8, nil, "modern",
+		},
+		{
+			// 00FF hires on, D010 16x16 sprite
+			"schip",
+			[]byte{0x00, 0xFF, 0xD0, 0x10, 0x12, 0x04},
+			PLATFORM_SCHIP, []string{"00FF@200", "D010@202"}, "schip",
+		},
+		{
+			// F000 long I load, skipping the address, then scroll up
+			"xochip",
+			[]byte{0xF0, 0x00, 0x12, 0x34, 0x00, 0xD2, 0x12, 0x06},
+			PLATFORM_XOCHIP, []string{"00D2@204", "F000@200"}, "xochip",
+		},
+		{
+			// 0011 MegaChip on, 00FF is SUPER-CHIP but MegaChip wins
+			"megachip",
+			[]byte{0x00, 0x11, 0x00, 0xFF, 0x12, 0x04},
+			PLATFORM_MEGACHIP, []string{"0011@200"}, "schip",
+		},
+		{
+			// 0300 calls 1802 code
+			"machine code",
+			[]byte{0x03, 0x00, 0x12, 0x02},
+			PLATFORM_CHIP8, nil, "vip",
+		},
+		{
+			// 1260 jumps over the 1802 patch to 2C0, which clears with 0230
+			"hires",
+			func() []byte {
+				rom := make([]byte, 0xC4)
+				rom[0], rom[1] = 0x12, 0x60
+				copy(rom[0xC0:], []byte{0x02, 0x30, 0x12, 0xC2})
+				return rom
+			}(),
+			PLATFORM_HIRES, []string{"1260"}, "vip",
+		},
+	}
+	for _, tt := range tests {
+		r := Analyze(tt.rom)
+		if r.Platform != tt.platform || r.Profile != tt.profile {
+			t.Errorf("%s: platform %s, profile %s, want %s, %s", tt.name, r.Platform, r.Profile, tt.platform, tt.profile)
+		}
+		if !reflect.DeepEqual(r.Evidence, tt.evidence) {
+			t.Errorf("%s: evidence %v, want %v", tt.name, r.Evidence, tt.evidence)
+		}
+		if !r.OK() {
+			t.Errorf("%s: warnings %v", tt.name, r.Warnings)
+		}
+	}
+}
+
+func TestTrace(t *testing.T) {
+	// 3000 may skip 1300, which jumps out of the ROM and isn't followed, 2208 calls a subroutine, the jump back at 206
+	// loops, and the data at 20A is never reached
+	//
+	//	200: 3000 1300 2208 1206 00EE FFFF
+	rom := []byte{0x30, 0x00, 0x13, 0x00, 0x22, 0x08, 0x12, 0x06, 0x00, 0xEE, 0xFF, 0xFF}
+	r := Analyze(rom)
+	if r.CodeBytes != 10 {
+		t.Errorf("CodeBytes = %d, want 10", r.CodeBytes)
+	}
+	want := map[string]int{"3XNN": 1, "1NNN": 2, "2NNN": 1, "00EE": 1}
+	if !reflect.DeepEqual(r.Opcodes, want) {
+		t.Errorf("Opcodes = %v, want %v", r.Opcodes, want)
+	}
+	if !r.OK() {
+		t.Errorf("warnings = %v", r.Warnings)
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	tests := []struct {
+		name string
+		rom  []byte
+	}{
+		{"empty", nil},
+		{"zero entry", []byte{0x00, 0x00}},
+		{"jump out", []byte{0x15, 0x00}},
+		{"too large", append([]byte{0x12, 0x00}, make([]byte, MAX_SIZE)...)},
+	}
+	for _, tt := range tests {
+		if r := Analyze(tt.rom); r.OK() {
+			t.Errorf("%s: no warnings", tt.name)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package rsp
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Client drives a chippy debug server
+// Requests are one at a time, but Halt may be called while Continue waits
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	// Guards writes, so Halt can interrupt a Continue
+	mu sync.Mutex
+}
+
+// Connects to a debug server, the target halts until told to continue
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// Sends a packet and waits for the reply
+// Error replies (Exx) come back as errors
+func (c *Client) Request(data string) (string, error) {
+	c.mu.Lock()
+	err := WritePacket(c.conn, data)
+	c.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	return c.reply()
+}
+
+// Reads and acks a reply packet
+func (c *Client) reply() (string, error) {
+	data, _, err := ReadPacket(c.r)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	_, err = c.conn.Write([]byte{'+'})
+	c.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 3 && data[0] == 'E' {
+		return data, fmt.Errorf("debug server replied %s :(", data)
+	}
+	return data, nil
+}
+
+// Sends a packet that should be answered with OK
+func (c *Client) requestOK(data string) error {
+	reply, err := c.Request(data)
+	if err != nil {
+		return err
+	}
+	if reply != "OK" {
+		return fmt.Errorf("debug server replied %q to %q :(", reply, data)
+	}
+	return nil
+}
+
+// Returns why the target is stopped, like S05
+func (c *Client) StopReason() (string, error) {
+	return c.Request("?")
+}
+
+// Reads every register
+func (c *Client) Registers() (Registers, error) {
+	reply, err := c.Request("g")
+	if err != nil {
+		return Registers{}, err
+	}
+	return DecodeRegisters(reply)
+}
+
+// Writes every register
+func (c *Client) SetRegisters(r Registers) error {
+	return c.requestOK("G" + r.Encode())
+}
+
+// Reads one register, see REG_I and friends
+func (c *Client) Register(n int) (uint16, error) {
+	reply, err := c.Request(fmt.Sprintf("p%x", n))
+	if err != nil {
+		return 0, err
+	}
+	return DecodeRegister(n, reply)
+}
+
+// Writes one register, see REG_I and friends
+func (c *Client) SetRegister(n int, val uint16) error {
+	return c.requestOK(fmt.Sprintf("P%x=%s", n, EncodeRegister(n, val)))
+}
+
+// Reads n bytes of memory starting at addr
+func (c *Client) ReadMemory(addr uint16, n int) ([]byte, error) {
+	reply, err := c.Request(fmt.Sprintf("m%x,%x", addr, n))
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(reply)
+}
+
+// Writes bytes to memory starting at addr
+func (c *Client) WriteMemory(addr uint16, data []byte) error {
+	return c.requestOK(fmt.Sprintf("M%x,%x:%s", addr, len(data), hex.EncodeToString(data)))
+}
+
+// Sets a breakpoint on the instruction at addr
+func (c *Client) SetBreakpoint(addr uint16) error {
+	return c.requestOK(fmt.Sprintf("Z0,%x,2", addr))
+}
+
+// Clears the breakpoint on the instruction at addr
+func (c *Client) ClearBreakpoint(addr uint16) error {
+	return c.requestOK(fmt.Sprintf("z0,%x,2", addr))
+}
+
+// Executes one instruction, and returns the stop reply
+func (c *Client) Step() (string, error) {
+	return c.Request("s")
+}
+
+// Resumes the target, and waits until it stops at a breakpoint or is halted
+// Returns the stop reply, STOP_TRAP or STOP_INTERRUPT
+func (c *Client) Continue() (string, error) {
+	reply, err := c.Request("c")
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(reply, "S") && !strings.HasPrefix(reply, "T") {
+		return reply, fmt.Errorf("unexpected stop reply %q :(", reply)
+	}
+	return reply, nil
+}
+
+// Interrupts a running target, the pending Continue returns STOP_INTERRUPT
+func (c *Client) Halt() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := c.conn.Write([]byte{INTERRUPT})
+	return err
+}
+
+// Detaches, letting the target run freely, and closes the connection
+func (c *Client) Detach() error {
+	err := c.requestOK("D")
+	c.conn.Close()
+	return err
+}
+
+// Closes the connection without detaching cleanly
+// The server detaches anyway when the connection drops
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,207 @@
+package rsp
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+// Package rsp speaks chippy's remote debug protocol, modelled on the GDB
+// Remote Serial Protocol: packets look like $data#checksum, are acked with
+// + (or - to ask for a resend), and a lone 0x03 byte interrupts the target.
+// It has no SDL dependency, so tools can import it to drive chippy.
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Byte sent to halt a running target
+const INTERRUPT = 0x03
+
+// Stop replies, with the signal that stopped the target
+const (
+	// Stopped at a breakpoint, after a step, or on attach
+	STOP_TRAP = "S05"
+
+	// Stopped by an interrupt
+	STOP_INTERRUPT = "S02"
+)
+
+// Register numbers, in the order g and G send them
+// V0 to VF are 0 to 15
+const (
+	REG_I = 16 + iota
+	REG_PC
+	REG_SP
+	REG_DT
+	REG_ST
+
+	// Number of registers
+	REG_COUNT
+)
+
+// Returns the size of a register in bytes
+func RegisterSize(n int) int {
+	if n == REG_I || n == REG_PC {
+		return 2
+	}
+	return 1
+}
+
+// Registers are every CHIP-8 register, as sent by g
+// Multi-byte registers are little endian on the wire
+type Registers struct {
+	V  [16]uint8
+	I  uint16
+	PC uint16
+	SP uint8
+	DT uint8
+	ST uint8
+}
+
+// Returns register n, see REG_I and friends
+func (r Registers) Get(n int) uint16 {
+	switch n {
+	case REG_I:
+		return r.I
+	case REG_PC:
+		return r.PC
+	case REG_SP:
+		return uint16(r.SP)
+	case REG_DT:
+		return uint16(r.DT)
+	case REG_ST:
+		return uint16(r.ST)
+	default:
+		return uint16(r.V[n&0xF])
+	}
+}
+
+// Sets register n, see REG_I and friends
+func (r *Registers) Set(n int, val uint16) {
+	switch n {
+	case REG_I:
+		r.I = val
+	case REG_PC:
+		r.PC = val
+	case REG_SP:
+		r.SP = uint8(val)
+	case REG_DT:
+		r.DT = uint8(val)
+	case REG_ST:
+		r.ST = uint8(val)
+	default:
+		r.V[n&0xF] = uint8(val)
+	}
+}
+
+// Encodes the registers as hex, like the reply to g
+func (r Registers) Encode() string {
+	buff := []byte{}
+	for n := 0; n < REG_COUNT; n++ {
+		buff = append(buff, EncodeRegister(n, r.Get(n))...)
+	}
+	return string(buff)
+}
+
+// Decodes registers from hex, like the data of G
+func DecodeRegisters(data string) (Registers, error) {
+	var r Registers
+	for n := 0; n < REG_COUNT; n++ {
+		size := RegisterSize(n) * 2
+		if len(data) < size {
+			return r, fmt.Errorf("register data is too short :(")
+		}
+		val, err := DecodeRegister(n, data[:size])
+		if err != nil {
+			return r, err
+		}
+		r.Set(n, val)
+		data = data[size:]
+	}
+	return r, nil
+}
+
+// Encodes one register as little endian hex
+func EncodeRegister(n int, val uint16) string {
+	if RegisterSize(n) == 1 {
+		return hex.EncodeToString([]byte{uint8(val)})
+	}
+	var b [2]byte
+	binary.LittleEndian.PutUint16(b[:], val)
+	return hex.EncodeToString(b[:])
+}
+
+// Decodes one register from little endian hex
+func DecodeRegister(n int, data string) (uint16, error) {
+	b, err := hex.DecodeString(data)
+	if err != nil || len(b) != RegisterSize(n) {
+		return 0, fmt.Errorf("bad value %q for register %d :(", data, n)
+	}
+	if len(b) == 1 {
+		return uint16(b[0]), nil
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+// Writes a packet, framed and checksummed
+func WritePacket(w io.Writer, data string) error {
+	_, err := fmt.Fprintf(w, "$%s#%02x", data, checksum(data))
+	return err
+}
+
+// Reads the next packet, skipping acks
+// Returns interrupt true, and no data, for a 0x03 byte between packets
+func ReadPacket(r *bufio.Reader) (data string, interrupt bool, err error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return "", false, err
+		}
+		switch b {
+		case INTERRUPT:
+			return "", true, nil
+		case '$':
+			body, err := r.ReadString('#')
+			if err != nil {
+				return "", false, err
+			}
+			body = body[:len(body)-1]
+
+			var sum [2]byte
+			if _, err := io.ReadFull(r, sum[:]); err != nil {
+				return "", false, err
+			}
+			want, err := hex.DecodeString(string(sum[:]))
+			if err != nil || want[0] != checksum(body) {
+				return "", false, fmt.Errorf("bad checksum on packet %q :(", body)
+			}
+			return body, false, nil
+		case '-':
+			return "", false, fmt.Errorf("packet was rejected :(")
+		}
+		// Acks and anything else between packets are ignored
+	}
+}
+
+// Returns the packet checksum, the sum of its bytes mod 256
+func checksum(data string) uint8 {
+	var sum uint8
+	for i := 0; i < len(data); i++ {
+		sum += data[i]
+	}
+	return sum
+}
@@ -0,0 +1,187 @@
+package screen
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"chippy/pkg/filter"
+	"chippy/pkg/palette"
+	"fmt"
+	"image/color"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Scaling decides how the CHIP-8 display is fitted into the window
+type Scaling int
+
+const (
+	// Scale by the largest fraction that fits, keeping the aspect ratio
+	SCALE_FIT Scaling = iota
+
+	// Scale by the largest whole number that fits, so every CHIP-8 pixel is
+	// the same size on screen
+	SCALE_INTEGER
+)
+
+// Parses a scaling mode name, fit or integer
+func ParseScaling(name string) (Scaling, error) {
+	switch name {
+	case "fit":
+		return SCALE_FIT, nil
+	case "integer":
+		return SCALE_INTEGER, nil
+	default:
+		return SCALE_FIT, fmt.Errorf("unknown scaling %q, expected fit or integer :(", name)
+	}
+}
+
+// Screen draws CHIP-8 frames through a single streaming texture
+type Screen struct {
+	renderer *sdl.Renderer
+	texture  *sdl.Texture
+
+	// Optional post-processing, nil uploads the frame as is
+	filter *filter.Filter
+
+	// Size of the CHIP-8 display, in CHIP-8 pixels
+	width  int
+	height int
+
+	// RGBA upload buffer, when there is no filter
+	pixels []byte
+
+	scaling Scaling
+}
+
+// Creates a screen for a CHIP-8 display of the given size
+func New(renderer *sdl.Renderer, width, height int, filt *filter.Filter, scaling Scaling) (*Screen, error) {
+	s := &Screen{
+		renderer: renderer,
+		filter:   filt,
+		width:    width,
+		height:   height,
+		scaling:  scaling,
+	}
+
+	if err := s.Resize(width, height); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Returns the size of the CHIP-8 display, in CHIP-8 pixels
+func (s *Screen) Size() (int, int) {
+	return s.width, s.height
+}
+
+// Changes the size of the CHIP-8 display, for platforms that switch modes
+func (s *Screen) Resize(width, height int) error {
+	s.width = width
+	s.height = height
+
+	// The texture is the size of whatever we upload, the filter may upscale
+	tw, th := width, height
+	if s.filter != nil {
+		s.filter.Resize(width, height)
+		tw, th = s.filter.Size()
+	} else {
+		s.pixels = make([]byte, width*height*4)
+	}
+
+	texture, err := s.renderer.CreateTexture(sdl.PIXELFORMAT_RGBA32, sdl.TEXTUREACCESS_STREAMING, int32(tw), int32(th))
+	if err != nil {
+		return err
+	}
+	if s.texture != nil {
+		s.texture.Destroy()
+	}
+	s.texture = texture
+	return nil
+}
+
+// Uploads a frame to the texture
+// display holds one CHIP-8 pixel value per byte, row by row
+func (s *Screen) Update(display []uint8, pal palette.Palette) error {
+	if s.filter != nil {
+		return s.texture.Update(nil, s.filter.Apply(display, pal), s.filter.Pitch())
+	}
+
+	for i := 0; i < s.width*s.height && i < len(display); i++ {
+		s.setPixel(i, pal.Color(display[i]))
+	}
+	return s.texture.Update(nil, s.pixels, s.width*4)
+}
+
+// Uploads a frame with its own colour for every pixel
+// display says which CHIP-8 pixels are lit, colors holds the colour of each,
+// lit or not. bg is what the filter fades scanlines and gaps towards.
+func (s *Screen) UpdateColors(display []uint8, colors []color.RGBA, bg color.RGBA) error {
+	if s.filter != nil {
+		return s.texture.Update(nil, s.filter.ApplyColors(display, colors, bg), s.filter.Pitch())
+	}
+
+	for i := 0; i < s.width*s.height && i < len(colors); i++ {
+		s.setPixel(i, colors[i])
+	}
+	return s.texture.Update(nil, s.pixels, s.width*4)
+}
+
+// Sets pixel i of the upload buffer
+func (s *Screen) setPixel(i int, c color.RGBA) {
+	s.pixels[i*4] = c.R
+	s.pixels[i*4+1] = c.G
+	s.pixels[i*4+2] = c.B
+	s.pixels[i*4+3] = 255
+}
+
+// Copies the texture to the renderer, scaled and centered in the window
+// The background colour fills the borders
+func (s *Screen) Draw(bg sdl.Color) error {
+	s.renderer.SetDrawColor(bg.R, bg.G, bg.B, 255)
+	s.renderer.Clear()
+	dst, err := s.Rect()
+	if err != nil {
+		return err
+	}
+	return s.renderer.Copy(s.texture, nil, &dst)
+}
+
+// Returns where the CHIP-8 display ends up in the window
+func (s *Screen) Rect() (sdl.Rect, error) {
+	ow, oh, err := s.renderer.GetOutputSize()
+	if err != nil {
+		return sdl.Rect{}, err
+	}
+
+	// Largest scale that fits both ways
+	scale := float64(ow) / float64(s.width)
+	if v := float64(oh) / float64(s.height); v < scale {
+		scale = v
+	}
+	if s.scaling == SCALE_INTEGER && scale >= 1 {
+		scale = float64(int(scale))
+	}
+
+	w := int32(float64(s.width) * scale)
+	h := int32(float64(s.height) * scale)
+	return sdl.Rect{X: (ow - w) / 2, Y: (oh - h) / 2, W: w, H: h}, nil
+}
+
+// Frees the texture
+func (s *Screen) Destroy() {
+	s.texture.Destroy()
+}
@@ -0,0 +1,177 @@
+package symbols
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Table maps addresses to labels and source lines
+//
+// Symbol files are text, one symbol per line, with # starting a comment:
+//
+//	0x200 main            a label
+//	0x200 game.8o:12      the source line an address was assembled from
+//	main = 0x200          a label, as assemblers and Octo's :const print them
+type Table struct {
+	labels map[string]uint16
+	names  map[uint16]string
+
+	// Source lines, by address and by file:line
+	lines map[uint16]Line
+	addrs map[Line][]uint16
+
+	// Labelled addresses, sorted, for Nearest
+	sorted []uint16
+}
+
+// Line is a line of a source file
+type Line struct {
+	File string
+	Line int
+}
+
+// Returns an empty table
+func New() *Table {
+	return &Table{
+		labels: map[string]uint16{},
+		names:  map[uint16]string{},
+		lines:  map[uint16]Line{},
+		addrs:  map[Line][]uint16{},
+	}
+}
+
+// Loads a symbol file
+func Load(file string) (*Table, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parses a symbol file
+func Parse(r io.Reader) (*Table, error) {
+	t := New()
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 3 && fields[1] == "=":
+			addr, err := parseAddr(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("symbols line %d: %v", n, err)
+			}
+			t.AddLabel(fields[0], addr)
+		case len(fields) == 2:
+			addr, err := parseAddr(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("symbols line %d: %v", n, err)
+			}
+			if src, ok := parseLine(fields[1]); ok {
+				t.AddLine(addr, src)
+			} else {
+				t.AddLabel(fields[1], addr)
+			}
+		default:
+			return nil, fmt.Errorf("symbols line %d: expected an address and a label or file:line :(", n)
+		}
+	}
+	return t, scanner.Err()
+}
+
+// Adds a label
+func (t *Table) AddLabel(name string, addr uint16) {
+	t.labels[name] = addr
+	if _, ok := t.names[addr]; !ok {
+		t.names[addr] = name
+		t.sorted = append(t.sorted, addr)
+		sort.Slice(t.sorted, func(a, b int) bool { return t.sorted[a] < t.sorted[b] })
+	}
+}
+
+// Adds the source line an address was assembled from
+func (t *Table) AddLine(addr uint16, src Line) {
+	t.lines[addr] = src
+	src.File = filepath.Base(src.File)
+	t.addrs[src] = append(t.addrs[src], addr)
+}
+
+// Returns the address of a label
+func (t *Table) Label(name string) (uint16, bool) {
+	addr, ok := t.labels[name]
+	return addr, ok
+}
+
+// Returns the label of the closest labelled address at or before addr, and
+// how far past it addr is
+func (t *Table) Nearest(addr uint16) (string, uint16, bool) {
+	i := sort.Search(len(t.sorted), func(i int) bool { return t.sorted[i] > addr })
+	if i == 0 {
+		return "", 0, false
+	}
+	at := t.sorted[i-1]
+	return t.names[at], addr - at, true
+}
+
+// Returns the source line an address was assembled from
+func (t *Table) Line(addr uint16) (Line, bool) {
+	src, ok := t.lines[addr]
+	return src, ok
+}
+
+// Returns the addresses assembled from a source line
+// Files match on their base name, so paths don't have to agree
+func (t *Table) Addresses(file string, line int) []uint16 {
+	return t.addrs[Line{File: filepath.Base(file), Line: line}]
+}
+
+// Parses a hex address, with or without a 0x or $ prefix
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "0x"), "$")
+	addr, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad address %q :(", s)
+	}
+	return uint16(addr), nil
+}
+
+// Parses file:line
+func parseLine(s string) (Line, bool) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return Line{}, false
+	}
+	n, err := strconv.Atoi(s[i+1:])
+	if err != nil || n <= 0 {
+		return Line{}, false
+	}
+	return Line{File: s[:i], Line: n}, true
+}
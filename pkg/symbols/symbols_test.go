@@ -0,0 +1,113 @@
+package symbols
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const symbolFile = `
+# Built by hand
+0x200 main
+$20A  loop        # a comment
+draw = 0x214
+0x200 src/game.8o:3
+0x202 src/game.8o:3
+0x20A src/game.8o:7
+`
+
+func TestParse(t *testing.T) {
+	table, err := Parse(strings.NewReader(symbolFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]uint16{"main": 0x200, "loop": 0x20A, "draw": 0x214}
+	for name, want := range labels {
+		if addr, ok := table.Label(name); !ok || addr != want {
+			t.Errorf("Label(%s) = %03X, %v, want %03X", name, addr, ok, want)
+		}
+	}
+	if _, ok := table.Label("nope"); ok {
+		t.Errorf("found a label called nope")
+	}
+
+	if src, ok := table.Line(0x20A); !ok || src != (Line{"src/game.8o", 7}) {
+		t.Errorf("Line(20A) = %+v, %v", src, ok)
+	}
+	if _, ok := table.Line(0x204); ok {
+		t.Errorf("found a line for 204")
+	}
+
+	// Files match on their base name
+	if addrs := table.Addresses("/elsewhere/game.8o", 3); !reflect.DeepEqual(addrs, []uint16{0x200, 0x202}) {
+		t.Errorf("Addresses(game.8o, 3) = %03X", addrs)
+	}
+}
+
+func TestNearest(t *testing.T) {
+	table, err := Parse(strings.NewReader(symbolFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		addr   uint16
+		label  string
+		offset uint16
+		ok     bool
+	}{
+		{0x1FE, "", 0, false},
+		{0x200, "main", 0, true},
+		{0x208, "main", 8, true},
+		{0x20A, "loop", 0, true},
+		{0xFFF, "draw", 0xFFF - 0x214, true},
+	}
+	for _, tt := range tests {
+		label, offset, ok := table.Nearest(tt.addr)
+		if label != tt.label || offset != tt.offset || ok != tt.ok {
+			t.Errorf("Nearest(%03X) = %s+%d, %v, want %s+%d, %v", tt.addr, label, offset, ok, tt.label, tt.offset, tt.ok)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"0x200",
+		"0x200 main extra",
+		"zz main",
+		"main = nowhere",
+		"0x10000 main",
+	}
+	for _, file := range tests {
+		if _, err := Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("Parse(%q) took it", file)
+		}
+	}
+
+	// A name that looks like file:line but isn't one is a label
+	table, err := Parse(strings.NewReader("0x200 game.8o:x\n0x202 :3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := table.Label("game.8o:x"); !ok {
+		t.Errorf("game.8o:x isn't a label")
+	}
+	if _, ok := table.Label(":3"); !ok {
+		t.Errorf(":3 isn't a label")
+	}
+}
@@ -0,0 +1,257 @@
+package trace
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bufio"
+	"chippy/pkg/chip8"
+	"chippy/pkg/rominfo"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Magic bytes at the start of a binary trace
+const MAGIC = "CH8T"
+
+// Binary trace format version
+const VERSION = 1
+
+// Format is how trace records are written
+type Format int
+
+const (
+	// One line of text per instruction
+	FORMAT_TEXT Format = iota
+
+	// Compact little endian records, see Writer.binary
+	FORMAT_BINARY
+)
+
+// Returns the name of the format, as used on the command line
+func (f Format) String() string {
+	switch f {
+	case FORMAT_TEXT:
+		return "text"
+	case FORMAT_BINARY:
+		return "binary"
+	default:
+		return fmt.Sprintf("Format(%d)", int(f))
+	}
+}
+
+// Parses a format name, as returned by Format.String()
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text":
+		return FORMAT_TEXT, nil
+	case "binary":
+		return FORMAT_BINARY, nil
+	default:
+		return FORMAT_TEXT, fmt.Errorf("unknown trace format %q :(", name)
+	}
+}
+
+// Filter decides which instructions get traced
+// The zero value traces everything
+type Filter struct {
+	// Only trace instructions in this address range, inclusive, if Ranged
+	Ranged bool
+	Low    uint16
+	High   uint16
+
+	// Only trace these opcode classes (see rominfo.Classify), a single hex
+	// digit matches every class starting with it, empty traces all classes
+	Classes []string
+}
+
+// Parses a PC range like "200-2FF" (hex, inclusive) into the filter
+func (f *Filter) ParseRange(spec string) error {
+	lo, hi := spec, spec
+	if i := strings.Index(spec, "-"); i >= 0 {
+		lo, hi = spec[:i], spec[i+1:]
+	}
+	low, err := parseAddr(lo)
+	if err != nil {
+		return err
+	}
+	high, err := parseAddr(hi)
+	if err != nil {
+		return err
+	}
+	if low > high {
+		return fmt.Errorf("trace range %s is backwards :(", spec)
+	}
+	f.Ranged, f.Low, f.High = true, low, high
+	return nil
+}
+
+// Parses a comma separated list of opcode classes like "D,8XY4,FX33"
+func (f *Filter) ParseClasses(spec string) error {
+	valid := map[string]bool{}
+	for _, class := range rominfo.Classes() {
+		valid[class] = true
+	}
+
+	f.Classes = nil
+	for _, class := range strings.Split(spec, ",") {
+		class = strings.ToUpper(strings.TrimSpace(class))
+		if class == "" {
+			continue
+		}
+		_, err := strconv.ParseUint(class, 16, 4)
+		digit := len(class) == 1 && err == nil
+		if !digit && !valid[class] {
+			return fmt.Errorf("unknown opcode class %q, expected a hex digit or one of %s :(",
+				class, strings.Join(rominfo.Classes(), ","))
+		}
+		f.Classes = append(f.Classes, class)
+	}
+	return nil
+}
+
+// Returns true if the record passes the filter
+func (f *Filter) Match(r *chip8.TraceRecord) bool {
+	if f.Ranged && (r.PC < f.Low || r.PC > f.High) {
+		return false
+	}
+	if len(f.Classes) == 0 {
+		return true
+	}
+	class, _ := rominfo.Classify(r.Opcode)
+	for _, want := range f.Classes {
+		if class == want || (len(want) == 1 && strings.HasPrefix(class, want)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses a hex address, with or without a 0x prefix
+func parseAddr(s string) (uint16, error) {
+	s = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), "0x")
+	addr, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("bad trace address %q :(", s)
+	}
+	return uint16(addr), nil
+}
+
+// Writer is a chip8.Tracer that writes filtered records to an io.Writer
+type Writer struct {
+	w      *bufio.Writer
+	format Format
+	filter Filter
+
+	// First write error, tracing stops after it
+	err error
+
+	// Scratch space for binary records
+	buff []byte
+}
+
+// Creates a trace writer, binary traces get their header straight away
+// Call Flush when done
+func NewWriter(w io.Writer, format Format, filter Filter) *Writer {
+	t := &Writer{
+		w:      bufio.NewWriter(w),
+		format: format,
+		filter: filter,
+		buff:   make([]byte, 0, 32),
+	}
+	if format == FORMAT_BINARY {
+		_, t.err = t.w.WriteString(MAGIC)
+		if t.err == nil {
+			t.err = t.w.WriteByte(VERSION)
+		}
+	}
+	return t
+}
+
+// Writes a record, if it passes the filter
+func (t *Writer) Trace(r *chip8.TraceRecord) {
+	if t.err != nil || !t.filter.Match(r) {
+		return
+	}
+	if t.format == FORMAT_BINARY {
+		t.err = t.binary(r)
+	} else {
+		t.err = t.text(r)
+	}
+}
+
+// Writes a record as a line of text:
+//
+//	cycle PC opcode disassembly I=... DT=... ST=... [VX=old->new ...]
+func (t *Writer) text(r *chip8.TraceRecord) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%10d %03X %04X %-18s I=%03X DT=%02X ST=%02X",
+		r.Cycle, r.PC, r.Opcode, chip8.Disassemble(r.Opcode), r.I, r.DT, r.ST)
+	changed := r.Changed()
+	for i := 0; i < 16; i++ {
+		if changed&(1<<uint(i)) != 0 {
+			fmt.Fprintf(&b, " V%X=%02X->%02X", i, r.Before[i], r.After[i])
+		}
+	}
+	b.WriteByte('\n')
+	_, err := t.w.WriteString(b.String())
+	return err
+}
+
+// Writes a record in binary, all little endian:
+//
+//	u64 cycle, u16 PC, u16 opcode, u16 I, u8 DT, u8 ST, u16 changed mask,
+//	then the new value of every changed V register, lowest first
+func (t *Writer) binary(r *chip8.TraceRecord) error {
+	b := t.buff[:0]
+	b = appendUint64(b, r.Cycle)
+	b = appendUint16(b, r.PC)
+	b = appendUint16(b, r.Opcode)
+	b = appendUint16(b, r.I)
+	b = append(b, r.DT, r.ST)
+	changed := r.Changed()
+	b = appendUint16(b, changed)
+	for i := 0; i < 16; i++ {
+		if changed&(1<<uint(i)) != 0 {
+			b = append(b, r.After[i])
+		}
+	}
+	t.buff = b
+	_, err := t.w.Write(b)
+	return err
+}
+
+// Flushes buffered records, returning the first error tracing hit
+func (t *Writer) Flush() error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.w.Flush()
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	var tmp [2]byte
+	binary.LittleEndian.PutUint16(tmp[:], v)
+	return append(b, tmp[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], v)
+	return append(b, tmp[:]...)
+}
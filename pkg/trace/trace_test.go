@@ -0,0 +1,134 @@
+package trace
+
+/*
+
+         dP       oo
+         88
+.d8888b. 88d888b. dP 88d888b. 88d888b. dP    dP
+88'  `"" 88'  `88 88 88'  `88 88'  `88 88    88
+88.  ... 88    88 88 88.  .88 88.  .88 88.  .88
+`88888P' dP    dP dP 88Y888P' 88Y888P' `8888P88
+                     88       88            .88
+                     dP       dP        d8888P
+
+				CHIP-8 Emulator
+					m0x <3
+*/
+
+import (
+	"bytes"
+	"chippy/pkg/chip8"
+	"strings"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		spec      string
+		low, high uint16
+		wantErr   bool
+	}{
+		{"200-2FF", 0x200, 0x2FF, false},
+		{"0x300", 0x300, 0x300, false},
+		{"0-0", 0, 0, false},
+		{"2FF-200", 0, 0, true},
+		{"200-zz", 0, 0, true},
+		{"10000", 0, 0, true},
+	}
+	for _, tt := range tests {
+		var f Filter
+		err := f.ParseRange(tt.spec)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRange(%q) error = %v", tt.spec, err)
+			continue
+		}
+		if err == nil && (!f.Ranged || f.Low != tt.low || f.High != tt.high) {
+			t.Errorf("ParseRange(%q) = %+v, want %03X-%03X", tt.spec, f, tt.low, tt.high)
+		}
+	}
+}
+
+func TestParseClasses(t *testing.T) {
+	var f Filter
+	if err := f.ParseClasses(" d, 8xy4,,FX33 "); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(f.Classes, ",") != "D,8XY4,FX33" {
+		t.Errorf("Classes = %v", f.Classes)
+	}
+
+	for _, spec := range []string{"G", "8XY9", "DRW", "D,nope"} {
+		err := f.ParseClasses(spec)
+		if err == nil {
+			t.Errorf("ParseClasses(%q) took it", spec)
+		} else if !strings.Contains(err.Error(), "8XY4") {
+			t.Errorf("ParseClasses(%q) error doesn't list the classes: %v", spec, err)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		pc     uint16
+		opcode uint16
+		want   bool
+	}{
+		{"everything", Filter{}, 0x000, 0xD015, true},
+		{"in range", Filter{Ranged: true, Low: 0x200, High: 0x2FF}, 0x2FF, 0xD015, true},
+		{"out of range", Filter{Ranged: true, Low: 0x200, High: 0x2FF}, 0x300, 0xD015, false},
+		{"address 0", Filter{Ranged: true}, 0x000, 0xD015, true},
+		{"not address 0", Filter{Ranged: true}, 0x200, 0xD015, false},
+		{"class", Filter{Classes: []string{"8XY4"}}, 0x200, 0x8124, true},
+		{"other class", Filter{Classes: []string{"8XY4"}}, 0x200, 0x8125, false},
+		{"digit", Filter{Classes: []string{"8"}}, 0x200, 0x8125, true},
+		{"both", Filter{Ranged: true, Low: 0x300, High: 0x300, Classes: []string{"D"}}, 0x200, 0xD015, false},
+	}
+	for _, tt := range tests {
+		r := chip8.TraceRecord{PC: tt.pc, Opcode: tt.opcode}
+		if got := tt.filter.Match(&r); got != tt.want {
+			t.Errorf("%s: Match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	r := chip8.TraceRecord{Cycle: 7, PC: 0x202, Opcode: 0x7105, I: 0x300}
+	r.After[1] = 5
+
+	var text bytes.Buffer
+	w := NewWriter(&text, FORMAT_TEXT, Filter{})
+	w.Trace(&r)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if line := text.String(); !strings.Contains(line, "202 7105") || !strings.HasSuffix(line, " V1=00->05\n") {
+		t.Errorf("text record = %q", line)
+	}
+
+	var bin bytes.Buffer
+	w = NewWriter(&bin, FORMAT_BINARY, Filter{Classes: []string{"7"}})
+	w.Trace(&r)
+	r.Opcode = 0x6105
+	w.Trace(&r)
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte(MAGIC + "\x01" +
+		"\x07\x00\x00\x00\x00\x00\x00\x00" + "\x02\x02" + "\x05\x71" + "\x00\x03" + "\x00\x00" + "\x02\x00" + "\x05")
+	if !bytes.Equal(bin.Bytes(), want) {
+		t.Errorf("binary trace = % X, want % X", bin.Bytes(), want)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, f := range []Format{FORMAT_TEXT, FORMAT_BINARY} {
+		if got, err := ParseFormat(f.String()); err != nil || got != f {
+			t.Errorf("ParseFormat(%q) = %v, %v", f.String(), got, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Errorf("ParseFormat took xml")
+	}
+}